@@ -0,0 +1,328 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target is a pprofrec instance that is scraped by the Aggregate handler.
+type Target struct {
+	// Name labels the rows of the instance, defaults to URL.
+	Name string
+	// URL of the Window handler of the instance, e.g. http://10.0.0.1:8080/debug/pprof/window.
+	URL string
+	// Header is sent with every request, e.g. to authorize against the instance.
+	Header http.Header
+}
+
+// AggregateOpts configures the Aggregate handler.
+type AggregateOpts struct {
+	// Targets are the instances that are scraped, e.g. all replicas of a deployment.
+	Targets []Target
+	// Client scrapes the targets, defaults to a client with a timeout of 10 seconds.
+	Client *http.Client
+	// Columns selects the metrics that are rendered.
+	Columns Columns
+	// Highlights configures thresholds above which cells of the html table are highlighted.
+	Highlights []Highlight
+	// Theme configures the look of the html table, e.g. DarkTheme.
+	Theme Theme
+	// Auth restricts access to the handler.
+	Auth Auth
+	// OnError is called with failures to scrape and render metrics, they are logged if nil.
+	OnError func(error)
+}
+
+var defaultAggregateClient = &http.Client{Timeout: 10 * time.Second}
+
+// instance holds the records scraped from a target.
+type instance struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Records []Record `json:"records"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Aggregate scrapes the JSON endpoints of the Window handlers of multiple pprofrec instances
+// on every request and responds with a html table that lists the records of every instance
+// below a row naming it, so that fleet-wide trends are visible in one place.
+// The scraped records are returned as a JSON array of instances instead if the request
+// specifies ?format=json or accepts application/json.
+// ?window=, ?freq= and ?res= are passed on to the targets and ?cols= trims the rendered columns.
+func Aggregate(opts AggregateOpts) http.HandlerFunc {
+	if opts.Client == nil {
+		opts.Client = defaultAggregateClient
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(opts.OnError, r)
+
+		if !opts.Auth.authorize(w, r) {
+			return
+		}
+
+		q := url.Values{}
+		q.Set("format", formatJSON)
+		for _, name := range []string{"window", "freq", "res"} {
+			_, err := getQueryDuration(r, name, 0)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			if v := r.URL.Query().Get(name); v != "" {
+				q.Set(name, v)
+			}
+		}
+
+		format := getFormat(r)
+		switch format {
+		case formatHTML, formatJSON:
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+
+			return
+		}
+
+		is := scrapeTargets(r.Context(), opts.Client, opts.Targets, q)
+		for _, i := range is {
+			if i.Error != "" {
+				reportError(opts.OnError, fmt.Errorf("failed to scrape %v: %v", i.URL, i.Error))
+			}
+		}
+
+		if acceptsGzip(r) {
+			gw := newGzipResponseWriter(w)
+			defer func() {
+				err := gw.Close()
+				if err != nil {
+					reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+				}
+			}()
+
+			w = gw
+		}
+
+		if format == formatJSON {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set(schemaVersionHeader, strconv.Itoa(SchemaVersion))
+
+			err := json.NewEncoder(w).Encode(is)
+			if err != nil {
+				reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+			}
+
+			return
+		}
+
+		cols := getInstanceColumns(is)
+		cols = opts.Columns.filter(cols)
+		cols = getQueryColumns(r).filter(cols)
+
+		highlights := getHighlights(opts.Highlights, cols)
+
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		err := writeAggregate(w, cols, is, highlights, opts.Theme)
+		if err != nil {
+			reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
+
+// writeAggregate writes the html table of the instances, the records of each instance follow a row naming it.
+func writeAggregate(w io.Writer, cols []column, is []instance, highlights map[string]Highlight, theme Theme) (err error) {
+	err = writeHead(w, cols, nil, theme, display{})
+	if err != nil {
+		return
+	}
+
+	for _, i := range is {
+		text := fmt.Sprintf("%v records from %v", len(i.Records), i.URL)
+		if i.Error != "" {
+			text = fmt.Sprintf("failed to scrape %v: %v", i.URL, i.Error)
+		}
+
+		err = writeMarker(w, cols, "tbl__row--annotation tbl__row--instance", i.Name, text, display{})
+		if err != nil {
+			return
+		}
+
+		rs := i.Records
+		err = writeRows(w, cols, rs, false, func(j int, from time.Time, to time.Time) rowMeta {
+			return rowMeta{highlights: highlights, annotations: getRecordAnnotations(rs, j, from)}
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// scrapeTargets fetches the records of the targets concurrently, the instances are returned in the order of the targets.
+func scrapeTargets(ctx context.Context, client *http.Client, targets []Target, q url.Values) []instance {
+	is := make([]instance, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		is[i] = instance{Name: t.Name, URL: t.URL}
+		if is[i].Name == "" {
+			is[i].Name = t.URL
+		}
+
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+
+			rs, err := scrapeTarget(ctx, client, t, q)
+			if err != nil {
+				is[i].Error = err.Error()
+
+				return
+			}
+
+			is[i].Records = rs
+		}(i, t)
+	}
+	wg.Wait()
+
+	return is
+}
+
+// scrapeTarget fetches the records of t with the query parameters q merged into its URL.
+func scrapeTarget(ctx context.Context, client *http.Client, t Target, q url.Values) (rs []Record, err error) {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return
+	}
+
+	uq := u.Query()
+	for k, vs := range q {
+		uq[k] = vs
+	}
+	u.RawQuery = uq.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return
+	}
+	for k, vs := range t.Header {
+		req.Header[k] = vs
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return nil, fmt.Errorf("unexpected status code: %v: %v", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&rs)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// getInstanceColumns determines the columns of the metrics recorded by any of the instances,
+// gopsutil stats that are missing from some of the records are replaced by zero values.
+func getInstanceColumns(is []instance) (cols []column) {
+	var c capabilities
+	var pprof, memStats bool
+	runtimeMetrics := map[string]bool{}
+	samples := map[string][]Sample{}
+
+	for _, i := range is {
+		for _, r := range i.Records {
+			pprof = pprof || r.Pprof != PprofStat{}
+			memStats = memStats || r.MemStats != MemStats{}
+			c.memoryInfoStat = c.memoryInfoStat || r.MemoryInfo != nil
+			c.cpuTimeStat = c.cpuTimeStat || r.CPUTimes != nil
+			c.iOCounterStat = c.iOCounterStat || r.IOCounters != nil
+			c.ctxSwitchStat = c.ctxSwitchStat || r.NumCtxSwitches != nil
+			c.pageFaultStat = c.pageFaultStat || r.PageFaults != nil
+			c.memoryLimit = c.memoryLimit || r.MemoryLimit != nil
+			c.win32 = c.win32 || r.Win32 != nil
+			c.schedLatencies = c.schedLatencies || r.SchedLatencies != nil
+			c.gcPauses = c.gcPauses || r.GCPauses != nil
+			c.rates = c.rates || r.Rates != nil
+			c.overhead = c.overhead || r.Overhead != nil
+
+			for name := range r.RuntimeMetrics {
+				runtimeMetrics[name] = true
+			}
+
+			for name, ss := range r.Samples {
+			samples:
+				for _, s := range ss {
+					for _, known := range samples[name] {
+						if known.Name == s.Name {
+							continue samples
+						}
+					}
+
+					samples[name] = append(samples[name], s)
+				}
+			}
+		}
+	}
+
+	groups := getGroups(getColumns(c))
+	for j := range is {
+		for k := range is[j].Records {
+			is[j].Records[k].fillGroups(groups)
+		}
+	}
+
+	for _, col := range getColumns(c) {
+		switch col.group {
+		case pprofGroup:
+			if !pprof {
+				continue
+			}
+		case memStatsGroup:
+			if !memStats {
+				continue
+			}
+		case runtimeMetricsGroup:
+			if !runtimeMetrics[col.name] {
+				continue
+			}
+		}
+
+		cols = append(cols, col)
+	}
+
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		g := &group{name: name, title: name}
+		for _, s := range samples[name] {
+			cols = append(cols, getSampleColumn(g, s))
+		}
+	}
+
+	return
+}
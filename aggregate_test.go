@@ -0,0 +1,90 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := httptest.NewServer(http.HandlerFunc(Window(ctx, WindowOpts{
+		Window:    time.Second,
+		Frequency: 10 * time.Millisecond,
+		Columns:   Columns{Include: []string{"goroutine"}},
+		Auth:      Auth{Token: "token"},
+	})))
+	defer a.Close()
+
+	b := httptest.NewServer(http.HandlerFunc(Window(ctx, WindowOpts{
+		Window:     time.Second,
+		Frequency:  10 * time.Millisecond,
+		Columns:    Columns{Include: []string{"HeapAlloc", "queue"}},
+		Collectors: []Collector{&queueCollector{}},
+	})))
+	defer b.Close()
+
+	time.Sleep(50 * time.Millisecond)
+
+	var errs []error
+	f := Aggregate(AggregateOpts{
+		Targets: []Target{
+			{Name: "a", URL: a.URL, Header: http.Header{"Authorization": []string{"Bearer token"}}},
+			{URL: b.URL},
+			{Name: "c", URL: a.URL},
+		},
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	})
+
+	w := httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?window=20ms", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-col="pprof.goroutine"`)
+	assert.Contains(t, w.Body.String(), `data-col="memstats.HeapAlloc"`)
+	assert.Contains(t, w.Body.String(), `data-col="queue.depth"`)
+	assert.NotContains(t, w.Body.String(), `data-group="memoryinfo"`)
+	assert.Contains(t, w.Body.String(), `<td class="tbl__col1">a</td>`)
+	assert.Contains(t, w.Body.String(), `<td class="tbl__col1">`+b.URL+`</td>`)
+	assert.Contains(t, w.Body.String(), "failed to scrape "+a.URL+": unexpected status code: 401")
+	assert.Len(t, errs, 1)
+
+	w = httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=json&cols=goroutine", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var is []instance
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&is))
+	require.Len(t, is, 3)
+	assert.NotEmpty(t, is[0].Records)
+	assert.NotEmpty(t, is[1].Records)
+	assert.NotEmpty(t, is[2].Error)
+
+	w = httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?window=x", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAggregateHostileTarget(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"time":"2021-10-01T12:00:00Z","samples":{"<svg onload=alert(1)>":[{"name":"<img src=x onerror=alert(1)>","value":1}]}}]`))
+	}))
+	defer target.Close()
+
+	w := httptest.NewRecorder()
+	Aggregate(AggregateOpts{Targets: []Target{{URL: target.URL}}})(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "<svg onload")
+	assert.NotContains(t, w.Body.String(), "<img")
+	assert.Contains(t, w.Body.String(), `data-col="&lt;svg onload=alert(1)&gt;.&lt;img src=x onerror=alert(1)&gt;"`)
+}
@@ -0,0 +1,367 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// AlertOpts configures alerting on recorded metrics.
+type AlertOpts struct {
+	// Rules define the conditions that fire alerts.
+	Rules []AlertRule
+	// Notifiers are notified when a rule fires and when it resolves.
+	Notifiers []Notifier
+}
+
+// AlertRule fires an alert when the value of a column, or its rate of change,
+// compared to a threshold holds for a given duration, e.g. goroutine > 10000 for 30s
+// or RSS rate per minute > 100MiB, when a column keeps increasing or when an expression holds.
+type AlertRule struct {
+	// Name identifies the rule in alerts.
+	Name string
+	// Expr fires the alert when the expression holds instead of comparing Column if set, so that rules can
+	// be read from config files, e.g. rate(HeapAlloc) > 50MiB/m && goroutine > 1000.
+	// Comparisons of a column or its rate(column) to a threshold are combined by &&, || and !
+	// and grouped by parentheses. Thresholds take the suffixes KiB, MiB, GiB and TiB for bytes or
+	// a duration suffix such as ms, thresholds of rates are given per second or per the duration after a slash.
+	Expr string
+	// Column names the column the rule applies to, as selected by Columns.
+	Column string
+	// Op compares the value to the threshold, one of >, >=, < or <=.
+	Op string
+	// Threshold is compared to the value, bytes are given in bytes and durations in nanoseconds.
+	Threshold float64
+	// Rate compares the change of the column per Rate instead of its value if set,
+	// e.g. time.Minute compares the change per minute.
+	Rate time.Duration
+	// Increasing fires the alert when the column increased with each of the last Increasing records
+	// instead of comparing it to the threshold if set.
+	Increasing int
+	// For defines how long the condition has to hold before the alert fires.
+	For time.Duration
+}
+
+const (
+	// AlertFiring is the status of an alert whose rule fired.
+	AlertFiring = "firing"
+	// AlertResolved is the status of an alert whose rule resolved.
+	AlertResolved = "resolved"
+)
+
+// Alert is sent to the notifiers when a rule fires or resolves.
+type Alert struct {
+	Rule   string `json:"rule"`
+	Status string `json:"status"`
+	// Column is the expression of rules defined by an Expr, their Value and Threshold are zero.
+	Column    string  `json:"column"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Values are the recent values of Column ordered from oldest to latest, rather than its rate of change,
+	// they are empty for rules defined by an Expr.
+	Values []float64 `json:"values,omitempty"`
+	Since  time.Time `json:"since"`
+	Time   time.Time `json:"time"`
+	// Records are the recent records ordered from oldest to latest, for notifiers that render them.
+	Records []Record `json:"-"`
+
+	unit unit
+}
+
+// alertValues is the number of recent values that are attached to alerts.
+const alertValues = sparklineWidth
+
+// Notifier is notified about alerts.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// WebhookNotifier POSTs alerts JSON-encoded to a URL.
+type WebhookNotifier struct {
+	// URL receives the alerts.
+	URL string
+	// Client sends the requests, defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+var defaultNotifierClient = &http.Client{Timeout: 10 * time.Second}
+
+// Notify POSTs the alert JSON-encoded to the URL.
+func (n WebhookNotifier) Notify(ctx context.Context, a Alert) (err error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return
+	}
+
+	return post(ctx, n.Client, n.URL, http.Header{"Content-Type": {"application/json"}}, b)
+}
+
+// TemplateNotifier POSTs alerts rendered by a template to a URL, so that systems such as PagerDuty
+// or Opsgenie can be integrated without dedicated notifiers.
+//
+//	tmpl := template.Must(template.New("").Funcs(pprofrec.NotifierFuncs).Parse(`{"summary": {{json .Rule}}}`))
+//	notifier := pprofrec.TemplateNotifier{URL: "https://example.com/alerts", Template: tmpl}
+type TemplateNotifier struct {
+	// URL receives the alerts.
+	URL string
+	// Template renders the body of the requests with the Alert.
+	Template *template.Template
+	// Header is sent with the requests, the Content-Type defaults to application/json.
+	Header http.Header
+	// Client sends the requests, defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// NotifierFuncs are functions for the templates of notifiers, json encodes a value as JSON.
+var NotifierFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+
+		return string(b), err
+	},
+}
+
+// Notify POSTs the alert rendered by the template to the URL.
+func (n TemplateNotifier) Notify(ctx context.Context, a Alert) (err error) {
+	if n.Template == nil {
+		return fmt.Errorf("missing template")
+	}
+
+	var b bytes.Buffer
+	err = n.Template.Execute(&b, a)
+	if err != nil {
+		return
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	for k, vs := range n.Header {
+		header[http.CanonicalHeaderKey(k)] = vs
+	}
+
+	return post(ctx, n.Client, n.URL, header, b.Bytes())
+}
+
+// post sends body with the given header to url and fails if the response status is not 2xx.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body []byte) (err error) {
+	if client == nil {
+		client = defaultNotifierClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+
+	// the body is drained so that the connection is reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	err = resp.Body.Close()
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %v", resp.StatusCode)
+	}
+
+	return
+}
+
+// alertState tracks whether the condition of a rule holds.
+type alertState struct {
+	rule      AlertRule
+	col       column
+	expr      alertExpr
+	since     time.Time
+	firing    bool
+	increases int
+}
+
+// alerter evaluates alert rules against consecutive records.
+type alerter struct {
+	states []*alertState
+}
+
+// newAlerter resolves the columns of the rules, rules with unknown columns, operators or invalid expressions
+// are reported to onError and skipped.
+func newAlerter(onError func(error), rules []AlertRule, cols []column) *alerter {
+	a := &alerter{}
+
+	for _, rule := range rules {
+		if rule.Expr != "" {
+			expr, err := parseAlertExpr(rule.Expr)
+			if err == nil {
+				err = expr.resolve(cols)
+			}
+			if err != nil {
+				reportError(onError, fmt.Errorf("skipping alert rule %v: %w", rule.Name, err))
+
+				continue
+			}
+
+			a.states = append(a.states, &alertState{rule: rule, expr: expr})
+
+			continue
+		}
+
+		if rule.Increasing == 0 && !isAlertOp(rule.Op) {
+			reportError(onError, fmt.Errorf("skipping alert rule %v: unknown op: %v", rule.Name, rule.Op))
+
+			continue
+		}
+
+		col, ok := findColumn(cols, rule.Column)
+		if !ok {
+			reportError(onError, fmt.Errorf("skipping alert rule %v: unknown column: %v", rule.Name, rule.Column))
+
+			continue
+		}
+
+		a.states = append(a.states, &alertState{rule: rule, col: col})
+	}
+
+	return a
+}
+
+// isAlertOp reports whether op is an operator of AlertRule.
+func isAlertOp(op string) bool {
+	switch op {
+	case ">", ">=", "<", "<=":
+		return true
+	default:
+		return false
+	}
+}
+
+// evaluate returns the alerts that fired or resolved with the current record.
+func (a *alerter) evaluate(previous Record, current Record) (alerts []Alert) {
+	for _, s := range a.states {
+		v, holds, ok := s.holds(previous, current)
+		if !ok {
+			continue
+		}
+
+		if !holds {
+			if s.firing {
+				alerts = append(alerts, s.alert(AlertResolved, v, current.Time))
+			}
+
+			s.since = time.Time{}
+			s.firing = false
+
+			continue
+		}
+
+		if s.since.IsZero() {
+			s.since = current.Time
+		}
+
+		if !s.firing && current.Time.Sub(s.since) >= s.rule.For {
+			s.firing = true
+			alerts = append(alerts, s.alert(AlertFiring, v, current.Time))
+		}
+	}
+
+	return
+}
+
+// holds reports whether the condition of the rule holds for the current record,
+// ok is false if it cannot be evaluated without a previous record.
+func (s *alertState) holds(previous Record, current Record) (v float64, holds bool, ok bool) {
+	if s.expr != nil {
+		holds, ok = s.expr.eval(previous, current)
+
+		return
+	}
+
+	v = s.col.value(current)
+
+	if s.rule.Increasing > 0 {
+		if !previous.Time.IsZero() && v > s.col.value(previous) {
+			s.increases++
+		} else {
+			s.increases = 0
+		}
+
+		return v, s.increases >= s.rule.Increasing, true
+	}
+
+	if s.rule.Rate > 0 {
+		v, ok = getColumnRate(s.col, previous, current, s.rule.Rate)
+		if !ok {
+			return
+		}
+	}
+
+	return v, compare(v, s.rule.Op, s.rule.Threshold), true
+}
+
+// getColumnRate returns the change of col between previous and current per rate,
+// ok is false if previous is unset or not older than current.
+func getColumnRate(col column, previous Record, current Record, rate time.Duration) (v float64, ok bool) {
+	dt := current.Time.Sub(previous.Time)
+	if previous.Time.IsZero() || dt <= 0 {
+		return
+	}
+
+	return (col.value(current) - col.value(previous)) / float64(dt) * float64(rate), true
+}
+
+func (s *alertState) alert(status string, v float64, ts time.Time) Alert {
+	column := s.rule.Expr
+	if s.expr == nil {
+		column = s.col.qualifiedName()
+	}
+
+	return Alert{
+		Rule:      s.rule.Name,
+		Status:    status,
+		Column:    column,
+		Value:     v,
+		Threshold: s.rule.Threshold,
+		Since:     s.since,
+		Time:      ts,
+		unit:      s.col.unit,
+	}
+}
+
+func compare(v float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return v > threshold
+	case ">=":
+		return v >= threshold
+	case "<":
+		return v < threshold
+	case "<=":
+		return v <= threshold
+	default:
+		return false
+	}
+}
+
+// notify sends the alerts to the notifiers until alerts is closed.
+func notify(notifiers []Notifier, alerts <-chan Alert, onError func(error)) {
+	for a := range alerts {
+		for _, n := range notifiers {
+			err := n.Notify(context.Background(), a)
+			if err != nil {
+				reportError(onError, fmt.Errorf("failed to notify about alert %v: %w", a.Rule, err))
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlerterEvaluate(t *testing.T) {
+	var errs []error
+	a := newAlerter(func(err error) { errs = append(errs, err) }, []AlertRule{
+		{Name: "goroutines", Column: "goroutine", Op: ">", Threshold: 5, For: time.Second},
+		{Name: "heap growth", Column: "memstats.HeapAlloc", Op: ">", Threshold: 100, Rate: time.Minute},
+		{Name: "unknown", Column: "unknown", Op: ">"},
+		{Name: "invalid", Column: "goroutine", Op: "!="},
+	}, getColumns(capabilities{}))
+	require.Len(t, a.states, 2)
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "skipping alert rule unknown: unknown column: unknown")
+	assert.EqualError(t, errs[1], "skipping alert rule invalid: unknown op: !=")
+
+	ts := time.Now()
+	record := func(offset time.Duration, goroutines int, heapAlloc uint64) Record {
+		return Record{Time: ts.Add(offset), Pprof: PprofStat{Goroutine: goroutines}, MemStats: MemStats{HeapAlloc: heapAlloc}}
+	}
+
+	r0 := record(0, 10, 0)
+	assert.Empty(t, a.evaluate(Record{}, r0))
+
+	r1 := record(500*time.Millisecond, 10, 0)
+	assert.Empty(t, a.evaluate(r0, r1))
+
+	r2 := record(time.Second, 10, 10)
+	alerts := a.evaluate(r1, r2)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, Alert{Rule: "goroutines", Status: AlertFiring, Column: "pprof.Lookup.goroutine", Value: 10, Threshold: 5, Since: r0.Time, Time: r2.Time}, alerts[0])
+	assert.Equal(t, "heap growth", alerts[1].Rule)
+	assert.Equal(t, AlertFiring, alerts[1].Status)
+	assert.InDelta(t, 1200, alerts[1].Value, 0.001)
+
+	r3 := record(2*time.Second, 1, 10)
+	alerts = a.evaluate(r2, r3)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, AlertResolved, alerts[0].Status)
+	assert.Equal(t, AlertResolved, alerts[1].Status)
+
+	assert.Empty(t, a.evaluate(r3, record(3*time.Second, 1, 10)))
+}
+
+func TestAlerterEvaluateIncreasing(t *testing.T) {
+	a := newAlerter(nil, []AlertRule{{Name: "leak", Column: "goroutine", Increasing: 2}}, getColumns(capabilities{}))
+	require.Len(t, a.states, 1)
+
+	ts := time.Now()
+	var previous Record
+	var fired []string
+	for i, n := range []int{1, 2, 3, 3, 4} {
+		current := Record{Time: ts.Add(time.Duration(i) * time.Second), Pprof: PprofStat{Goroutine: n}}
+		for _, alert := range a.evaluate(previous, current) {
+			fired = append(fired, alert.Status)
+		}
+		previous = current
+	}
+
+	assert.Equal(t, []string{AlertFiring, AlertResolved}, fired)
+}
+
+func TestRecorderAlertWebhook(t *testing.T) {
+	alerts := make(chan Alert, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		err := json.NewDecoder(r.Body).Decode(&a)
+		assert.NoError(t, err)
+
+		alerts <- a
+	}))
+	defer srv.Close()
+
+	rec := newTestRecorder(t, RecorderOpts{
+		Frequency: 50 * time.Millisecond,
+		Alerts: AlertOpts{
+			Rules:     []AlertRule{{Name: "goroutines", Column: "goroutine", Op: ">", Threshold: 0}},
+			Notifiers: []Notifier{WebhookNotifier{URL: srv.URL}},
+		},
+	})
+	rec.Start(context.Background())
+	defer rec.Stop()
+
+	select {
+	case a := <-alerts:
+		assert.Equal(t, "goroutines", a.Rule)
+		assert.Equal(t, AlertFiring, a.Status)
+		assert.NotEmpty(t, a.Values)
+		assert.Empty(t, a.Records)
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestAlerterEvaluateExpr(t *testing.T) {
+	var errs []error
+	a := newAlerter(func(err error) { errs = append(errs, err) }, []AlertRule{
+		{Name: "heap", Expr: "rate(HeapAlloc) > 1KiB/s && goroutine > 5"},
+		{Name: "invalid", Expr: "goroutine >"},
+		{Name: "unknown", Expr: "unknown > 5"},
+	}, getColumns(capabilities{}))
+	require.Len(t, a.states, 1)
+	assert.Len(t, errs, 2)
+
+	ts := time.Now()
+	r0 := Record{Time: ts, Pprof: PprofStat{Goroutine: 10}}
+	assert.Empty(t, a.evaluate(Record{}, r0))
+
+	r1 := Record{Time: ts.Add(time.Second), Pprof: PprofStat{Goroutine: 10}, MemStats: MemStats{HeapAlloc: 2 << 10}}
+	alerts := a.evaluate(r0, r1)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, Alert{Rule: "heap", Status: AlertFiring, Column: "rate(HeapAlloc) > 1KiB/s && goroutine > 5", Since: r1.Time, Time: r1.Time}, alerts[0])
+
+	r2 := Record{Time: ts.Add(2 * time.Second), Pprof: PprofStat{Goroutine: 10}, MemStats: MemStats{HeapAlloc: 2 << 10}}
+	alerts = a.evaluate(r1, r2)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, AlertResolved, alerts[0].Status)
+}
+
+func TestTemplateNotifier(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   string
+	}
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		requests <- request{header: r.Header, body: string(b)}
+	}))
+	defer srv.Close()
+
+	tmpl := template.Must(template.New("").Funcs(NotifierFuncs).Parse(
+		`{"summary": {{json .Rule}}, "status": "{{.Status}}", "records": {{len .Records}}, "goroutines": {{(index .Records 1).Pprof.Goroutine}}}`,
+	))
+	n := TemplateNotifier{URL: srv.URL, Template: tmpl, Header: http.Header{"authorization": {"Token secret"}}}
+
+	err := n.Notify(context.Background(), Alert{
+		Rule:    `"heap"`,
+		Status:  AlertFiring,
+		Records: []Record{{Pprof: PprofStat{Goroutine: 1}}, {Pprof: PprofStat{Goroutine: 2}}},
+	})
+	require.NoError(t, err)
+
+	r := <-requests
+	assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+	assert.Equal(t, "Token secret", r.header.Get("Authorization"))
+	assert.Equal(t, `{"summary": "\"heap\"", "status": "firing", "records": 2, "goroutines": 2}`, r.body)
+
+	err = TemplateNotifier{URL: srv.URL}.Notify(context.Background(), Alert{})
+	assert.EqualError(t, err, "missing template")
+}
+
+// trackingBody records how much of it was read before it was closed.
+type trackingBody struct {
+	r          io.Reader
+	read       int
+	readClosed int
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += n
+
+	return n, err
+}
+
+func (b *trackingBody) Close() error {
+	b.readClosed = b.read
+
+	return nil
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestPostDrainsBody(t *testing.T) {
+	var bodies []*trackingBody
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		b := &trackingBody{r: strings.NewReader(strings.Repeat("x", 1<<17))}
+		bodies = append(bodies, b)
+
+		return &http.Response{StatusCode: http.StatusOK, Body: b}, nil
+	})}
+
+	require.NoError(t, post(context.Background(), client, "http://localhost", http.Header{}, nil))
+	require.Len(t, bodies, 1)
+	assert.Equal(t, 1<<16, bodies[0].readClosed)
+}
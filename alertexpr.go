@@ -0,0 +1,327 @@
+package pprofrec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertExpr is a parsed AlertRule.Expr, e.g. rate(HeapAlloc) > 50MiB/m && goroutine > 1000.
+type alertExpr interface {
+	// resolve looks up the columns of the comparisons.
+	resolve(cols []column) error
+	// eval reports whether the expression holds for the current record,
+	// ok is false if it cannot be evaluated without a previous record.
+	eval(previous Record, current Record) (holds bool, ok bool)
+}
+
+type alertAnd struct {
+	l alertExpr
+	r alertExpr
+}
+
+func (e alertAnd) resolve(cols []column) (err error) {
+	err = e.l.resolve(cols)
+	if err != nil {
+		return
+	}
+
+	return e.r.resolve(cols)
+}
+
+func (e alertAnd) eval(previous Record, current Record) (holds bool, ok bool) {
+	l, lok := e.l.eval(previous, current)
+	r, rok := e.r.eval(previous, current)
+
+	return l && r, lok && rok
+}
+
+type alertOr struct {
+	l alertExpr
+	r alertExpr
+}
+
+func (e alertOr) resolve(cols []column) (err error) {
+	err = e.l.resolve(cols)
+	if err != nil {
+		return
+	}
+
+	return e.r.resolve(cols)
+}
+
+func (e alertOr) eval(previous Record, current Record) (holds bool, ok bool) {
+	l, lok := e.l.eval(previous, current)
+	r, rok := e.r.eval(previous, current)
+
+	return l || r, lok && rok
+}
+
+type alertNot struct {
+	e alertExpr
+}
+
+func (e alertNot) resolve(cols []column) error {
+	return e.e.resolve(cols)
+}
+
+func (e alertNot) eval(previous Record, current Record) (holds bool, ok bool) {
+	holds, ok = e.e.eval(previous, current)
+
+	return !holds, ok
+}
+
+// alertComparison compares the value of a column, or its change per rate, to a threshold.
+type alertComparison struct {
+	column    string
+	col       column
+	rate      time.Duration
+	op        string
+	threshold float64
+}
+
+func (e *alertComparison) resolve(cols []column) error {
+	col, ok := findColumn(cols, e.column)
+	if !ok {
+		return fmt.Errorf("unknown column: %v", e.column)
+	}
+
+	e.col = col
+
+	return nil
+}
+
+func (e *alertComparison) eval(previous Record, current Record) (holds bool, ok bool) {
+	v := e.col.value(current)
+
+	if e.rate > 0 {
+		v, ok = getColumnRate(e.col, previous, current, e.rate)
+		if !ok {
+			return
+		}
+	}
+
+	return compare(v, e.op, e.threshold), true
+}
+
+// parseAlertExpr parses an alert rule expression. Comparisons of a column or its rate(column) to a threshold
+// are combined by &&, || and ! and grouped by parentheses, && binds stronger than ||.
+// Thresholds take the byte suffixes KiB, MiB, GiB and TiB or a duration suffix such as ms,
+// thresholds of rates are given per second or per the duration that follows a slash, e.g. 50MiB/m.
+func parseAlertExpr(s string) (e alertExpr, err error) {
+	p := &alertExprParser{s: s}
+
+	e, err = p.parseOr()
+	if err != nil {
+		return
+	}
+
+	p.skipSpaces()
+	if p.i < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at offset %v", p.s[p.i:], p.i)
+	}
+
+	return
+}
+
+type alertExprParser struct {
+	s string
+	i int
+}
+
+func (p *alertExprParser) skipSpaces() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t' || p.s[p.i] == '\n') {
+		p.i++
+	}
+}
+
+// consume skips the spaces and the token if it follows them.
+func (p *alertExprParser) consume(token string) bool {
+	p.skipSpaces()
+
+	if !strings.HasPrefix(p.s[p.i:], token) {
+		return false
+	}
+
+	p.i += len(token)
+
+	return true
+}
+
+// word returns the characters up to the next space, operator or parenthesis.
+func (p *alertExprParser) word() string {
+	p.skipSpaces()
+
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(" \t\n()<>=!&|", rune(p.s[p.i])) {
+		p.i++
+	}
+
+	return p.s[start:p.i]
+}
+
+func (p *alertExprParser) parseOr() (e alertExpr, err error) {
+	e, err = p.parseAnd()
+	if err != nil {
+		return
+	}
+
+	for p.consume("||") {
+		var r alertExpr
+		r, err = p.parseAnd()
+		if err != nil {
+			return
+		}
+
+		e = alertOr{l: e, r: r}
+	}
+
+	return
+}
+
+func (p *alertExprParser) parseAnd() (e alertExpr, err error) {
+	e, err = p.parseUnary()
+	if err != nil {
+		return
+	}
+
+	for p.consume("&&") {
+		var r alertExpr
+		r, err = p.parseUnary()
+		if err != nil {
+			return
+		}
+
+		e = alertAnd{l: e, r: r}
+	}
+
+	return
+}
+
+func (p *alertExprParser) parseUnary() (e alertExpr, err error) {
+	if p.consume("!") {
+		e, err = p.parseUnary()
+		if err != nil {
+			return
+		}
+
+		return alertNot{e: e}, nil
+	}
+
+	if p.consume("(") {
+		e, err = p.parseOr()
+		if err != nil {
+			return
+		}
+
+		if !p.consume(")") {
+			return nil, fmt.Errorf("missing ) at offset %v", p.i)
+		}
+
+		return
+	}
+
+	return p.parseComparison()
+}
+
+func (p *alertExprParser) parseComparison() (e alertExpr, err error) {
+	c := &alertComparison{}
+
+	c.column = p.word()
+	if c.column == "rate" && p.consume("(") {
+		c.column = p.word()
+		if !p.consume(")") {
+			return nil, fmt.Errorf("missing ) at offset %v", p.i)
+		}
+
+		c.rate = time.Second
+	}
+	if c.column == "" {
+		return nil, fmt.Errorf("missing column at offset %v", p.i)
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if p.consume(op) {
+			c.op = op
+
+			break
+		}
+	}
+	if c.op == "" {
+		return nil, fmt.Errorf("missing operator after %v at offset %v", c.column, p.i)
+	}
+
+	threshold := p.word()
+	if threshold == "" {
+		return nil, fmt.Errorf("missing threshold after %v at offset %v", c.op, p.i)
+	}
+
+	var per time.Duration
+	c.threshold, per, err = parseAlertThreshold(threshold)
+	if err != nil {
+		return
+	}
+
+	if per > 0 {
+		if c.rate == 0 {
+			return nil, fmt.Errorf("threshold %v is a rate but %v is not: use rate(%v)", threshold, c.column, c.column)
+		}
+
+		c.rate = per
+	}
+
+	return c, nil
+}
+
+var alertByteSuffixes = map[string]float64{
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// parseAlertThreshold parses a threshold such as 1000, 512MiB, 100ms or 50MiB/m,
+// per is the duration after the slash, a missing amount defaults to one, e.g. /m is per minute.
+func parseAlertThreshold(s string) (v float64, per time.Duration, err error) {
+	value := s
+	if i := strings.Index(s, "/"); i >= 0 {
+		value = s[:i]
+
+		unit := s[i+1:]
+		if unit != "" && (unit[0] < '0' || unit[0] > '9') {
+			unit = "1" + unit
+		}
+
+		per, err = time.ParseDuration(unit)
+		if err != nil || per <= 0 {
+			return 0, 0, fmt.Errorf("invalid rate unit: %v", s[i+1:])
+		}
+	}
+
+	n := 0
+	for n < len(value) && (value[n] == '-' || value[n] == '.' || (value[n] >= '0' && value[n] <= '9')) {
+		n++
+	}
+
+	v, err = strconv.ParseFloat(value[:n], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid threshold: %v", s)
+	}
+
+	suffix := value[n:]
+	if suffix == "" {
+		return
+	}
+
+	if m, ok := alertByteSuffixes[suffix]; ok {
+		return v * m, per, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid threshold: %v", s)
+	}
+
+	return float64(d), per, nil
+}
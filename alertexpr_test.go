@@ -0,0 +1,106 @@
+package pprofrec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAlertThreshold(t *testing.T) {
+	tcs := []struct {
+		s   string
+		v   float64
+		per time.Duration
+		err string
+	}{
+		{s: "1000", v: 1000},
+		{s: "0.5", v: 0.5},
+		{s: "-2", v: -2},
+		{s: "512MiB", v: 512 << 20},
+		{s: "100ms", v: float64(100 * time.Millisecond)},
+		{s: "50MiB/m", v: 50 << 20, per: time.Minute},
+		{s: "10/5s", v: 10, per: 5 * time.Second},
+		{s: "10MB", err: "invalid threshold: 10MB"},
+		{s: "MiB", err: "invalid threshold: MiB"},
+		{s: "10/x", err: "invalid rate unit: x"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.s, func(t *testing.T) {
+			v, per, err := parseAlertThreshold(tc.s)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.v, v)
+			assert.Equal(t, tc.per, per)
+		})
+	}
+}
+
+func TestParseAlertExpr(t *testing.T) {
+	tcs := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "goroutine > 1000"},
+		{expr: "rate(HeapAlloc) > 50MiB/m && goroutine > 1000"},
+		{expr: "!(goroutine<=10 || memstats.HeapAlloc >= 1GiB)"},
+		{expr: "goroutine", err: "missing operator after goroutine at offset 9"},
+		{expr: "goroutine >", err: "missing threshold after > at offset 11"},
+		{expr: "> 10", err: "missing column at offset 0"},
+		{expr: "(goroutine > 10", err: "missing ) at offset 15"},
+		{expr: "goroutine > 10 goroutine", err: `unexpected "goroutine" at offset 15`},
+		{expr: "HeapAlloc > 50MiB/m", err: "threshold 50MiB/m is a rate but HeapAlloc is not: use rate(HeapAlloc)"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := parseAlertExpr(tc.expr)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAlertExprEval(t *testing.T) {
+	e, err := parseAlertExpr("rate(HeapAlloc) > 50MiB/m && goroutine > 1000 || !(goroutine > 0)")
+	require.NoError(t, err)
+	require.NoError(t, e.resolve(getColumns(capabilities{})))
+
+	ts := time.Now()
+	record := func(offset time.Duration, goroutines int, heapAlloc uint64) Record {
+		return Record{Time: ts.Add(offset), Pprof: PprofStat{Goroutine: goroutines}, MemStats: MemStats{HeapAlloc: heapAlloc}}
+	}
+
+	r0 := record(0, 2000, 0)
+	_, ok := e.eval(Record{}, r0)
+	assert.False(t, ok)
+
+	holds, ok := e.eval(r0, record(time.Second, 2000, 1<<20))
+	assert.True(t, ok)
+	assert.True(t, holds)
+
+	holds, _ = e.eval(r0, record(time.Second, 10, 1<<20))
+	assert.False(t, holds)
+
+	holds, _ = e.eval(r0, record(time.Minute, 2000, 1<<20))
+	assert.False(t, holds)
+
+	holds, _ = e.eval(r0, record(time.Second, 0, 0))
+	assert.True(t, holds)
+
+	e, err = parseAlertExpr("unknown > 1")
+	require.NoError(t, err)
+	assert.EqualError(t, e.resolve(getColumns(capabilities{})), "unknown column: unknown")
+}
@@ -0,0 +1,130 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Annotation marks an event like a deploy or a load test.
+type Annotation struct {
+	Time  time.Time `json:"time"`
+	Label string    `json:"label"`
+}
+
+// annotationStore holds the annotations within a window.
+// It is safe for concurrent use.
+type annotationStore struct {
+	mu     sync.RWMutex
+	window time.Duration
+	as     []Annotation
+}
+
+func newAnnotationStore(window time.Duration) *annotationStore {
+	return &annotationStore{window: window}
+}
+
+// add stores a and drops the annotations that are older than the window.
+func (s *annotationStore) add(a Annotation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.as = append(s.as, a)
+
+	from := a.Time.Add(-s.window)
+	for len(s.as) > 0 && s.as[0].Time.Before(from) {
+		s.as = s.as[1:]
+	}
+}
+
+// list returns a copy of the annotations, ordered from oldest to latest.
+func (s *annotationStore) list() []Annotation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Annotation(nil), s.as...)
+}
+
+// between returns the annotations after after until until.
+func (s *annotationStore) between(after time.Time, until time.Time) (as []Annotation) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, a := range s.as {
+		if a.Time.After(after) && !a.Time.After(until) {
+			as = append(as, a)
+		}
+	}
+
+	return
+}
+
+// writeAnnotations writes a marker row for each annotation that spans the columns of the table,
+// from latest to oldest if the rows are rendered newest first.
+func writeAnnotations(w io.Writer, cols []column, as []Annotation, d display) (err error) {
+	for i := range as {
+		a := as[i]
+		if d.newestFirst {
+			a = as[len(as)-1-i]
+		}
+
+		err = writeMarker(w, cols, "tbl__row--annotation", string(d.appendTime(nil, a.Time)), a.Label, d)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// writeMarker writes a row of class that spans all columns, rows of class tbl__row--annotation
+// are exempt from hiding columns.
+func writeMarker(w io.Writer, cols []column, class string, head string, text string, d display) (err error) {
+	_, err = fmt.Fprintf(w, `<tr class="%s"><td class="tbl__col1">%s</td><td colspan="%d">%s</td></tr>`,
+		class, html.EscapeString(head), d.cells()*len(cols), html.EscapeString(text))
+
+	return
+}
+
+// Annotate records an annotation with the label given by ?label= or the label form value of a POST request,
+// the annotation is rendered as a marker row by the Window handler and included in the next record.
+// Annotations are only accepted via POST and, like Tune, not from pages of other origins.
+func Annotate(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		if !isSameOrigin(r) {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+
+			return
+		}
+
+		label := r.FormValue("label")
+		if label == "" {
+			http.Error(w, "missing label", http.StatusBadRequest)
+
+			return
+		}
+
+		a := rec.Annotate(label)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		err := json.NewEncoder(w).Encode(a)
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
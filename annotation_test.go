@@ -0,0 +1,133 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationStore(t *testing.T) {
+	s := newAnnotationStore(time.Minute)
+	ts := time.Now()
+
+	s.add(Annotation{Time: ts, Label: "deploy"})
+	s.add(Annotation{Time: ts.Add(30 * time.Second), Label: "load test"})
+	assert.Len(t, s.list(), 2)
+
+	assert.Equal(t, []Annotation{{Time: ts, Label: "deploy"}}, s.between(ts.Add(-time.Second), ts))
+	assert.Empty(t, s.between(ts, ts.Add(time.Second)))
+
+	s.add(Annotation{Time: ts.Add(90 * time.Second), Label: "rollback"})
+	assert.Equal(t, []string{"load test", "rollback"}, []string{s.list()[0].Label, s.list()[1].Label})
+}
+
+func TestRecorderAnnotate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Second, Frequency: 20 * time.Millisecond, Columns: Columns{Include: []string{"goroutine"}}})
+	h := Window(ctx, WindowOpts{Recorder: rec})
+
+	time.Sleep(50 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Annotate(rec)(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/annotate?label=deploy+<v2>", http.NoBody))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var a Annotation
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&a))
+	assert.Equal(t, "deploy <v2>", a.Label)
+	as := rec.Annotations()
+	require.Len(t, as, 1)
+	assert.True(t, a.Time.Equal(as[0].Time))
+
+	time.Sleep(50 * time.Millisecond)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `<tr class="tbl__row--annotation">`))
+	assert.Contains(t, w.Body.String(), `<td colspan="2">deploy &lt;v2&gt;</td>`)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?format=csv", http.NoBody))
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+
+	var labels []string
+	for _, row := range rows[1:] {
+		if row[2] != "" {
+			labels = append(labels, row[2])
+		}
+	}
+	assert.Equal(t, []string{"deploy <v2>"}, labels)
+}
+
+func TestAnnotateMethod(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{})
+
+	w := httptest.NewRecorder()
+	Annotate(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/annotate?label=deploy", http.NoBody))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	Annotate(rec)(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/annotate", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, rec.Annotations())
+}
+
+func TestAnnotateCrossOrigin(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{})
+	h := Annotate(rec)
+
+	for _, header := range []map[string]string{
+		{"Sec-Fetch-Site": "cross-site"},
+		{"Origin": "http://evil.example"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/debug/pprof/annotate", strings.NewReader("label=deploy"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		h(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Code, header)
+	}
+	assert.Empty(t, rec.Annotations())
+
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/debug/pprof/annotate", strings.NewReader("label=deploy"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Sec-Fetch-Site", "same-origin")
+
+	w := httptest.NewRecorder()
+	h(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, rec.Annotations(), 1)
+}
+
+func TestStreamAnnotations(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{})
+	f := Stream(StreamOpts{Frequency: 100 * time.Millisecond, Recorder: rec})
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost:8080?window=250ms", http.NoBody)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		rec.Annotate("load test")
+	}()
+
+	w := &responseWriter{}
+	f(w, r)
+
+	assert.Contains(t, w.Buffer.String(), "load test</td></tr>")
+}
@@ -0,0 +1,83 @@
+package pprofrec
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Auth restricts access to the handlers, access is granted if any of the configured checks passes
+// and to everyone if none is configured.
+type Auth struct {
+	// Username and Password grant access via basic auth if set.
+	Username string
+	Password string
+	// Token grants access via an Authorization: Bearer <token> header if set.
+	Token string
+	// Allow grants access if it returns true, e.g. to check a session cookie or the remote address.
+	Allow func(r *http.Request) bool
+}
+
+// enabled reports whether any check is configured.
+func (a Auth) enabled() bool {
+	return a.Username != "" || a.Password != "" || a.Token != "" || a.Allow != nil
+}
+
+// allows reports whether r passes any of the configured checks.
+func (a Auth) allows(r *http.Request) bool {
+	if !a.enabled() {
+		return true
+	}
+
+	if a.Username != "" || a.Password != "" {
+		username, password, ok := r.BasicAuth()
+		if ok && equal(username, a.Username) && equal(password, a.Password) {
+			return true
+		}
+	}
+
+	if a.Token != "" {
+		h := r.Header.Get("Authorization")
+		if len(h) > len("Bearer ") && strings.EqualFold(h[:len("Bearer ")], "Bearer ") && equal(h[len("Bearer "):], a.Token) {
+			return true
+		}
+	}
+
+	if a.Allow != nil && a.Allow(r) {
+		return true
+	}
+
+	return false
+}
+
+// authorize responds with 401 and returns false if r is not allowed access.
+func (a Auth) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if a.allows(r) {
+		return true
+	}
+
+	if a.Username != "" || a.Password != "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="pprofrec", charset="UTF-8"`)
+	} else if a.Token != "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="pprofrec"`)
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+	return false
+}
+
+// Wrap restricts access to h, e.g. to the Health, Charts or Annotate handlers.
+func (a Auth) Wrap(h func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorize(w, r) {
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// equal compares a and b in constant time.
+func equal(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,75 @@
+package pprofrec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthAllows(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	assert.True(t, Auth{}.allows(r))
+
+	basic := Auth{Username: "admin", Password: "secret"}
+	assert.False(t, basic.allows(r))
+
+	r.SetBasicAuth("admin", "wrong")
+	assert.False(t, basic.allows(r))
+
+	r.SetBasicAuth("admin", "secret")
+	assert.True(t, basic.allows(r))
+
+	bearer := Auth{Token: "token"}
+	r = httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("Authorization", "Bearer token")
+	assert.True(t, bearer.allows(r))
+
+	r.Header.Set("Authorization", "Bearer other")
+	assert.False(t, bearer.allows(r))
+
+	r.Header.Set("Authorization", "Bearer ")
+	assert.False(t, bearer.allows(r))
+
+	hook := Auth{Token: "token", Allow: func(r *http.Request) bool { return r.URL.Query().Get("key") == "ok" }}
+	assert.True(t, hook.allows(httptest.NewRequest(http.MethodGet, "/?key=ok", http.NoBody)))
+	assert.False(t, hook.allows(httptest.NewRequest(http.MethodGet, "/?key=no", http.NoBody)))
+}
+
+func TestWindowAuth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := Window(ctx, WindowOpts{Window: time.Second, Frequency: 10 * time.Millisecond, Auth: Auth{Username: "admin", Password: "secret"}})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="pprofrec", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody)
+	r.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	h(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAuthWrap(t *testing.T) {
+	h := Auth{Token: "token"}.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="pprofrec"`, w.Header().Get("WWW-Authenticate"))
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("Authorization", "bearer token")
+	w = httptest.NewRecorder()
+	h(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -0,0 +1,201 @@
+package pprofrec
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// CgroupCollector collects the memory and CPU limits of the cgroup of the process alongside derived metrics,
+// as absolute numbers are meaningless in containers without their limits. cgroup v1 and v2 are supported.
+// The metrics are recorded as the memory limit and usage of the cgroup, the RSS of the process in percent
+// of the memory limit, the CPU limit in cores and the number of CPU periods, throttled periods and throttled time.
+// Limits are zero if unlimited and all metrics are zero outside of Linux.
+type CgroupCollector struct{}
+
+// Name returns cgroup.
+func (c CgroupCollector) Name() string {
+	return "cgroup"
+}
+
+// Collect returns the current limits and usage of the cgroup.
+func (c CgroupCollector) Collect(ctx context.Context) []Sample {
+	s := readCgroup("/sys/fs/cgroup", "/proc/self/cgroup")
+
+	var rss float64
+	p, err := process.NewProcessWithContext(ctx, int32(os.Getpid()))
+	if err == nil {
+		mi, err := p.MemoryInfoWithContext(ctx)
+		if err == nil {
+			rss = float64(mi.RSS)
+		}
+	}
+
+	var rssOfLimit float64
+	if s.memoryLimit > 0 {
+		rssOfLimit = 100 * rss / s.memoryLimit
+	}
+
+	return []Sample{
+		{Name: "memory_limit", Value: s.memoryLimit, Unit: UnitBytes},
+		{Name: "memory_usage", Value: s.memoryUsage, Unit: UnitBytes},
+		{Name: "rss_of_limit", Value: rssOfLimit, Unit: UnitPercent},
+		{Name: "cpu_limit", Value: s.cpuLimit},
+		{Name: "periods", Value: s.periods},
+		{Name: "throttled_periods", Value: s.throttledPeriods},
+		{Name: "throttled", Value: s.throttled, Unit: UnitSeconds},
+	}
+}
+
+// cgroupStats holds the limits and usage of a cgroup.
+type cgroupStats struct {
+	memoryLimit      float64
+	memoryUsage      float64
+	cpuLimit         float64
+	periods          float64
+	throttledPeriods float64
+	throttled        float64
+}
+
+// cgroupUnlimited is the threshold above which cgroup v1 memory limits are considered unlimited,
+// as they are reported as the maximum page aligned int64.
+const cgroupUnlimited = 1 << 62
+
+// readCgroup reads the stats of the cgroup that procCgroup, e.g. /proc/self/cgroup, refers to
+// from the cgroup filesystem mounted at root.
+func readCgroup(root string, procCgroup string) (s cgroupStats) {
+	paths := readCgroupPaths(procCgroup)
+
+	// cgroup v2 mounts a single hierarchy with a cgroup.controllers file at its root
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	if err == nil {
+		dir := getCgroupDir(root, paths[""])
+
+		limit := readCgroupFile(dir, "memory.max")
+		if limit != "max" {
+			s.memoryLimit = parseCgroupValue(limit)
+		}
+		s.memoryUsage = parseCgroupValue(readCgroupFile(dir, "memory.current"))
+
+		fs := strings.Fields(readCgroupFile(dir, "cpu.max"))
+		if len(fs) == 2 && fs[0] != "max" {
+			s.cpuLimit = getCPULimit(parseCgroupValue(fs[0]), parseCgroupValue(fs[1]))
+		}
+
+		stat := readCgroupStat(dir, "cpu.stat")
+		s.periods = stat["nr_periods"]
+		s.throttledPeriods = stat["nr_throttled"]
+		s.throttled = stat["throttled_usec"] / 1e6
+
+		return
+	}
+
+	memDir := getCgroupDir(filepath.Join(root, "memory"), paths["memory"])
+
+	limit := parseCgroupValue(readCgroupFile(memDir, "memory.limit_in_bytes"))
+	if limit < cgroupUnlimited {
+		s.memoryLimit = limit
+	}
+	s.memoryUsage = parseCgroupValue(readCgroupFile(memDir, "memory.usage_in_bytes"))
+
+	cpuDir := getCgroupDir(filepath.Join(root, "cpu"), paths["cpu"])
+
+	quota := parseCgroupValue(readCgroupFile(cpuDir, "cpu.cfs_quota_us"))
+	period := parseCgroupValue(readCgroupFile(cpuDir, "cpu.cfs_period_us"))
+	if quota > 0 {
+		s.cpuLimit = getCPULimit(quota, period)
+	}
+
+	stat := readCgroupStat(cpuDir, "cpu.stat")
+	s.periods = stat["nr_periods"]
+	s.throttledPeriods = stat["nr_throttled"]
+	s.throttled = stat["throttled_time"] / 1e9
+
+	return
+}
+
+// readCgroupPaths maps the controllers listed in procCgroup to the path of their cgroup,
+// the unified cgroup v2 hierarchy is mapped by the empty controller.
+func readCgroupPaths(procCgroup string) map[string]string {
+	paths := map[string]string{}
+
+	f, err := os.Open(procCgroup)
+	if err != nil {
+		return paths
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// lines are formatted as hierarchy-ID:controller-list:cgroup-path
+		fs := strings.SplitN(s.Text(), ":", 3)
+		if len(fs) != 3 {
+			continue
+		}
+
+		for _, c := range strings.Split(fs[1], ",") {
+			paths[c] = fs[2]
+		}
+	}
+
+	return paths
+}
+
+// getCgroupDir returns the directory of the cgroup path below base, or base if it does not exist,
+// as the path refers to the root of the hierarchy within a cgroup namespace.
+func getCgroupDir(base string, path string) string {
+	dir := filepath.Join(base, path)
+
+	_, err := os.Stat(dir)
+	if err != nil {
+		return base
+	}
+
+	return dir
+}
+
+func readCgroupFile(dir string, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+// readCgroupStat parses a file of space separated key value pairs, e.g. cpu.stat.
+func readCgroupStat(dir string, name string) map[string]float64 {
+	stat := map[string]float64{}
+	for _, l := range strings.Split(readCgroupFile(dir, name), "\n") {
+		fs := strings.Fields(l)
+		if len(fs) == 2 {
+			stat[fs[0]] = parseCgroupValue(fs[1])
+		}
+	}
+
+	return stat
+}
+
+func parseCgroupValue(s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+
+	return v
+}
+
+// getCPULimit returns the number of cores that quota per period amounts to.
+func getCPULimit(quota float64, period float64) float64 {
+	if period <= 0 {
+		return 0
+	}
+
+	return quota / period
+}
@@ -0,0 +1,86 @@
+package pprofrec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCgroupFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestReadCgroupV2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeCgroupFiles(t, dir, map[string]string{
+		"proc":                         "0::/app\n",
+		"fs/cgroup.controllers":        "cpu memory\n",
+		"fs/app/memory.max":            "1073741824\n",
+		"fs/app/memory.current":        "536870912\n",
+		"fs/app/cpu.max":               "150000 100000\n",
+		"fs/app/cpu.stat":              "usage_usec 100\nnr_periods 10\nnr_throttled 4\nthrottled_usec 2500000\n",
+		"unlimited/cgroup.controllers": "cpu memory\n",
+		"unlimited/memory.max":         "max\n",
+		"unlimited/cpu.max":            "max 100000\n",
+		"unlimited/cpu.stat":           "nr_periods 0\n",
+	})
+
+	assert.Equal(t, cgroupStats{
+		memoryLimit:      1 << 30,
+		memoryUsage:      1 << 29,
+		cpuLimit:         1.5,
+		periods:          10,
+		throttledPeriods: 4,
+		throttled:        2.5,
+	}, readCgroup(filepath.Join(dir, "fs"), filepath.Join(dir, "proc")))
+
+	// the path of a cgroup namespace does not exist below the mount
+	assert.Equal(t, cgroupStats{}, readCgroup(filepath.Join(dir, "unlimited"), filepath.Join(dir, "proc")))
+}
+
+func TestReadCgroupV1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeCgroupFiles(t, dir, map[string]string{
+		"proc":                                   "4:memory:/app\n2:cpu,cpuacct:/app\n0::/\n",
+		"fs/memory/app/memory.limit_in_bytes":    "268435456\n",
+		"fs/memory/app/memory.usage_in_bytes":    "134217728\n",
+		"fs/cpu/app/cpu.cfs_quota_us":            "50000\n",
+		"fs/cpu/app/cpu.cfs_period_us":           "100000\n",
+		"fs/cpu/app/cpu.stat":                    "nr_periods 20\nnr_throttled 5\nthrottled_time 1000000000\n",
+		"unlimited/memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"unlimited/cpu/cpu.cfs_quota_us":         "-1\n",
+	})
+
+	assert.Equal(t, cgroupStats{
+		memoryLimit:      1 << 28,
+		memoryUsage:      1 << 27,
+		cpuLimit:         0.5,
+		periods:          20,
+		throttledPeriods: 5,
+		throttled:        1,
+	}, readCgroup(filepath.Join(dir, "fs"), filepath.Join(dir, "proc")))
+
+	assert.Equal(t, cgroupStats{}, readCgroup(filepath.Join(dir, "unlimited"), filepath.Join(dir, "proc")))
+}
+
+func TestCgroupCollector(t *testing.T) {
+	ss := CgroupCollector{}.Collect(context.Background())
+	require.Len(t, ss, 7)
+	assert.Equal(t, "rss_of_limit", ss[2].Name)
+	assert.Equal(t, UnitSeconds, ss[6].Unit)
+}
@@ -0,0 +1,238 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// defaultChartColumns are charted if the request does not select columns.
+var defaultChartColumns = Columns{Include: []string{"goroutine", "HeapAlloc"}}
+
+// chartData holds the series of the charted columns, values are aligned with time.
+type chartData struct {
+	// Time holds the time of each record in unix milliseconds.
+	Time   []int64       `json:"time"`
+	Series []chartSeries `json:"series"`
+}
+
+type chartSeries struct {
+	Name string `json:"name"`
+	// Unit is one of bytes, seconds, time or empty for counts,
+	// seconds are durations and times are unix milliseconds.
+	Unit   string    `json:"unit"`
+	Values []float64 `json:"values"`
+}
+
+func getChartData(cols []column, rs []Record) (d chartData) {
+	d.Time = make([]int64, len(rs))
+	for i, r := range rs {
+		d.Time[i] = r.Time.UnixNano() / 1e6
+	}
+
+	d.Series = make([]chartSeries, len(cols))
+	for i, col := range cols {
+		s := chartSeries{Name: col.qualifiedName(), Values: make([]float64, len(rs))}
+
+		scale := float64(1)
+		switch col.unit {
+		case unitBytes:
+			s.Unit = "bytes"
+		case unitDuration:
+			s.Unit = "seconds"
+			scale = 1e9
+		case unitTime:
+			s.Unit = "time"
+			scale = 1e6
+		case unitPercent:
+			s.Unit = "percent"
+		}
+
+		for j, r := range rs {
+			s.Values[j] = col.value(r) / scale
+		}
+
+		d.Series[i] = s
+	}
+
+	return
+}
+
+// Charts responds with a self-contained html page that plots the metrics recorded by rec as line charts.
+// The page polls the series of the columns selected by ?cols=goroutine,HeapAlloc as JSON via ?format=json,
+// goroutine and HeapAlloc are charted by default. The records can be limited to a shorter window by ?window=5m.
+func Charts(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		window, err := getQueryDuration(r, "window", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		cs := getQueryColumns(r)
+		if len(cs.Include) == 0 && len(cs.Exclude) == 0 {
+			cs = defaultChartColumns
+		}
+
+		switch getFormat(r) {
+		case formatHTML:
+		case formatJSON:
+			w.Header().Set("Content-Type", "application/json")
+
+			err = json.NewEncoder(w).Encode(getChartData(cs.filter(rec.s.cols), limitWindow(rec.Records(), window)))
+			if err != nil {
+				reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+			}
+
+			return
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		_, err = fmt.Fprintf(w, chartsHTML, rec.opts.Frequency.Milliseconds())
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
+
+// chartsHTML is formatted with the refresh interval in milliseconds.
+const chartsHTML = `
+<!DOCTYPE html>
+<html>
+<head>
+	<style>
+		body {
+			font-family: Courier, monospace;
+			font-size: 13px;
+			margin: 10px;
+		}
+
+		.chart {
+			display: inline-block;
+			margin: 0px 20px 20px 0px;
+		}
+
+		.chart__title {
+			font-weight: bold;
+		}
+
+		.chart canvas {
+			border-left: 1px solid gray;
+			border-bottom: 1px solid gray;
+		}
+	</style>
+	<title></title>
+</head>
+<body>
+	<div id="charts"></div>
+	<script>
+		var refresh = %d;
+		var width = 480, height = 160;
+
+		function format(v, unit) {
+			if (unit === "bytes") {
+				var units = ["B", "KiB", "MiB", "GiB", "TiB"];
+				var i = 0;
+				while (Math.abs(v) >= 1024 && i < units.length - 1) {
+					v /= 1024;
+					i++;
+				}
+				return v.toFixed(i === 0 ? 0 : 1) + " " + units[i];
+			}
+			if (unit === "seconds") {
+				return v.toPrecision(3) + " s";
+			}
+			if (unit === "time") {
+				return new Date(v).toLocaleTimeString();
+			}
+			if (unit === "percent") {
+				return v.toFixed(2) + " %%";
+			}
+			return String(Math.round(v * 100) / 100);
+		}
+
+		function chart(id, name) {
+			var el = document.getElementById(id);
+			if (el) {
+				return el;
+			}
+
+			el = document.createElement("div");
+			el.id = id;
+			el.className = "chart";
+
+			var title = document.createElement("div");
+			title.className = "chart__title";
+			title.textContent = name;
+			el.appendChild(title);
+
+			var canvas = document.createElement("canvas");
+			canvas.width = width;
+			canvas.height = height;
+			el.appendChild(canvas);
+
+			var legend = document.createElement("div");
+			legend.className = "chart__legend";
+			el.appendChild(legend);
+
+			document.getElementById("charts").appendChild(el);
+
+			return el;
+		}
+
+		function draw(time, s) {
+			var el = chart("chart-" + s.name, s.name);
+			var canvas = el.querySelector("canvas");
+			var ctx = canvas.getContext("2d");
+			ctx.clearRect(0, 0, width, height);
+
+			if (time.length === 0) {
+				return;
+			}
+
+			var lo = Math.min.apply(null, s.values), hi = Math.max.apply(null, s.values);
+			var t0 = time[0], t1 = time[time.length - 1];
+
+			ctx.strokeStyle = "steelblue";
+			ctx.beginPath();
+			for (var i = 0; i < time.length; i++) {
+				var x = t1 > t0 ? (time[i] - t0) / (t1 - t0) * (width - 1) : 0;
+				var y = hi > lo ? (height - 2) - (s.values[i] - lo) / (hi - lo) * (height - 4) : height / 2;
+				if (i === 0) {
+					ctx.moveTo(x, y);
+				} else {
+					ctx.lineTo(x, y);
+				}
+			}
+			ctx.stroke();
+
+			el.querySelector(".chart__legend").textContent =
+				"min " + format(lo, s.unit) + "  max " + format(hi, s.unit) + "  latest " + format(s.values[s.values.length - 1], s.unit);
+		}
+
+		function update() {
+			var u = new URL(location.href);
+			u.searchParams.set("format", "json");
+
+			fetch(u.toString())
+				.then(function (res) { return res.json(); })
+				.then(function (d) {
+					d.series.forEach(function (s) { draw(d.time, s); });
+				})
+				.catch(function (err) { console.error(err); })
+				.then(function () { setTimeout(update, refresh); });
+		}
+
+		update();
+	</script>
+</body>
+</html>
+`
@@ -0,0 +1,59 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChartData(t *testing.T) {
+	ts := time.Unix(10, 0)
+	rs := goroutineRecords(ts, 3, 4)
+	rs[1].MemStats.PauseTotalNs = 2e9
+
+	cols := Columns{Include: []string{"goroutine", "PauseTotalNs"}}.filter(getColumns(capabilities{}))
+	d := getChartData(cols, rs)
+
+	assert.Equal(t, []int64{10000, 11000}, d.Time)
+	require.Len(t, d.Series, 2)
+	assert.Equal(t, chartSeries{Name: "pprof.Lookup.goroutine", Values: []float64{3, 4}}, d.Series[0])
+	assert.Equal(t, chartSeries{Name: "runtime.MemStats.PauseTotalNs", Unit: "seconds", Values: []float64{0, 2}}, d.Series[1])
+}
+
+func TestCharts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Second, Frequency: 10 * time.Millisecond})
+	rec.Start(ctx)
+	defer rec.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Charts(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/charts", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "var refresh = 10;")
+
+	w = httptest.NewRecorder()
+	Charts(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/charts?format=json", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var d chartData
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&d))
+	require.Len(t, d.Series, 2)
+	assert.Equal(t, "pprof.Lookup.goroutine", d.Series[0].Name)
+	assert.Equal(t, "runtime.MemStats.HeapAlloc", d.Series[1].Name)
+	assert.Equal(t, "bytes", d.Series[1].Unit)
+	assert.Len(t, d.Series[0].Values, len(d.Time))
+
+	w = httptest.NewRecorder()
+	Charts(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/charts?format=csv", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,159 @@
+package pprofrec
+
+import (
+	"context"
+	"os"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// ChildrenCollector collects the resource usage of the child processes and their descendants,
+// for applications that fork helpers like ffmpeg, git or plugins.
+// The usage is recorded as the number of processes, their RSS, user and system CPU time and read and written bytes.
+type ChildrenCollector struct {
+	// Pid is the process whose children are collected, defaults to the current process.
+	Pid int32
+	// Names breaks the usage down by executable name in addition to the totals, e.g. ffmpeg or git.
+	Names []string
+}
+
+// Name returns children.
+func (c ChildrenCollector) Name() string {
+	return "children"
+}
+
+// Collect sums the resource usage of the descendants of Pid, processes that exit while they are inspected are skipped.
+func (c ChildrenCollector) Collect(ctx context.Context) []Sample {
+	pid := c.Pid
+	if pid == 0 {
+		pid = int32(os.Getpid())
+	}
+
+	var total childrenUsage
+	named := make([]childrenUsage, len(c.Names))
+
+	for _, p := range getDescendants(ctx, pid) {
+		u, ok := getChildUsage(ctx, p)
+		if !ok {
+			continue
+		}
+
+		total.add(u)
+
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+
+		for i, n := range c.Names {
+			if n == name {
+				named[i].add(u)
+			}
+		}
+	}
+
+	ss := total.samples("")
+	for i, n := range c.Names {
+		ss = append(ss, named[i].samples(n+".")...)
+	}
+
+	return ss
+}
+
+// childrenUsage is the resource usage of a set of processes.
+type childrenUsage struct {
+	count  float64
+	rss    float64
+	user   float64
+	system float64
+	read   float64
+	write  float64
+}
+
+func (u *childrenUsage) add(o childrenUsage) {
+	u.count += o.count
+	u.rss += o.rss
+	u.user += o.user
+	u.system += o.system
+	u.read += o.read
+	u.write += o.write
+}
+
+func (u childrenUsage) samples(prefix string) []Sample {
+	return []Sample{
+		{Name: prefix + "count", Value: u.count},
+		{Name: prefix + "rss", Value: u.rss, Unit: UnitBytes},
+		{Name: prefix + "user", Value: u.user, Unit: UnitSeconds},
+		{Name: prefix + "system", Value: u.system, Unit: UnitSeconds},
+		{Name: prefix + "read", Value: u.read, Unit: UnitBytes},
+		{Name: prefix + "write", Value: u.write, Unit: UnitBytes},
+	}
+}
+
+// getChildUsage returns the resource usage of p, ok is false if p exited.
+// Stats that are not available on the current OS are zero.
+func getChildUsage(ctx context.Context, p *process.Process) (u childrenUsage, ok bool) {
+	mi, err := p.MemoryInfoWithContext(ctx)
+	if err != nil {
+		running, _ := p.IsRunningWithContext(ctx)
+		if !running {
+			return u, false
+		}
+	}
+	if mi != nil {
+		u.rss = float64(mi.RSS)
+	}
+
+	u.count = 1
+
+	ts, _ := p.TimesWithContext(ctx)
+	if ts != nil {
+		u.user = ts.User
+		u.system = ts.System
+	}
+
+	ioc, _ := p.IOCountersWithContext(ctx)
+	if ioc != nil {
+		u.read = float64(ioc.ReadBytes)
+		u.write = float64(ioc.WriteBytes)
+	}
+
+	return u, true
+}
+
+// getDescendants returns the children of pid and their descendants.
+func getDescendants(ctx context.Context, pid int32) (ds []*process.Process) {
+	ps, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return
+	}
+
+	children := map[int32][]*process.Process{}
+	for _, p := range ps {
+		ppid, err := p.PpidWithContext(ctx)
+		if err != nil {
+			continue
+		}
+
+		children[ppid] = append(children[ppid], p)
+	}
+
+	// pids are tracked as seen to guard against cycles caused by reused pids
+	seen := map[int32]bool{pid: true}
+	pids := []int32{pid}
+	for len(pids) > 0 {
+		pid, pids = pids[0], pids[1:]
+
+		for _, c := range children[pid] {
+			if seen[c.Pid] {
+				continue
+			}
+			seen[c.Pid] = true
+
+			ds = append(ds, c)
+			pids = append(pids, c.Pid)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,50 @@
+package pprofrec
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildrenCollector(t *testing.T) {
+	cmd := exec.Command("sleep", "5")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	c := ChildrenCollector{Names: []string{"sleep", "ffmpeg"}}
+	ss := c.Collect(context.Background())
+	require.Len(t, ss, 18)
+
+	values := map[string]float64{}
+	for _, s := range ss {
+		values[s.Name] = s.Value
+	}
+
+	assert.True(t, values["count"] >= 1)
+	assert.True(t, values["rss"] > 0)
+	assert.Equal(t, float64(1), values["sleep.count"])
+	assert.True(t, values["sleep.rss"] > 0)
+	assert.Equal(t, float64(0), values["ffmpeg.count"])
+	assert.Equal(t, "sleep.rss", ss[7].Name)
+	assert.Equal(t, UnitBytes, ss[7].Unit)
+}
+
+func TestGetDescendants(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "sleep 5 & wait")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(getDescendants(context.Background(), int32(cmd.Process.Pid))) == 1
+	}, time.Second, 10*time.Millisecond)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"log"
 	"net/http"
 	"time"
@@ -12,18 +11,16 @@ import (
 func main() {
 	mux := http.NewServeMux()
 
-	ctx := context.Background()
-
-	windowOpts := pprofrec.WindowOpts{
-		Window:    120 * time.Second,
-		Frequency: 1 * time.Second,
-	}
-	mux.HandleFunc("/debug/pprof/window", pprofrec.Window(ctx, windowOpts))
-
-	streamOpts := pprofrec.StreamOpts{
-		Frequency: 500 * time.Millisecond,
-	}
-	mux.HandleFunc("/debug/pprof/stream", pprofrec.Stream(streamOpts))
+	rec := pprofrec.RegisterHandlers(mux, "/debug/pprof", pprofrec.HandlersOpts{
+		Window: pprofrec.WindowOpts{
+			Window:    120 * time.Second,
+			Frequency: 1 * time.Second,
+		},
+		Stream: pprofrec.StreamOpts{
+			Frequency: 500 * time.Millisecond,
+		},
+	})
+	defer rec.Stop()
 
 	srv := &http.Server{
 		Addr:         ":8080",
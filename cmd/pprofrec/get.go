@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func get(ctx context.Context, args []string, stdout io.Writer) (err error) {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+
+	var cf clientFlags
+	cf.register(fs)
+
+	format := fs.String("format", "table", "output format, one of table, csv or json")
+	output := fs.String("o", "", "file to write to instead of stdout")
+	window := fs.Duration("window", 0, "limits the records to a shorter window, e.g. 5m")
+	freq := fs.Duration("freq", 0, "thins out the records to a lower frequency, e.g. 10s")
+
+	err = fs.Parse(args)
+	if err != nil {
+		return
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("missing window url, usage: pprofrec get [flags] <window url>")
+	}
+
+	q := url.Values{}
+	switch *format {
+	case "table", "csv":
+		q.Set("format", "csv")
+	case "json":
+		q.Set("format", "json")
+	default:
+		return fmt.Errorf("unknown format: %v", *format)
+	}
+	if cf.cols != "" {
+		q.Set("cols", cf.cols)
+	}
+	if *window > 0 {
+		q.Set("window", window.String())
+	}
+	if *freq > 0 {
+		q.Set("freq", freq.String())
+	}
+
+	b, err := fetch(ctx, fs.Arg(0), q, cf.token)
+	if err != nil {
+		return
+	}
+
+	w := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	if *format != "table" {
+		_, err = w.Write(b)
+
+		return
+	}
+
+	return writeTable(w, b)
+}
+
+// fetch GETs rawURL with the query parameters q merged into its query.
+func fetch(ctx context.Context, rawURL string, q url.Values, token string) (b []byte, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	uq := u.Query()
+	for k, vs := range q {
+		uq[k] = vs
+	}
+	u.RawQuery = uq.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %v: %v", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	return
+}
+
+// writeTable renders the CSV output of the Window handler as an aligned table,
+// columns are labeled by their unqualified name and times are shortened to the clock time.
+func writeTable(w io.Writer, b []byte) (err error) {
+	rows, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		return
+	}
+
+	if len(rows) == 0 {
+		return
+	}
+
+	// the trailing tags and annotations columns are only rendered if they are set
+	n := len(rows[0])
+	for n > 0 && (rows[0][n-1] == "tags" || rows[0][n-1] == "annotations") {
+		set := false
+		for _, row := range rows[1:] {
+			if row[n-1] != "" {
+				set = true
+			}
+		}
+		if set {
+			break
+		}
+
+		n--
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	for i, row := range rows {
+		cells := make([]string, n)
+		for j := range cells {
+			cells[j] = row[j]
+
+			switch {
+			case i == 0:
+				cells[j] = cells[j][strings.LastIndex(cells[j], ".")+1:]
+			case j == 0:
+				t, err := time.Parse(time.RFC3339Nano, cells[j])
+				if err == nil {
+					cells[j] = t.Format("15:04:05")
+				}
+			}
+		}
+
+		_, err = fmt.Fprintln(tw, strings.Join(cells, "\t")+"\t")
+		if err != nil {
+			return
+		}
+	}
+
+	return tw.Flush()
+}
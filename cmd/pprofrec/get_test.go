@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppwfx/pprofrec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTable(t *testing.T) {
+	in := "time,pprof.Lookup.goroutine,runtime.MemStats.HeapAlloc,annotations,tags\n" +
+		"2021-10-01T12:00:00Z,5,1024,,\n" +
+		"2021-10-01T12:00:01Z,12,2048,,\n"
+
+	var b bytes.Buffer
+	require.NoError(t, writeTable(&b, []byte(in)))
+	assert.Equal(t, ""+
+		"      time  goroutine  HeapAlloc\n"+
+		"  12:00:00          5       1024\n"+
+		"  12:00:01         12       2048\n", b.String())
+}
+
+func TestGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(pprofrec.Window(ctx, pprofrec.WindowOpts{
+		Window:    time.Second,
+		Frequency: 10 * time.Millisecond,
+		Auth:      pprofrec.Auth{Token: "token"},
+	})))
+	defer srv.Close()
+
+	time.Sleep(50 * time.Millisecond)
+
+	var b bytes.Buffer
+	require.NoError(t, run(ctx, []string{"get", "-token", "token", "-cols", "goroutine", srv.URL}, &b))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.True(t, len(lines) > 1)
+	assert.Equal(t, []string{"time", "goroutine"}, strings.Fields(lines[0]))
+
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "window.json")
+	require.NoError(t, run(ctx, []string{"get", "-token", "token", "-format", "json", "-o", path, srv.URL}, &b))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "["))
+
+	err = run(ctx, []string{"get", srv.URL}, &b)
+	assert.EqualError(t, err, "unexpected status code: 401: unauthorized")
+}
@@ -0,0 +1,67 @@
+// Command pprofrec inspects the metrics recorded by a remote pprofrec instance from the terminal.
+//
+// Usage:
+//
+//	pprofrec get [flags] <window url>
+//	pprofrec top [flags] <stream url>
+//
+// get fetches the recorded window, e.g. http://localhost:8080/debug/pprof/window,
+// and renders it as an aligned table or writes it as JSON or CSV to a file.
+//
+// top connects to the stream, e.g. http://localhost:8080/debug/pprof/stream,
+// and redraws the latest values and their recent history with every record.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	err := run(ctx, os.Args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pprofrec: %v\n", err)
+
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) (err error) {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command, usage: pprofrec get|top [flags] <url>")
+	}
+
+	switch args[0] {
+	case "get":
+		return get(ctx, args[1:], stdout)
+	case "top":
+		return top(ctx, args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown command: %v", args[0])
+	}
+}
+
+// clientFlags are shared by the commands that fetch from a pprofrec instance.
+type clientFlags struct {
+	cols  string
+	token string
+}
+
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.cols, "cols", "", "comma-separated columns to fetch, e.g. goroutine,HeapAlloc,RSS")
+	fs.StringVar(&f.token, "token", os.Getenv("PPROFREC_TOKEN"), "bearer token, defaults to $PPROFREC_TOKEN")
+}
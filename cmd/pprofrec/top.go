@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const defaultTopColumns = "goroutine,HeapAlloc,HeapInuse,NumGC,PauseTotalNs,RSS"
+
+func top(ctx context.Context, args []string, stdout io.Writer) (err error) {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+
+	var cf clientFlags
+	cf.register(fs)
+
+	freq := fs.Duration("freq", 0, "overrides the frequency of the stream, e.g. 500ms")
+	window := fs.Duration("window", 0, "stops after the given duration, e.g. 5m")
+	history := fs.Int("history", 60, "number of records the sparklines span")
+
+	err = fs.Parse(args)
+	if err != nil {
+		return
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("missing stream url, usage: pprofrec top [flags] <stream url>")
+	}
+
+	if cf.cols == "" {
+		cf.cols = defaultTopColumns
+	}
+
+	q := url.Values{}
+	q.Set("format", "ndjson")
+	q.Set("cols", cf.cols)
+	if *freq > 0 {
+		q.Set("freq", freq.String())
+	}
+	if *window > 0 {
+		q.Set("window", window.String())
+	}
+
+	body, err := stream(ctx, fs.Arg(0), q, cf.token)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+
+	s := newTopState(strings.Split(cf.cols, ","), *history)
+
+	d := json.NewDecoder(bufio.NewReader(body))
+	for {
+		var r map[string]interface{}
+		err = d.Decode(&r)
+		if err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("failed to decode record: %w", err)
+		}
+
+		s.add(r)
+
+		err = s.render(stdout, fs.Arg(0))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stream GETs rawURL with the query parameters q merged into its query and returns the response body.
+func stream(ctx context.Context, rawURL string, q url.Values, token string) (body io.ReadCloser, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	uq := u.Query()
+	for k, vs := range q {
+		uq[k] = vs
+	}
+	u.RawQuery = uq.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		b := make([]byte, 512)
+		n, _ := io.ReadFull(resp.Body, b)
+
+		return nil, fmt.Errorf("unexpected status code: %v: %v", resp.StatusCode, strings.TrimSpace(string(b[:n])))
+	}
+
+	return resp.Body, nil
+}
+
+// series holds the most recent values of a metric.
+type series struct {
+	group  string
+	name   string
+	values []float64
+}
+
+// topState holds the series of the metrics that are rendered by top.
+type topState struct {
+	include []string
+	exclude []string
+	history int
+	time    string
+	series  []*series
+	index   map[string]*series
+}
+
+// newTopState returns a topState that keeps history values of the metrics selected by cols,
+// which are matched like the ?cols query parameter of the stream.
+func newTopState(cols []string, history int) *topState {
+	s := &topState{
+		history: history,
+		index:   map[string]*series{},
+	}
+
+	for _, n := range cols {
+		n = strings.TrimSpace(n)
+		switch {
+		case n == "" || n == "-":
+		case strings.HasPrefix(n, "-"):
+			s.exclude = append(s.exclude, n[1:])
+		default:
+			s.include = append(s.include, n)
+		}
+	}
+
+	return s
+}
+
+// selects reports whether the metric name of group is rendered.
+func (s *topState) selects(group string, name string) bool {
+	if len(s.include) > 0 && !matches(s.include, group, name) {
+		return false
+	}
+
+	return !matches(s.exclude, group, name)
+}
+
+// add appends the metrics of the decoded record r to their series.
+func (s *topState) add(r map[string]interface{}) {
+	if t, ok := r["time"].(string); ok {
+		s.time = t
+	}
+
+	flatten("", r, func(group string, name string, v float64) {
+		if !s.selects(group, name) {
+			return
+		}
+
+		key := group + "." + name
+		ser, ok := s.index[key]
+		if !ok {
+			ser = &series{group: group, name: name}
+			s.index[key] = ser
+			s.series = append(s.series, ser)
+		}
+
+		ser.values = append(ser.values, v)
+		if len(ser.values) > s.history {
+			ser.values = ser.values[len(ser.values)-s.history:]
+		}
+	})
+}
+
+// render clears the terminal and draws the latest value, the change since the previous record
+// and a sparkline of the history of each series.
+func (s *topState) render(w io.Writer, title string) (err error) {
+	t := s.time
+	parsed, err := time.Parse(time.RFC3339Nano, t)
+	if err == nil {
+		t = parsed.Format("15:04:05")
+	}
+
+	_, err = fmt.Fprintf(w, "\x1b[H\x1b[2J%v  %v\n\n", title, t)
+	if err != nil {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, err = fmt.Fprintln(tw, "NAME\tVALUE\tDELTA\tHISTORY")
+	if err != nil {
+		return
+	}
+
+	for _, ser := range s.series {
+		cur := ser.values[len(ser.values)-1]
+
+		delta := ""
+		if len(ser.values) > 1 {
+			d := cur - ser.values[len(ser.values)-2]
+			delta = formatValue(d)
+			if d > 0 {
+				delta = "+" + delta
+			}
+		}
+
+		_, err = fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", ser.name, formatValue(cur), delta, sparkline(ser.values))
+		if err != nil {
+			return
+		}
+	}
+
+	return tw.Flush()
+}
+
+// flatten calls fn with every number in v, which is a decoded JSON record. Numbers are
+// grouped by the key of their enclosing object and collector samples by their collector.
+func flatten(group string, v interface{}, fn func(group string, name string, v float64)) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch e := m[k].(type) {
+		case float64:
+			fn(group, k, e)
+		case map[string]interface{}:
+			if k == "samples" {
+				for c, ss := range e {
+					flattenSamples(c, ss, fn)
+				}
+
+				continue
+			}
+
+			flatten(k, e, fn)
+		}
+	}
+}
+
+// flattenSamples calls fn with the value of every sample in ss.
+func flattenSamples(group string, ss interface{}, fn func(group string, name string, v float64)) {
+	l, ok := ss.([]interface{})
+	if !ok {
+		return
+	}
+
+	for _, e := range l {
+		sample, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := sample["name"].(string)
+		v, ok := sample["value"].(float64)
+		if name == "" || !ok {
+			continue
+		}
+
+		fn(group, name, v)
+	}
+}
+
+// matches reports whether any of the names refers to the metric name or its group group.
+func matches(names []string, group string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, group) || strings.EqualFold(n, name) || strings.EqualFold(n, group+"."+name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders vs as a line of block characters scaled between their minimum and maximum.
+func sparkline(vs []float64) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range vs {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	var b strings.Builder
+	for _, v := range vs {
+		i := 0
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(sparks)-1))
+		}
+
+		b.WriteRune(sparks[i])
+	}
+
+	return b.String()
+}
+
+// formatValue formats v without exponent, rounded to two decimals if it is fractional.
+func formatValue(v float64) string {
+	if v == math.Trunc(v) {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ppwfx/pprofrec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", sparkline([]float64{1, 5, 10}))
+	assert.Equal(t, "▁▁", sparkline([]float64{3, 3}))
+}
+
+func TestTopState(t *testing.T) {
+	s := newTopState([]string{"goroutine", "memstats", "-NumGC", "queue"}, 2)
+
+	for _, v := range []float64{1, 2, 4} {
+		s.add(map[string]interface{}{
+			"time":     "2021-10-01T12:00:00Z",
+			"pprof":    map[string]interface{}{"goroutine": v, "heap": v},
+			"memStats": map[string]interface{}{"heapAlloc": v * 1024, "numGC": v},
+			"samples": map[string]interface{}{
+				"queue": []interface{}{map[string]interface{}{"name": "depth", "value": v}},
+			},
+		})
+	}
+
+	var names []string
+	for _, ser := range s.series {
+		names = append(names, ser.group+"."+ser.name)
+	}
+	assert.Equal(t, []string{"memStats.heapAlloc", "pprof.goroutine", "queue.depth"}, names)
+	assert.Equal(t, []float64{2, 4}, s.index["pprof.goroutine"].values)
+
+	var b bytes.Buffer
+	require.NoError(t, s.render(&b, "http://localhost:8080/stream"))
+	assert.True(t, strings.HasPrefix(b.String(), "\x1b[H\x1b[2Jhttp://localhost:8080/stream  12:00:00\n"))
+	assert.Contains(t, b.String(), "goroutine  4      +2     ▁█")
+}
+
+func TestTop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(pprofrec.Stream(pprofrec.StreamOpts{
+		Frequency:    10 * time.Millisecond,
+		MinFrequency: 10 * time.Millisecond,
+		Auth:         pprofrec.Auth{Token: "token"},
+	})))
+	defer srv.Close()
+
+	var b bytes.Buffer
+	require.NoError(t, run(context.Background(), []string{"top", "-token", "token", "-cols", "goroutine", "-window", "100ms", srv.URL}, &b))
+	assert.Contains(t, b.String(), "goroutine")
+	assert.NotContains(t, b.String(), "heapAlloc")
+
+	err := run(context.Background(), []string{"top", srv.URL}, &b)
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package pprofrec
+
+import (
+	"context"
+)
+
+// Collector collects application-defined metrics, e.g. queue depth, cache size or pool stats,
+// which are recorded as a group of columns named after the collector.
+// Collect is expected to return samples with the same names on every call.
+type Collector interface {
+	// Name names the collector, it is used as the group name of its columns.
+	Name() string
+	// Collect returns the current values of the metrics.
+	Collect(ctx context.Context) []Sample
+}
+
+// Unit determines how the value of a Sample is rendered.
+type Unit string
+
+const (
+	// UnitCount renders the value as a plain number.
+	UnitCount Unit = ""
+	// UnitBytes renders the value in human-readable bytes.
+	UnitBytes Unit = "bytes"
+	// UnitSeconds renders the value, given in seconds, as a duration.
+	UnitSeconds Unit = "seconds"
+	// UnitTime renders the value, given in unix seconds, as a time.
+	UnitTime Unit = "time"
+	// UnitPercent renders the value, given in percent, with two decimals.
+	UnitPercent Unit = "percent"
+)
+
+// Sample is a single value collected by a Collector.
+type Sample struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  Unit    `json:"unit,omitempty"`
+}
+
+// getCollectorColumns discovers the columns of the collectors by collecting them once.
+func getCollectorColumns(ctx context.Context, collectors []Collector) (cols []column) {
+	for _, c := range collectors {
+		g := &group{
+			name:  c.Name(),
+			title: c.Name(),
+		}
+
+		for _, s := range c.Collect(ctx) {
+			cols = append(cols, getSampleColumn(g, s))
+		}
+	}
+
+	return
+}
+
+func getSampleColumn(g *group, s Sample) column {
+	name := s.Name
+	value := func(r Record) float64 {
+		for _, s := range r.Samples[g.name] {
+			if s.Name == name {
+				return s.Value
+			}
+		}
+
+		return 0
+	}
+
+	switch s.Unit {
+	case UnitBytes:
+		return column{group: g, name: name, unit: unitBytes, value: value}
+	case UnitSeconds:
+		return column{group: g, name: name, unit: unitDuration, value: func(r Record) float64 { return seconds(value(r)) }}
+	case UnitTime:
+		return column{group: g, name: name, unit: unitTime, value: func(r Record) float64 { return seconds(value(r)) }}
+	case UnitPercent:
+		return column{group: g, name: name, unit: unitPercent, value: value}
+	default:
+		return column{group: g, name: name, unit: unitCount, value: value}
+	}
+}
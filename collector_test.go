@@ -0,0 +1,65 @@
+package pprofrec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type queueCollector struct {
+	calls int64
+}
+
+func (c *queueCollector) Name() string {
+	return "queue"
+}
+
+func (c *queueCollector) Collect(ctx context.Context) []Sample {
+	n := atomic.AddInt64(&c.calls, 1)
+
+	return []Sample{
+		{Name: "depth", Value: float64(n)},
+		{Name: "size", Value: float64(n * 1024), Unit: UnitBytes},
+	}
+}
+
+func TestCollector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := &queueCollector{}
+	f := Window(ctx, WindowOpts{Window: time.Second, Frequency: 50 * time.Millisecond, Collectors: []Collector{c}})
+
+	time.Sleep(200 * time.Millisecond)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=csv&cols=queue", nil)
+	w := httptest.NewRecorder()
+	f(w, r)
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.True(t, len(lines) > 1)
+	assert.Equal(t, "time,queue.depth,queue.size,annotations,tags", lines[0])
+
+	r = httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	w = httptest.NewRecorder()
+	f(w, r)
+
+	assert.Contains(t, w.Body.String(), "queue")
+	assert.Contains(t, w.Body.String(), "KiB")
+}
+
+func TestCollectorDeselected(t *testing.T) {
+	c := &queueCollector{}
+	s := newSampler(context.Background(), nil, []Collector{c}, Columns{Exclude: []string{"queue"}})
+
+	r := s.getRecord(context.Background())
+	assert.Nil(t, r.Samples)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&c.calls))
+}
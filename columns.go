@@ -0,0 +1,384 @@
+package pprofrec
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// unit determines how the values of a column are formatted.
+type unit int
+
+const (
+	unitCount unit = iota
+	unitBytes
+	unitDuration
+	unitTime
+	unitPercent
+)
+
+// group is a set of columns that are recorded from the same source.
+type group struct {
+	// name identifies the group, e.g. when selecting columns.
+	name string
+	// title is rendered as the group head.
+	title string
+	// href links the group head to the documentation of the source.
+	href string
+	// fields indicates that the columns are fields of the source struct.
+	fields bool
+}
+
+// column describes a single recorded metric.
+type column struct {
+	group *group
+	name  string
+	unit  unit
+	// value returns the value of the metric in the given record,
+	// durations and times are returned in nanoseconds.
+	value func(r Record) float64
+}
+
+// label returns the name that is rendered in the html head.
+func (c column) label() string {
+	if c.group.fields {
+		return "." + c.name
+	}
+
+	return c.name
+}
+
+// qualifiedName returns the name of the column prefixed with the title of its group.
+func (c column) qualifiedName() string {
+	return c.group.title + "." + c.name
+}
+
+var (
+	pprofGroup = &group{
+		name:  "pprof",
+		title: "pprof.Lookup",
+		href:  "https://godoc.org/runtime/pprof#Lookup",
+	}
+	memStatsGroup = &group{
+		name:   "memstats",
+		title:  "runtime.MemStats",
+		href:   "https://godoc.org/runtime#MemStats",
+		fields: true,
+	}
+	runtimeMetricsGroup = &group{
+		name:  "runtimemetrics",
+		title: "runtime/metrics",
+		href:  "https://pkg.go.dev/runtime/metrics",
+	}
+	memoryInfoGroup = &group{
+		name:   "memoryinfo",
+		title:  "process.MemoryInfoStat",
+		href:   "https://godoc.org/github.com/shirou/gopsutil/process#MemoryInfoStat",
+		fields: true,
+	}
+	cpuTimesGroup = &group{
+		name:   "cputimes",
+		title:  "cpu.TimesStat",
+		href:   "https://godoc.org/github.com/shirou/gopsutil/cpu#TimesStat",
+		fields: true,
+	}
+	ioCountersGroup = &group{
+		name:   "iocounters",
+		title:  "process.IOCountersStat",
+		href:   "https://godoc.org/github.com/shirou/gopsutil/process#IOCountersStat",
+		fields: true,
+	}
+	ctxSwitchesGroup = &group{
+		name:   "ctxswitches",
+		title:  "process.NumCtxSwitchesStat",
+		href:   "https://godoc.org/github.com/shirou/gopsutil/process#NumCtxSwitchesStat",
+		fields: true,
+	}
+	memoryLimitGroup = &group{
+		name:  "memlimit",
+		title: "debug.SetMemoryLimit",
+		href:  "https://pkg.go.dev/runtime/debug#SetMemoryLimit",
+	}
+	win32Group = &group{
+		name:  "win32",
+		title: "win32",
+		href:  "https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getprocesshandlecount",
+	}
+	schedLatenciesGroup = &group{
+		name:  "schedlatencies",
+		title: "/sched/latencies:seconds",
+		href:  "https://pkg.go.dev/runtime/metrics",
+	}
+	gcPausesGroup = &group{
+		name:  "gcpauses",
+		title: "runtime.MemStats.PauseNs",
+		href:  "https://pkg.go.dev/runtime#MemStats",
+	}
+	ratesGroup = &group{
+		name:  "rates",
+		title: "rates",
+	}
+	overheadGroup = &group{
+		name:  "overhead",
+		title: "overhead",
+	}
+	pageFaultsGroup = &group{
+		name:   "pagefaults",
+		title:  "process.PageFaultsStat",
+		href:   "https://godoc.org/github.com/shirou/gopsutil/process#PageFaultsStat",
+		fields: true,
+	}
+)
+
+var pprofColumns = []column{
+	{group: pprofGroup, name: "goroutine", unit: unitCount, value: func(r Record) float64 { return float64(r.Pprof.Goroutine) }},
+	{group: pprofGroup, name: "threadcreate", unit: unitCount, value: func(r Record) float64 { return float64(r.Pprof.Threadcreate) }},
+	{group: pprofGroup, name: "heap", unit: unitCount, value: func(r Record) float64 { return float64(r.Pprof.Heap) }},
+	{group: pprofGroup, name: "allocs", unit: unitCount, value: func(r Record) float64 { return float64(r.Pprof.Allocs) }},
+	{group: pprofGroup, name: "block", unit: unitCount, value: func(r Record) float64 { return float64(r.Pprof.Block) }},
+	{group: pprofGroup, name: "mutex", unit: unitCount, value: func(r Record) float64 { return float64(r.Pprof.Mutex) }},
+}
+
+var memStatsColumns = []column{
+	{group: memStatsGroup, name: "Alloc", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.Alloc) }},
+	{group: memStatsGroup, name: "TotalAlloc", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.TotalAlloc) }},
+	{group: memStatsGroup, name: "Sys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.Sys) }},
+	{group: memStatsGroup, name: "Lookups", unit: unitCount, value: func(r Record) float64 { return float64(r.MemStats.Lookups) }},
+	{group: memStatsGroup, name: "Mallocs", unit: unitCount, value: func(r Record) float64 { return float64(r.MemStats.Mallocs) }},
+	{group: memStatsGroup, name: "Frees", unit: unitCount, value: func(r Record) float64 { return float64(r.MemStats.Frees) }},
+	{group: memStatsGroup, name: "HeapAlloc", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.HeapAlloc) }},
+	{group: memStatsGroup, name: "HeapSys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.HeapSys) }},
+	{group: memStatsGroup, name: "HeapIdle", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.HeapIdle) }},
+	{group: memStatsGroup, name: "HeapInuse", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.HeapInuse) }},
+	{group: memStatsGroup, name: "HeapReleased", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.HeapReleased) }},
+	{group: memStatsGroup, name: "HeapObjects", unit: unitCount, value: func(r Record) float64 { return float64(r.MemStats.HeapObjects) }},
+	{group: memStatsGroup, name: "StackInuse", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.StackInuse) }},
+	{group: memStatsGroup, name: "StackSys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.StackSys) }},
+	{group: memStatsGroup, name: "MSpanInuse", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.MSpanInuse) }},
+	{group: memStatsGroup, name: "MSpanSys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.MSpanSys) }},
+	{group: memStatsGroup, name: "MCacheInuse", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.MCacheInuse) }},
+	{group: memStatsGroup, name: "MCacheSys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.MCacheSys) }},
+	{group: memStatsGroup, name: "BuckHashSys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.BuckHashSys) }},
+	{group: memStatsGroup, name: "GCSys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.GCSys) }},
+	{group: memStatsGroup, name: "OtherSys", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.OtherSys) }},
+	{group: memStatsGroup, name: "NextGC", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemStats.NextGC) }},
+	{group: memStatsGroup, name: "LastGC", unit: unitTime, value: func(r Record) float64 { return float64(r.MemStats.LastGC) }},
+	{group: memStatsGroup, name: "PauseTotalNs", unit: unitDuration, value: func(r Record) float64 { return float64(r.MemStats.PauseTotalNs) }},
+	{group: memStatsGroup, name: "NumGC", unit: unitCount, value: func(r Record) float64 { return float64(r.MemStats.NumGC) }},
+	{group: memStatsGroup, name: "NumForcedGC", unit: unitCount, value: func(r Record) float64 { return float64(r.MemStats.NumForcedGC) }},
+	{group: memStatsGroup, name: "GCCPUFraction", unit: unitPercent, value: func(r Record) float64 { return 100 * r.MemStats.GCCPUFraction }},
+}
+
+var memoryInfoColumns = []column{
+	{group: memoryInfoGroup, name: "RSS", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryInfo.RSS) }},
+	{group: memoryInfoGroup, name: "VMS", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryInfo.VMS) }},
+	{group: memoryInfoGroup, name: "HWM", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryInfo.HWM) }},
+	{group: memoryInfoGroup, name: "Data", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryInfo.Data) }},
+	{group: memoryInfoGroup, name: "Stack", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryInfo.Stack) }},
+	{group: memoryInfoGroup, name: "Locked", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryInfo.Locked) }},
+	{group: memoryInfoGroup, name: "Swap", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryInfo.Swap) }},
+}
+
+var cpuTimesColumns = []column{
+	{group: cpuTimesGroup, name: "User", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.User) }},
+	{group: cpuTimesGroup, name: "System", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.System) }},
+	{group: cpuTimesGroup, name: "Idle", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.Idle) }},
+	{group: cpuTimesGroup, name: "Nice", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.Nice) }},
+	{group: cpuTimesGroup, name: "Iowait", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.Iowait) }},
+	{group: cpuTimesGroup, name: "Irq", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.Irq) }},
+	{group: cpuTimesGroup, name: "Softirq", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.Softirq) }},
+	{group: cpuTimesGroup, name: "Steal", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.Steal) }},
+	{group: cpuTimesGroup, name: "Guest", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.Guest) }},
+	{group: cpuTimesGroup, name: "GuestNice", unit: unitDuration, value: func(r Record) float64 { return seconds(r.CPUTimes.GuestNice) }},
+}
+
+var ioCountersColumns = []column{
+	{group: ioCountersGroup, name: "ReadCount", unit: unitCount, value: func(r Record) float64 { return float64(r.IOCounters.ReadCount) }},
+	{group: ioCountersGroup, name: "WriteCount", unit: unitCount, value: func(r Record) float64 { return float64(r.IOCounters.WriteCount) }},
+	{group: ioCountersGroup, name: "ReadBytes", unit: unitBytes, value: func(r Record) float64 { return float64(r.IOCounters.ReadBytes) }},
+	{group: ioCountersGroup, name: "WriteBytes", unit: unitBytes, value: func(r Record) float64 { return float64(r.IOCounters.WriteBytes) }},
+}
+
+var ctxSwitchesColumns = []column{
+	{group: ctxSwitchesGroup, name: "Voluntary", unit: unitCount, value: func(r Record) float64 { return float64(r.NumCtxSwitches.Voluntary) }},
+	{group: ctxSwitchesGroup, name: "Involuntary", unit: unitCount, value: func(r Record) float64 { return float64(r.NumCtxSwitches.Involuntary) }},
+}
+
+var memoryLimitColumns = []column{
+	{group: memoryLimitGroup, name: "limit", unit: unitBytes, value: func(r Record) float64 { return float64(r.MemoryLimit.Limit) }},
+	{group: memoryLimitGroup, name: "used", unit: unitPercent, value: func(r Record) float64 { return r.MemoryLimit.Used }},
+}
+
+var win32Columns = []column{
+	{group: win32Group, name: "HandleCount", unit: unitCount, value: func(r Record) float64 { return float64(r.Win32.HandleCount) }},
+	{group: win32Group, name: "PriorityClass", unit: unitCount, value: func(r Record) float64 { return float64(r.Win32.PriorityClass) }},
+	{group: win32Group, name: "IOPriority", unit: unitCount, value: func(r Record) float64 { return float64(r.Win32.IOPriority) }},
+}
+
+var schedLatenciesColumns = []column{
+	{group: schedLatenciesGroup, name: "p50", unit: unitDuration, value: func(r Record) float64 { return seconds(r.SchedLatencies.P50) }},
+	{group: schedLatenciesGroup, name: "p99", unit: unitDuration, value: func(r Record) float64 { return seconds(r.SchedLatencies.P99) }},
+}
+
+var gcPausesColumns = []column{
+	{group: gcPausesGroup, name: "count", unit: unitCount, value: func(r Record) float64 { return float64(r.GCPauses.Count) }},
+	{group: gcPausesGroup, name: "max", unit: unitDuration, value: func(r Record) float64 { return float64(r.GCPauses.MaxNs) }},
+}
+
+var ratesColumns = []column{
+	{group: ratesGroup, name: "Mallocs/s", unit: unitCount, value: func(r Record) float64 { return r.Rates.Mallocs }},
+	{group: ratesGroup, name: "TotalAlloc/s", unit: unitBytes, value: func(r Record) float64 { return r.Rates.TotalAlloc }},
+	{group: ratesGroup, name: "NumGC/min", unit: unitCount, value: func(r Record) float64 { return r.Rates.NumGC }},
+	{group: ratesGroup, name: "ReadCount/s", unit: unitCount, value: func(r Record) float64 { return r.Rates.ReadCount }},
+	{group: ratesGroup, name: "WriteCount/s", unit: unitCount, value: func(r Record) float64 { return r.Rates.WriteCount }},
+	{group: ratesGroup, name: "CPU", unit: unitPercent, value: func(r Record) float64 { return r.Rates.CPU }},
+}
+
+var overheadColumns = []column{
+	{group: overheadGroup, name: "duration", unit: unitDuration, value: func(r Record) float64 { return float64(r.Overhead.Duration) }},
+	{group: overheadGroup, name: "allocs", unit: unitCount, value: func(r Record) float64 { return float64(r.Overhead.Allocs) }},
+	{group: overheadGroup, name: "allocBytes", unit: unitBytes, value: func(r Record) float64 { return float64(r.Overhead.AllocBytes) }},
+	{group: overheadGroup, name: "droppedTicks", unit: unitCount, value: func(r Record) float64 { return float64(r.Overhead.DroppedTicks) }},
+}
+
+var pageFaultsColumns = []column{
+	{group: pageFaultsGroup, name: "MinorFaults", unit: unitCount, value: func(r Record) float64 { return float64(r.PageFaults.MinorFaults) }},
+	{group: pageFaultsGroup, name: "MajorFaults", unit: unitCount, value: func(r Record) float64 { return float64(r.PageFaults.MajorFaults) }},
+	{group: pageFaultsGroup, name: "ChildMinorFaults", unit: unitCount, value: func(r Record) float64 { return float64(r.PageFaults.ChildMinorFaults) }},
+	{group: pageFaultsGroup, name: "ChildMajorFaults", unit: unitCount, value: func(r Record) float64 { return float64(r.PageFaults.ChildMajorFaults) }},
+}
+
+// Columns selects metric groups and individual columns by name.
+// Groups are named pprof, memstats, runtimemetrics, memoryinfo, cputimes, iocounters, ctxswitches, pagefaults, memlimit, win32,
+// schedlatencies, gcpauses, rates and overhead,
+// columns are named as in the html head without the leading dot, e.g. goroutine, HeapAlloc or RSS,
+// and may be qualified by their group, e.g. memstats.HeapAlloc. Names are matched case-insensitively.
+// Metrics of deselected groups are not sampled.
+type Columns struct {
+	// Include lists the groups and columns to record, all are recorded if empty.
+	Include []string
+	// Exclude lists the groups and columns not to record.
+	Exclude []string
+}
+
+// getQueryColumns parses the comma-separated cols query parameter,
+// names prefixed with a - are excluded.
+func getQueryColumns(r *http.Request) (cs Columns) {
+	q := r.URL.Query().Get("cols")
+	if q == "" {
+		return
+	}
+
+	for _, n := range strings.Split(q, ",") {
+		n = strings.TrimSpace(n)
+		switch {
+		case n == "" || n == "-":
+		case strings.HasPrefix(n, "-"):
+			cs.Exclude = append(cs.Exclude, n[1:])
+		default:
+			cs.Include = append(cs.Include, n)
+		}
+	}
+
+	return
+}
+
+// filter returns the columns that are selected.
+func (cs Columns) filter(cols []column) (selected []column) {
+	for _, col := range cols {
+		if cs.selects(col.group.name, col.name) {
+			selected = append(selected, col)
+		}
+	}
+
+	return
+}
+
+// selects reports whether the column name of the group group is selected.
+func (cs Columns) selects(group string, name string) bool {
+	if len(cs.Include) > 0 && !matches(cs.Include, group, name) {
+		return false
+	}
+
+	return !matches(cs.Exclude, group, name)
+}
+
+// matches reports whether any of the names refers to the column name or its group group.
+func matches(names []string, group string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, group) || strings.EqualFold(n, name) || strings.EqualFold(n, group+"."+name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findColumn returns the column named name, which may be qualified by its group.
+func findColumn(cols []column, name string) (column, bool) {
+	for _, col := range cols {
+		if strings.EqualFold(name, col.name) || strings.EqualFold(name, col.group.name+"."+col.name) {
+			return col, true
+		}
+	}
+
+	return column{}, false
+}
+
+// getColumns returns the columns that are available with the given capabilities.
+func getColumns(c capabilities) (cols []column) {
+	cols = append(cols, pprofColumns...)
+	cols = append(cols, memStatsColumns...)
+	cols = append(cols, getRuntimeMetricsColumns()...)
+
+	if c.memoryInfoStat {
+		cols = append(cols, memoryInfoColumns...)
+	}
+
+	if c.cpuTimeStat {
+		cols = append(cols, cpuTimesColumns...)
+	}
+
+	if c.iOCounterStat {
+		cols = append(cols, ioCountersColumns...)
+	}
+
+	if c.ctxSwitchStat {
+		cols = append(cols, ctxSwitchesColumns...)
+	}
+
+	if c.pageFaultStat {
+		cols = append(cols, pageFaultsColumns...)
+	}
+
+	if c.memoryLimit {
+		cols = append(cols, memoryLimitColumns...)
+	}
+
+	if c.win32 {
+		cols = append(cols, win32Columns...)
+	}
+
+	if c.schedLatencies {
+		cols = append(cols, schedLatenciesColumns...)
+	}
+
+	if c.gcPauses {
+		cols = append(cols, gcPausesColumns...)
+	}
+
+	if c.rates {
+		cols = append(cols, ratesColumns...)
+	}
+
+	if c.overhead {
+		cols = append(cols, overheadColumns...)
+	}
+
+	return
+}
+
+// seconds converts seconds to nanoseconds.
+func seconds(s float64) float64 {
+	return s * float64(time.Second)
+}
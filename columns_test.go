@@ -0,0 +1,75 @@
+package pprofrec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnsFilter(t *testing.T) {
+	cols := getColumns(capabilities{memoryInfoStat: true})
+
+	names := func(cols []column) (ns []string) {
+		for _, col := range cols {
+			ns = append(ns, col.name)
+		}
+
+		return
+	}
+
+	assert.Equal(t, []string{"goroutine", "HeapAlloc", "RSS"}, names(Columns{Include: []string{"goroutine", "memstats.heapalloc", "RSS"}}.filter(cols)))
+	assert.Equal(t, []string{"RSS", "VMS"}, names(Columns{Include: []string{"memoryinfo"}, Exclude: []string{"HWM", "Data", "Stack", "Locked", "Swap"}}.filter(cols)))
+	assert.Len(t, Columns{Exclude: []string{"pprof"}}.filter(cols), len(cols)-len(pprofColumns))
+	assert.Len(t, Columns{}.filter(cols), len(cols))
+}
+
+func TestSamplerSkipsDeselectedGroups(t *testing.T) {
+	s := newSampler(context.Background(), nil, nil, Columns{Include: []string{"goroutine"}})
+
+	r := s.getRecord(context.Background())
+	assert.NotZero(t, r.Pprof.Goroutine)
+	assert.Zero(t, r.MemStats)
+	assert.Nil(t, r.RuntimeMetrics)
+	assert.Nil(t, r.MemoryInfo)
+}
+
+func TestSamplerCtxSwitchesAndPageFaults(t *testing.T) {
+	s := newSampler(context.Background(), nil, nil, Columns{Include: []string{"ctxswitches", "pagefaults"}})
+	require.Len(t, s.cols, len(ctxSwitchesColumns)+len(pageFaultsColumns))
+
+	r := s.getRecord(context.Background())
+	require.NotNil(t, r.NumCtxSwitches)
+	require.NotNil(t, r.PageFaults)
+	assert.Nil(t, r.MemoryInfo)
+	assert.NotZero(t, s.cols[0].value(r)+s.cols[1].value(r))
+	assert.NotZero(t, s.cols[2].value(r))
+}
+
+func TestGetQueryColumns(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?cols=goroutine,+HeapAlloc,-cputimes,,", nil)
+	assert.Equal(t, Columns{Include: []string{"goroutine", "HeapAlloc"}, Exclude: []string{"cputimes"}}, getQueryColumns(r))
+
+	r = httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	assert.Equal(t, Columns{}, getQueryColumns(r))
+}
+
+func TestWindowQueryColumns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := Window(ctx, WindowOpts{Window: time.Second, Frequency: 100 * time.Millisecond})
+
+	time.Sleep(250 * time.Millisecond)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=csv&cols=goroutine,HeapAlloc", nil)
+	w := httptest.NewRecorder()
+	f(w, r)
+
+	assert.True(t, strings.HasPrefix(w.Body.String(), "time,pprof.Lookup.goroutine,runtime.MemStats.HeapAlloc,annotations,tags\n"))
+}
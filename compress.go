@@ -0,0 +1,66 @@
+package pprofrec
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipResponseWriter compresses the response, Flush flushes the compressed data
+// so that streamed rows reach the client immediately.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	zw *gzip.Writer
+}
+
+// newGzipResponseWriter returns a writer that compresses the response written to w.
+func newGzipResponseWriter(w http.ResponseWriter) *gzipResponseWriter {
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Del("Content-Length")
+
+	return &gzipResponseWriter{ResponseWriter: w, zw: gzip.NewWriter(w)}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.zw.Write(b)
+}
+
+// Flush flushes the compressed data and the underlying writer if it is a http.Flusher.
+func (w *gzipResponseWriter) Flush() {
+	_ = w.zw.Flush()
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes the remaining compressed data.
+func (w *gzipResponseWriter) Close() error {
+	return w.zw.Close()
+}
+
+// acceptsGzip reports whether the request accepts gzip encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, e := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(e, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				if err != nil || q == 0 {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+package pprofrec
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for encoding, ok := range map[string]bool{
+		"":                       false,
+		"gzip":                   true,
+		"deflate, gzip;q=0.8":    true,
+		"gzip;q=0":               false,
+		"br, gzip ; q=1.0, *":    true,
+		"x-gzip":                 false,
+		"identity;q=1, gzip;q=x": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		r.Header.Set("Accept-Encoding", encoding)
+
+		assert.Equal(t, ok, acceptsGzip(r), encoding)
+	}
+}
+
+func TestWindowGzip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := Window(ctx, WindowOpts{Window: time.Second, Frequency: 10 * time.Millisecond})
+
+	time.Sleep(50 * time.Millisecond)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "<table>")
+}
+
+func TestStreamGzip(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: 100 * time.Millisecond})
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost:8080?format=ndjson&window=250ms", http.NoBody)
+	require.NoError(t, err)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	f(w, r)
+
+	assert.True(t, w.Flushed)
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"goroutine"`)
+}
@@ -0,0 +1,57 @@
+package pprofrec
+
+import (
+	"context"
+	"os"
+
+	"github.com/shirou/gopsutil/net"
+)
+
+// connectionStates are the TCP states that connections are counted by,
+// besides the total they indicate connection leaks and ephemeral port exhaustion.
+var connectionStates = []string{"ESTABLISHED", "TIME_WAIT", "CLOSE_WAIT"}
+
+// ConnectionsCollector collects the number of open TCP and UDP connections in total and by TCP state.
+type ConnectionsCollector struct {
+	// Pid is the process whose connections are counted, defaults to the current process.
+	Pid int32
+	// Host counts the connections of all processes of the host instead,
+	// connections in TIME_WAIT are closed by the process and are only counted for the host.
+	Host bool
+}
+
+// Name returns connections.
+func (c ConnectionsCollector) Name() string {
+	return "connections"
+}
+
+// Collect counts the current connections, the counts are zero if they are not available on the current OS.
+func (c ConnectionsCollector) Collect(ctx context.Context) []Sample {
+	pid := c.Pid
+	if pid == 0 {
+		pid = int32(os.Getpid())
+	}
+
+	var cs []net.ConnectionStat
+	var err error
+	if c.Host {
+		cs, err = net.ConnectionsWithContext(ctx, "inet")
+	} else {
+		cs, err = net.ConnectionsPidWithContext(ctx, "inet", pid)
+	}
+	if err != nil {
+		cs = nil
+	}
+
+	counts := make(map[string]int, len(connectionStates))
+	for _, c := range cs {
+		counts[c.Status]++
+	}
+
+	ss := []Sample{{Name: "total", Value: float64(len(cs))}}
+	for _, s := range connectionStates {
+		ss = append(ss, Sample{Name: s, Value: float64(counts[s])})
+	}
+
+	return ss
+}
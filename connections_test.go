@@ -0,0 +1,34 @@
+package pprofrec
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionsCollector(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	accepted, err := l.Accept()
+	require.NoError(t, err)
+	defer accepted.Close()
+
+	ss := ConnectionsCollector{}.Collect(context.Background())
+	require.Len(t, ss, 4)
+	assert.Equal(t, "total", ss[0].Name)
+	assert.True(t, ss[0].Value >= 3)
+	assert.Equal(t, "ESTABLISHED", ss[1].Name)
+	assert.True(t, ss[1].Value >= 2)
+
+	ss = ConnectionsCollector{Host: true}.Collect(context.Background())
+	assert.True(t, ss[1].Value >= 2)
+}
@@ -0,0 +1,78 @@
+package pprofrec
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ConnStateCollector collects the connection states of http.Servers hooked via Hook,
+// to correlate goroutine and memory growth with connection churn.
+// The states are recorded as the number of new, active and idle connections
+// and the number of connections accepted and hijacked since the first hooked server started.
+type ConnStateCollector struct {
+	mu       sync.Mutex
+	states   map[net.Conn]http.ConnState
+	accepted int
+	hijacked int
+}
+
+// Hook sets the ConnState of srv to track its connections, a previously set ConnState is still called.
+// It has to be called before the server starts serving.
+func (c *ConnStateCollector) Hook(srv *http.Server) {
+	next := srv.ConnState
+	srv.ConnState = func(conn net.Conn, state http.ConnState) {
+		c.track(conn, state)
+
+		if next != nil {
+			next(conn, state)
+		}
+	}
+}
+
+func (c *ConnStateCollector) track(conn net.Conn, state http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.states == nil {
+		c.states = map[net.Conn]http.ConnState{}
+	}
+
+	switch state {
+	case http.StateNew:
+		c.accepted++
+		c.states[conn] = state
+	case http.StateHijacked:
+		c.hijacked++
+		delete(c.states, conn)
+	case http.StateClosed:
+		delete(c.states, conn)
+	default:
+		c.states[conn] = state
+	}
+}
+
+// Name returns connstate.
+func (c *ConnStateCollector) Name() string {
+	return "connstate"
+}
+
+// Collect counts the current connections by state.
+func (c *ConnStateCollector) Collect(ctx context.Context) []Sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := map[http.ConnState]int{}
+	for _, s := range c.states {
+		counts[s]++
+	}
+
+	return []Sample{
+		{Name: "new", Value: float64(counts[http.StateNew])},
+		{Name: "active", Value: float64(counts[http.StateActive])},
+		{Name: "idle", Value: float64(counts[http.StateIdle])},
+		{Name: "accepted", Value: float64(c.accepted)},
+		{Name: "hijacked", Value: float64(c.hijacked)},
+	}
+}
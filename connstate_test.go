@@ -0,0 +1,51 @@
+package pprofrec
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnStateCollector(t *testing.T) {
+	c := &ConnStateCollector{}
+	assert.Equal(t, "connstate", c.Name())
+
+	var called int
+	srv := &http.Server{ConnState: func(conn net.Conn, state http.ConnState) { called++ }}
+	c.Hook(srv)
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	h, _ := net.Pipe()
+	defer h.Close()
+
+	srv.ConnState(a, http.StateNew)
+	srv.ConnState(b, http.StateNew)
+	srv.ConnState(h, http.StateNew)
+	srv.ConnState(a, http.StateActive)
+	srv.ConnState(b, http.StateActive)
+	srv.ConnState(b, http.StateIdle)
+	srv.ConnState(h, http.StateActive)
+	srv.ConnState(h, http.StateHijacked)
+	assert.Equal(t, 8, called)
+
+	ss := c.Collect(context.Background())
+	require.Len(t, ss, 5)
+	assert.Equal(t, []Sample{
+		{Name: "new", Value: 0},
+		{Name: "active", Value: 1},
+		{Name: "idle", Value: 1},
+		{Name: "accepted", Value: 3},
+		{Name: "hijacked", Value: 1},
+	}, ss)
+
+	srv.ConnState(b, http.StateClosed)
+
+	ss = c.Collect(context.Background())
+	assert.Equal(t, float64(0), ss[2].Value)
+}
@@ -0,0 +1,156 @@
+package pprofrec
+
+// columnControls lets the viewer show and hide groups and columns of the html table,
+// the choices are persisted in localStorage per path.
+// It is written into the table body right after the head so that it runs while rows are still streamed,
+// hidden columns are hidden by a stylesheet that applies to rows that are rendered later on as well.
+const columnControls = `
+<script>
+(function () {
+	var table = document.currentScript.closest("table");
+	var key = "pprofrec.hidden." + location.pathname;
+	var groups = Array.prototype.slice.call(table.querySelectorAll(".tbl__head1 th[data-group]"));
+	var cols = Array.prototype.slice.call(table.querySelectorAll(".tbl__head2 th[data-col]"));
+	// each column spans the cells that are rendered per column, its value and its delta by default
+	var cells = cols.length ? cols[0].colSpan : 2;
+
+	var hidden = {};
+	try {
+		(JSON.parse(localStorage.getItem(key)) || []).forEach(function (c) { hidden[c] = true; });
+	} catch (e) {}
+
+	var style = document.createElement("style");
+	document.head.appendChild(style);
+
+	var panel = document.createElement("details");
+	panel.className = "cols";
+	panel.innerHTML = "<summary>columns</summary>";
+	table.parentNode.insertBefore(panel, table);
+
+	var boxes = {};
+
+	function apply() {
+		var rules = [];
+		cols.forEach(function (th, i) {
+			if (hidden[th.dataset.col]) {
+				rules.push(".tbl__head2 th:nth-child(" + (i + 2) + ")");
+				for (var j = 0; j < cells; j++) {
+					rules.push("tbody tr:not(.tbl__row--annotation) td:nth-child(" + (cells * i + j + 2) + ")");
+				}
+			}
+		});
+		style.textContent = rules.length ? rules.join(", ") + " { display: none; }" : "";
+
+		groups.forEach(function (th) {
+			var n = cols.filter(function (c) { return c.dataset.group === th.dataset.group && !hidden[c.dataset.col]; }).length;
+			th.colSpan = Math.max(cells * n, 1);
+			th.style.display = n === 0 ? "none" : "";
+			boxes[th.dataset.group].checked = n > 0;
+			boxes[th.dataset.group].indeterminate = n > 0 && n < cols.filter(function (c) { return c.dataset.group === th.dataset.group; }).length;
+		});
+		cols.forEach(function (th) { boxes[th.dataset.col].checked = !hidden[th.dataset.col]; });
+
+		try {
+			localStorage.setItem(key, JSON.stringify(Object.keys(hidden)));
+		} catch (e) {}
+	}
+
+	function checkbox(label, onchange) {
+		var el = document.createElement("label");
+		var box = document.createElement("input");
+		box.type = "checkbox";
+		box.onchange = onchange;
+		el.appendChild(box);
+		el.appendChild(document.createTextNode(label));
+		return {label: el, box: box};
+	}
+
+	groups.forEach(function (g) {
+		var div = document.createElement("div");
+		var c = checkbox(g.textContent, function () {
+			var show = c.box.checked;
+			cols.forEach(function (th) {
+				if (th.dataset.group === g.dataset.group) {
+					if (show) {
+						delete hidden[th.dataset.col];
+					} else {
+						hidden[th.dataset.col] = true;
+					}
+				}
+			});
+			apply();
+		});
+		boxes[g.dataset.group] = c.box;
+		div.appendChild(c.label);
+
+		cols.forEach(function (th) {
+			if (th.dataset.group !== g.dataset.group) {
+				return;
+			}
+			var cc = checkbox(th.dataset.col.slice(th.dataset.col.indexOf(".") + 1), function () {
+				if (cc.box.checked) {
+					delete hidden[th.dataset.col];
+				} else {
+					hidden[th.dataset.col] = true;
+				}
+				apply();
+			});
+			boxes[th.dataset.col] = cc.box;
+			div.appendChild(cc.label);
+		});
+
+		panel.appendChild(div);
+	});
+
+	apply();
+})();
+</script>
+`
+
+// pauseControl lets the viewer pause rendering streamed rows while the connection is kept,
+// rows that arrive while paused are held back and rendered on resume.
+// The page starts paused if the request specifies ?paused=true.
+const pauseControl = `
+<script>
+(function () {
+	var table = document.currentScript.closest("table");
+	var tbody = document.currentScript.parentNode;
+	var paused = new URLSearchParams(location.search).get("paused") === "true";
+	var held = [];
+
+	var button = document.createElement("button");
+	button.className = "pause";
+	table.parentNode.insertBefore(button, table);
+
+	function render() {
+		button.textContent = paused ? "resume (" + held.length + " new rows)" : "pause";
+	}
+
+	button.onclick = function () {
+		paused = !paused;
+		if (!paused) {
+			held.forEach(function (tr) { tr.style.display = ""; });
+			held = [];
+		}
+		render();
+	};
+
+	new MutationObserver(function (ms) {
+		if (!paused) {
+			return;
+		}
+		ms.forEach(function (m) {
+			m.addedNodes.forEach(function (n) {
+				if (n.nodeName === "TR") {
+					n.style.display = "none";
+					held.push(n);
+				}
+			});
+		});
+		render();
+	}).observe(tbody, {childList: true});
+
+	render();
+})();
+</script>
+`
@@ -0,0 +1,37 @@
+package pprofrec
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHeadColumnControls(t *testing.T) {
+	cols := Columns{Include: []string{"goroutine", "HeapAlloc"}}.filter(getColumns(capabilities{}))
+
+	var b bytes.Buffer
+	require.NoError(t, writeHead(&b, cols, nil, LightTheme, display{}))
+
+	assert.Contains(t, b.String(), `<th colspan="2" data-group="pprof">`)
+	assert.Contains(t, b.String(), `data-group="memstats" data-col="memstats.HeapAlloc"`)
+	assert.Contains(t, b.String(), `data-group="pprof" data-col="pprof.goroutine"`)
+	assert.Contains(t, b.String(), columnControls)
+}
+
+func TestStreamPauseControl(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: 100 * time.Millisecond})
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost:8080?window=150ms", http.NoBody)
+	require.NoError(t, err)
+
+	w := &responseWriter{}
+	f(w, r)
+
+	assert.Contains(t, w.Buffer.String(), pauseControl)
+	assert.Less(t, strings.Index(w.Buffer.String(), pauseControl), strings.Index(w.Buffer.String(), "<tr>"))
+}
@@ -0,0 +1,45 @@
+package pprofrec
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+)
+
+// DBStatsCollector collects the connection pool stats of database/sql handles,
+// as pool exhaustion usually correlates with growing goroutines and latencies.
+// The stats are recorded per handle as the number of open, in use and idle connections,
+// the number of waits for a connection and the total time waited.
+type DBStatsCollector struct {
+	// DBs are the handles to collect keyed by the name their columns are prefixed with, e.g. primary.
+	DBs map[string]*sql.DB
+}
+
+// Name returns dbstats.
+func (c DBStatsCollector) Name() string {
+	return "dbstats"
+}
+
+// Collect returns the current stats of the handles ordered by name.
+func (c DBStatsCollector) Collect(ctx context.Context) []Sample {
+	names := make([]string, 0, len(c.DBs))
+	for n := range c.DBs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var ss []Sample
+	for _, n := range names {
+		s := c.DBs[n].Stats()
+
+		ss = append(ss,
+			Sample{Name: n + ".open", Value: float64(s.OpenConnections)},
+			Sample{Name: n + ".inUse", Value: float64(s.InUse)},
+			Sample{Name: n + ".idle", Value: float64(s.Idle)},
+			Sample{Name: n + ".waitCount", Value: float64(s.WaitCount)},
+			Sample{Name: n + ".waitDuration", Value: s.WaitDuration.Seconds(), Unit: UnitSeconds},
+		)
+	}
+
+	return ss
+}
@@ -0,0 +1,61 @@
+package pprofrec
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDriver struct{}
+
+func (d testDriver) Open(name string) (driver.Conn, error) {
+	return testConn{}, nil
+}
+
+type testConn struct{}
+
+func (c testConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c testConn) Close() error {
+	return nil
+}
+
+func (c testConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func init() {
+	sql.Register("pprofrec-test", testDriver{})
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	primary, err := sql.Open("pprofrec-test", "")
+	require.NoError(t, err)
+	defer primary.Close()
+
+	replica, err := sql.Open("pprofrec-test", "")
+	require.NoError(t, err)
+	defer replica.Close()
+
+	conn, err := primary.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	c := DBStatsCollector{DBs: map[string]*sql.DB{"replica": replica, "primary": primary}}
+	assert.Equal(t, "dbstats", c.Name())
+
+	ss := c.Collect(context.Background())
+	require.Len(t, ss, 10)
+	assert.Equal(t, Sample{Name: "primary.open", Value: 1}, ss[0])
+	assert.Equal(t, Sample{Name: "primary.inUse", Value: 1}, ss[1])
+	assert.Equal(t, Sample{Name: "primary.idle", Value: 0}, ss[2])
+	assert.Equal(t, Sample{Name: "primary.waitDuration", Value: 0, Unit: UnitSeconds}, ss[4])
+	assert.Equal(t, Sample{Name: "replica.open", Value: 0}, ss[5])
+}
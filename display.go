@@ -0,0 +1,134 @@
+package pprofrec
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TimeFormatUnixMilli renders times as milliseconds since the unix epoch.
+const TimeFormatUnixMilli = "unixmilli"
+
+// unitsRaw renders bytes and durations as exact integers, selected by ?units=raw.
+const unitsRaw = "raw"
+
+// DisplayMode selects the cells that are rendered per column of the html table.
+type DisplayMode string
+
+const (
+	// DisplayBoth renders the value and the delta to the previous row of every column.
+	DisplayBoth DisplayMode = ""
+	// DisplayValues renders the values only.
+	DisplayValues DisplayMode = "values"
+	// DisplayDeltas renders the deltas to the previous row only.
+	DisplayDeltas DisplayMode = "deltas"
+)
+
+// TimeOpts configures how the time column of the html table is rendered.
+type TimeOpts struct {
+	// Format is the layout of the time column, e.g. time.RFC3339, or TimeFormatUnixMilli, defaults to 15:04:05.
+	Format string
+	// Location is the timezone that times are rendered in, defaults to time.Local.
+	// It can be overridden per request by ?tz=UTC or any other IANA timezone, e.g. ?tz=Europe/Berlin.
+	Location *time.Location
+}
+
+// display configures how the html table renders values, its zero value renders the defaults.
+type display struct {
+	timeFormat string
+	location   *time.Location
+	// raw renders bytes and durations as exact integers, durations in nanoseconds.
+	raw  bool
+	mode DisplayMode
+	// newestFirst renders the latest row at the top.
+	newestFirst bool
+}
+
+// getQueryDisplay returns d overridden by the query parameters of r.
+func getQueryDisplay(r *http.Request, d display) (display, error) {
+	switch q := r.URL.Query().Get("order"); q {
+	case "":
+	case "asc":
+		d.newestFirst = false
+	case "desc":
+		d.newestFirst = true
+	default:
+		return display{}, fmt.Errorf("invalid order: must be asc or desc")
+	}
+
+	switch q := r.URL.Query().Get("display"); q {
+	case "":
+	case "both":
+		d.mode = DisplayBoth
+	case string(DisplayValues), string(DisplayDeltas):
+		d.mode = DisplayMode(q)
+	default:
+		return display{}, fmt.Errorf("invalid display: must be both, values or deltas")
+	}
+
+	switch units := r.URL.Query().Get("units"); units {
+	case "", "human":
+	case unitsRaw:
+		d.raw = true
+	default:
+		return display{}, fmt.Errorf("invalid units: must be raw or human")
+	}
+
+	tz := r.URL.Query().Get("tz")
+	if tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return display{}, fmt.Errorf("invalid tz: %v", tz)
+		}
+
+		d.location = location
+	}
+
+	return d, nil
+}
+
+// appendTime appends t in the time format and location of d.
+func (d display) appendTime(b []byte, t time.Time) []byte {
+	if d.timeFormat == TimeFormatUnixMilli {
+		return strconv.AppendInt(b, t.UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	return t.In(d.getLocation()).AppendFormat(b, d.getTimeFormat())
+}
+
+// cells returns the number of cells that are rendered per column.
+func (d display) cells() int {
+	if d.mode == DisplayValues || d.mode == DisplayDeltas {
+		return 1
+	}
+
+	return 2
+}
+
+// getUnit returns the unit that values of u are rendered as, bytes and durations are rendered as counts if raw.
+func (d display) getUnit(u unit) unit {
+	if d.raw && (u == unitBytes || u == unitDuration) {
+		return unitCount
+	}
+
+	return u
+}
+
+// getLocation returns the location of d, time.Local if unset.
+func (d display) getLocation() *time.Location {
+	if d.location == nil {
+		return time.Local
+	}
+
+	return d.location
+}
+
+// getTimeFormat returns the time format of d, 15:04:05 if unset.
+func (d display) getTimeFormat() string {
+	if d.timeFormat == "" {
+		return "15:04:05"
+	}
+
+	return d.timeFormat
+}
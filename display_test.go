@@ -0,0 +1,162 @@
+package pprofrec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisplayAppendTime(t *testing.T) {
+	ts := time.Date(2021, 10, 1, 12, 0, 1, 500*int(time.Millisecond), time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	assert.Equal(t, ts.Local().Format("15:04:05"), string(display{}.appendTime(nil, ts)))
+	assert.Equal(t, "14:00:01", string(display{location: berlin}.appendTime(nil, ts)))
+	assert.Equal(t, "2021-10-01T12:00:01Z", string(display{timeFormat: time.RFC3339, location: time.UTC}.appendTime(nil, ts)))
+	assert.Equal(t, "1633089601500", string(display{timeFormat: TimeFormatUnixMilli, location: berlin}.appendTime(nil, ts)))
+}
+
+func TestGetQueryDisplay(t *testing.T) {
+	d, err := getQueryDisplay(httptest.NewRequest(http.MethodGet, "/", http.NoBody), display{timeFormat: time.RFC3339})
+	require.NoError(t, err)
+	assert.Equal(t, display{timeFormat: time.RFC3339}, d)
+
+	d, err = getQueryDisplay(httptest.NewRequest(http.MethodGet, "/?tz=UTC", http.NoBody), display{location: time.Local})
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, d.location)
+
+	_, err = getQueryDisplay(httptest.NewRequest(http.MethodGet, "/?tz=Nowhere/Unknown", http.NoBody), display{})
+	assert.Error(t, err)
+}
+
+func TestWindowTimezone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: time.Second, Columns: Columns{Include: []string{"pprof"}}})
+	for _, r := range goroutineRecords(time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC), 10, 20, 30) {
+		rec.rs.push(r)
+	}
+
+	h := Window(ctx, WindowOpts{Recorder: rec, Time: TimeOpts{Location: time.UTC}})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<td class="tbl__col1">12:00:01</td>`)
+
+	// clock times of the time range refer to the timezone of ?tz=
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?tz=Europe/Berlin&from=14:00:01", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<td class="tbl__col1">14:00:02</td>`)
+	assert.NotContains(t, w.Body.String(), `<td class="tbl__col1">14:00:00</td>`)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?tz=Nowhere/Unknown", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetQueryDisplayUnits(t *testing.T) {
+	d, err := getQueryDisplay(httptest.NewRequest(http.MethodGet, "/?units=raw", http.NoBody), display{})
+	require.NoError(t, err)
+	assert.True(t, d.raw)
+
+	d, err = getQueryDisplay(httptest.NewRequest(http.MethodGet, "/?units=human", http.NoBody), display{})
+	require.NoError(t, err)
+	assert.False(t, d.raw)
+
+	_, err = getQueryDisplay(httptest.NewRequest(http.MethodGet, "/?units=si", http.NoBody), display{})
+	assert.Error(t, err)
+}
+
+func TestAppendColRaw(t *testing.T) {
+	heapAlloc := Columns{Include: []string{"HeapAlloc"}}.filter(getColumns(capabilities{}))[0]
+	previous := Record{MemStats: MemStats{HeapAlloc: 1 << 20}}
+	current := Record{MemStats: MemStats{HeapAlloc: 3 << 20}}
+
+	assert.Equal(t, `</td><td style="padding-left: 10px;">3.000 MiB</td><td style="color: green;">2.000 MiB`,
+		string(appendCol(nil, heapAlloc, previous, current, Highlight{}, display{})))
+	assert.Equal(t, `</td><td style="padding-left: 10px;">3145728</td><td style="color: green;">2097152`,
+		string(appendCol(nil, heapAlloc, previous, current, Highlight{}, display{raw: true})))
+
+	p99 := schedLatenciesColumns[1]
+	r := Record{SchedLatencies: &SchedLatencies{P99: 0.0015}}
+	assert.Equal(t, `</td><td style="padding-left: 10px;">1500000</td><td style="color: gray;">0`,
+		string(appendCol(nil, p99, r, r, Highlight{}, display{raw: true})))
+}
+
+func TestAppendColDisplayMode(t *testing.T) {
+	goroutine := pprofColumns[0]
+	previous := Record{Pprof: PprofStat{Goroutine: 10}}
+	current := Record{Pprof: PprofStat{Goroutine: 6}}
+	h := Highlight{Warning: 5}
+
+	assert.Equal(t, `</td><td class="tbl__cell--warning" style="padding-left: 10px;">6</td><td style="color: red;">-4`,
+		string(appendCol(nil, goroutine, previous, current, h, display{})))
+	assert.Equal(t, `</td><td class="tbl__cell--warning" style="padding-left: 10px;">6`,
+		string(appendCol(nil, goroutine, previous, current, h, display{mode: DisplayValues})))
+	assert.Equal(t, `</td><td class="tbl__cell--warning" style="padding-left: 10px; color: red;">-4`,
+		string(appendCol(nil, goroutine, previous, current, h, display{mode: DisplayDeltas})))
+}
+
+func TestWindowDisplayMode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: time.Second, Columns: Columns{Include: []string{"goroutine", "threadcreate"}}})
+	for _, r := range goroutineRecords(time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC), 10, 20, 30) {
+		rec.rs.push(r)
+	}
+
+	h := Window(ctx, WindowOpts{Recorder: rec, Display: DisplayValues})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<th colspan="1" data-group="pprof" data-col="pprof.goroutine">`)
+	assert.NotContains(t, w.Body.String(), `color: green;`)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?display=both", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<th colspan="2" data-group="pprof" data-col="pprof.goroutine">`)
+	assert.Contains(t, w.Body.String(), `color: green;`)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?display=sideways", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWindowNewestFirst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: time.Second, Columns: Columns{Include: []string{"pprof"}}})
+	for _, r := range goroutineRecords(time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC), 10, 20, 30) {
+		rec.rs.push(r)
+	}
+
+	h := Window(ctx, WindowOpts{Recorder: rec, Time: TimeOpts{Location: time.UTC}, NewestFirst: true})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Index(w.Body.String(), ">12:00:02<") < strings.Index(w.Body.String(), ">12:00:01<"))
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?order=asc", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Index(w.Body.String(), ">12:00:01<") < strings.Index(w.Body.String(), ">12:00:02<"))
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?order=random", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,31 @@
+package pprofrec
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// reportError passes err to onError, err is logged if onError is nil.
+func reportError(onError func(error), err error) {
+	if onError != nil {
+		onError(err)
+
+		return
+	}
+
+	log.Printf("pprofrec: %v", err.Error())
+}
+
+// closeBody closes the body of r and reports the error to onError, requests without a body are skipped
+// as handlers may be called directly with a nil body.
+func closeBody(onError func(error), r *http.Request) {
+	if r.Body == nil {
+		return
+	}
+
+	err := r.Body.Close()
+	if err != nil {
+		reportError(onError, fmt.Errorf("failed to close request body: %w", err))
+	}
+}
@@ -0,0 +1,80 @@
+package pprofrec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingSink struct{}
+
+func (failingSink) Write(ctx context.Context, r Record, ms []Metric) error {
+	return errors.New("unavailable")
+}
+
+func TestReportError(t *testing.T) {
+	var errs []error
+	reportError(func(err error) { errs = append(errs, err) }, errors.New("failed"))
+	assert.Equal(t, []error{errors.New("failed")}, errs)
+
+	// logged without a handler
+	reportError(nil, errors.New("failed"))
+}
+
+type failingBody struct{}
+
+func (failingBody) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (failingBody) Close() error {
+	return errors.New("closed")
+}
+
+func TestCloseBody(t *testing.T) {
+	var errs []error
+	onError := func(err error) { errs = append(errs, err) }
+
+	closeBody(onError, &http.Request{})
+	assert.Empty(t, errs)
+
+	closeBody(onError, &http.Request{Body: ioutil.NopCloser(strings.NewReader(""))})
+	assert.Empty(t, errs)
+
+	closeBody(onError, &http.Request{Body: failingBody{}})
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "failed to close request body: closed")
+}
+
+func TestRecorderOnError(t *testing.T) {
+	errs := make(chan error, 10)
+	rec := newTestRecorder(t, RecorderOpts{
+		Frequency: 10 * time.Millisecond,
+		Columns:   Columns{Include: []string{"goroutine"}},
+		Sinks:     []Sink{failingSink{}},
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+
+	rec.Start(context.Background())
+	defer rec.Stop()
+
+	select {
+	case err := <-errs:
+		assert.EqualError(t, err, "failed to write record to sink: unavailable")
+	case <-time.After(time.Second):
+		t.Fatal("OnError was not called")
+	}
+}
@@ -0,0 +1,57 @@
+package pprofrec
+
+import (
+	"expvar"
+	"log"
+	"sync"
+)
+
+// expvarName is the name of the expvar map the latest recorded values are published under.
+const expvarName = "pprofrec"
+
+// expvarMu guards publishing the expvar map and its values.
+var expvarMu sync.Mutex
+
+// expvarPublisher publishes the latest recorded values of columns via expvar.
+type expvarPublisher struct {
+	cols   []column
+	values []*expvar.Float
+}
+
+// newExpvarPublisher publishes cols under the pprofrec expvar map, the map is shared
+// with other publishers, so that values of the same column are overwritten by the latest publisher.
+func newExpvarPublisher(cols []column) *expvarPublisher {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	m, ok := expvar.Get(expvarName).(*expvar.Map)
+	if !ok {
+		if expvar.Get(expvarName) != nil {
+			log.Printf("pprofrec: failed to publish expvar: %v is already published", expvarName)
+
+			return nil
+		}
+
+		m = expvar.NewMap(expvarName)
+	}
+
+	p := &expvarPublisher{cols: cols, values: make([]*expvar.Float, len(cols))}
+	for i, col := range cols {
+		v, ok := m.Get(col.qualifiedName()).(*expvar.Float)
+		if !ok {
+			v = new(expvar.Float)
+			m.Set(col.qualifiedName(), v)
+		}
+
+		p.values[i] = v
+	}
+
+	return p
+}
+
+// publish sets the published values to the values of r.
+func (p *expvarPublisher) publish(r Record) {
+	for i, col := range p.cols {
+		p.values[i].Set(col.value(r))
+	}
+}
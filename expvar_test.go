@@ -0,0 +1,43 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvarPublisher(t *testing.T) {
+	cols := Columns{Include: []string{"goroutine"}}.filter(getColumns(capabilities{}))
+
+	p := newExpvarPublisher(cols)
+	require.NotNil(t, p)
+	p.publish(goroutineRecords(time.Now(), 42)[0])
+
+	// a second publisher shares the values
+	q := newExpvarPublisher(cols)
+	require.NotNil(t, q)
+	assert.Equal(t, p.values, q.values)
+
+	var m map[string]float64
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("pprofrec").String()), &m))
+	assert.Equal(t, float64(42), m["pprof.Lookup.goroutine"])
+}
+
+func TestRecorderExpvar(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{Frequency: 10 * time.Millisecond, Expvar: true, Columns: Columns{Include: []string{"HeapAlloc"}}})
+	rec.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	rec.Stop()
+
+	latest, ok := rec.Latest()
+	require.True(t, ok)
+
+	v, ok := expvar.Get("pprofrec").(*expvar.Map).Get("runtime.MemStats.HeapAlloc").(*expvar.Float)
+	require.True(t, ok)
+	assert.Equal(t, float64(latest.MemStats.HeapAlloc), v.Value())
+}
@@ -0,0 +1,180 @@
+package pprofrec
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	formatHTML   = "html"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatSSE    = "sse"
+)
+
+// getFormat determines the response format from the format query parameter,
+// falling back to the Accept header.
+func getFormat(r *http.Request) string {
+	f := r.URL.Query().Get("format")
+	if f != "" {
+		return f
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/json"):
+		return formatJSON
+	case strings.Contains(accept, "application/x-ndjson"):
+		return formatNDJSON
+	case strings.Contains(accept, "text/csv"):
+		return formatCSV
+	case strings.Contains(accept, "text/event-stream"):
+		return formatSSE
+	}
+
+	return formatHTML
+}
+
+// getDownloadFilename returns the filename of a download in format of the records up to t, which defaults to now if zero.
+func getDownloadFilename(t time.Time, format string) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	return fmt.Sprintf("pprofrec-%v.%v", t.Format("20060102T150405"), format)
+}
+
+func writeJSON(w io.Writer, rs []Record) (err error) {
+	if rs == nil {
+		rs = []Record{}
+	}
+
+	err = json.NewEncoder(w).Encode(rs)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func writeNDJSON(w io.Writer, r Record) (err error) {
+	err = json.NewEncoder(w).Encode(r)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// writeCSV writes a header row with the qualified column names followed by a row per record.
+// Durations are written in seconds, or in nanoseconds if raw, and times in RFC 3339.
+func writeCSV(w io.Writer, cols []column, rs []Record, raw bool) (err error) {
+	cw := csv.NewWriter(w)
+
+	err = writeCSVHeader(cw, cols)
+	if err != nil {
+		return
+	}
+
+	for _, r := range rs {
+		err = writeCSVRecord(cw, cols, r, raw)
+		if err != nil {
+			return
+		}
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// writeCSVHeader writes the header row of writeCSV.
+func writeCSVHeader(cw *csv.Writer, cols []column) error {
+	row := make([]string, 0, len(cols)+3)
+	row = append(row, "time")
+	for _, col := range cols {
+		row = append(row, col.qualifiedName())
+	}
+	row = append(row, "annotations", "tags")
+
+	return cw.Write(row)
+}
+
+// writeCSVRecord writes the row of r of writeCSV.
+func writeCSVRecord(cw *csv.Writer, cols []column, r Record, raw bool) error {
+	row := make([]string, 0, len(cols)+3)
+	row = append(row, r.Time.Format(time.RFC3339Nano))
+	for _, col := range cols {
+		row = append(row, formatCSVValue(col.unit, col.value(r), raw))
+	}
+
+	labels := make([]string, len(r.Annotations))
+	for i, a := range r.Annotations {
+		labels[i] = a.Label
+	}
+	row = append(row, strings.Join(labels, "; "), formatTags(r.Tags))
+
+	return cw.Write(row)
+}
+
+func formatCSVValue(u unit, v float64, raw bool) string {
+	switch {
+	case u == unitDuration && !raw:
+		return strconv.FormatFloat(time.Duration(v).Seconds(), 'f', -1, 64)
+	case u == unitTime:
+		return time.Unix(0, int64(v)).Format(time.RFC3339Nano)
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+// writeHeartbeat writes a heartbeat that clients ignore in the given format,
+// a comment in html and SSE and an empty line in NDJSON.
+func writeHeartbeat(w io.Writer, format string) (err error) {
+	switch format {
+	case formatNDJSON:
+		_, err = w.Write([]byte("\n"))
+	case formatSSE:
+		_, err = w.Write([]byte(": heartbeat\n\n"))
+	default:
+		_, err = w.Write([]byte("<!-- heartbeat -->\n"))
+	}
+
+	return
+}
+
+// writeSSE writes r as a server-sent event with the record encoded as JSON.
+func writeSSE(w io.Writer, r Record) (err error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write([]byte("data: "))
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write([]byte("\n\n"))
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,192 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowJSON(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := Window(ctx, WindowOpts{Window: time.Second, Frequency: 100 * time.Millisecond})
+
+	time.Sleep(350 * time.Millisecond)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=json", nil)
+	w := httptest.NewRecorder()
+	f(w, r)
+
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, strconv.Itoa(SchemaVersion), w.Header().Get("Pprofrec-Schema-Version"))
+
+	var rs []Record
+	err := json.Unmarshal(w.Body.Bytes(), &rs)
+	require.NoError(t, err)
+	require.NotEmpty(t, rs)
+	assert.NotZero(t, rs[0].Pprof.Goroutine)
+	assert.NotZero(t, rs[0].MemStats.HeapAlloc)
+}
+
+func TestWindowCSV(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := Window(ctx, WindowOpts{Window: time.Second, Frequency: 100 * time.Millisecond})
+
+	time.Sleep(350 * time.Millisecond)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=csv", nil)
+	w := httptest.NewRecorder()
+	f(w, r)
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.True(t, len(rows) > 1)
+	assert.Equal(t, []string{"time", "pprof.Lookup.goroutine", "pprof.Lookup.threadcreate"}, rows[0][:3])
+	assert.Contains(t, rows[0], "runtime.MemStats.HeapAlloc")
+	assert.Len(t, rows[1], len(rows[0]))
+}
+
+func TestFormatCSVValue(t *testing.T) {
+	assert.Equal(t, "1.5", formatCSVValue(unitDuration, 1.5e9, false))
+	assert.Equal(t, "1500000000", formatCSVValue(unitDuration, 1.5e9, true))
+	assert.Equal(t, "1536", formatCSVValue(unitBytes, 1536, true))
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:8080?format=ndjson", http.NoBody)
+	require.NoError(t, err)
+
+	w := &responseWriter{}
+	done := make(chan struct{})
+	go func() {
+		f(w, r)
+		close(done)
+	}()
+
+	time.Sleep(350 * time.Millisecond)
+	cancel()
+	<-done
+
+	lines := strings.Split(strings.TrimSpace(w.Buffer.String()), "\n")
+	require.True(t, len(lines) > 1)
+	for _, l := range lines {
+		var rec Record
+		err = json.Unmarshal([]byte(l), &rec)
+		require.NoError(t, err)
+		assert.NotZero(t, rec.Pprof.Goroutine)
+	}
+}
+
+func TestStreamSSE(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:8080", http.NoBody)
+	require.NoError(t, err)
+	r.Header.Set("Accept", "text/event-stream")
+
+	w := &responseWriter{}
+	done := make(chan struct{})
+	go func() {
+		f(w, r)
+		close(done)
+	}()
+
+	time.Sleep(350 * time.Millisecond)
+	cancel()
+	<-done
+
+	events := strings.Split(strings.TrimSpace(w.Buffer.String()), "\n\n")
+	require.True(t, len(events) > 1)
+	for _, e := range events {
+		require.True(t, strings.HasPrefix(e, "data: "))
+
+		var rec Record
+		err = json.Unmarshal([]byte(strings.TrimPrefix(e, "data: ")), &rec)
+		require.NoError(t, err)
+		assert.NotZero(t, rec.Pprof.Goroutine)
+	}
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: time.Hour, Heartbeat: 50 * time.Millisecond})
+
+	for _, tc := range []struct {
+		format    string
+		heartbeat string
+	}{
+		{formatHTML, "<!-- heartbeat -->\n"},
+		{formatSSE, ": heartbeat\n\n"},
+		{formatNDJSON, "\n"},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:8080?format="+tc.format, http.NoBody)
+		require.NoError(t, err)
+
+		w := &responseWriter{}
+		done := make(chan struct{})
+		go func() {
+			f(w, r)
+			close(done)
+		}()
+
+		time.Sleep(180 * time.Millisecond)
+		cancel()
+		<-done
+
+		assert.True(t, strings.HasSuffix(w.Buffer.String(), tc.heartbeat+tc.heartbeat), tc.format)
+	}
+}
+
+func TestGetFormat(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	assert.Equal(t, formatHTML, getFormat(r))
+
+	r.Header.Set("Accept", "application/json")
+	assert.Equal(t, formatJSON, getFormat(r))
+
+	r.Header.Set("Accept", "application/x-ndjson")
+	assert.Equal(t, formatNDJSON, getFormat(r))
+
+	r = httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=json", nil)
+	assert.Equal(t, formatJSON, getFormat(r))
+}
+
+func TestWindowDownload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := Window(ctx, WindowOpts{Window: time.Second, Frequency: 50 * time.Millisecond})
+
+	time.Sleep(150 * time.Millisecond)
+
+	for _, format := range []string{"html", "json", "csv"} {
+		w := httptest.NewRecorder()
+		f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?download="+format, http.NoBody))
+		require.Equal(t, http.StatusOK, w.Code, format)
+		assert.Regexp(t, `^attachment; filename="pprofrec-\d{8}T\d{6}\.`+format+`"$`, w.Header().Get("Content-Disposition"), format)
+		assert.NotEmpty(t, w.Body.String(), format)
+	}
+
+	w := httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?download=xml", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
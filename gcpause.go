@@ -0,0 +1,91 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// GCPauses summarizes the individual GC pauses that ended since the previous record,
+// read from the PauseNs circular buffer of runtime.MemStats, which holds the 256 most recent pauses.
+type GCPauses struct {
+	Count uint32 `json:"count"`
+	MaxNs uint64 `json:"maxNs"`
+}
+
+// Pause is an individual GC pause.
+type Pause struct {
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+}
+
+// getGCPauses summarizes the pauses of the GC cycles after previous, the NumGC of the previous read.
+func getGCPauses(ms *runtime.MemStats, previous uint32) (p GCPauses) {
+	p.Count = ms.NumGC - previous
+
+	for _, pause := range getPauses(ms, previous) {
+		if uint64(pause.Duration) > p.MaxNs {
+			p.MaxNs = uint64(pause.Duration)
+		}
+	}
+
+	return
+}
+
+// getPauses returns the pauses of the GC cycles after previous, ordered from oldest to latest,
+// pauses that were overwritten in the circular buffer since are skipped.
+func getPauses(ms *runtime.MemStats, previous uint32) (ps []Pause) {
+	n := ms.NumGC - previous
+	if n > uint32(len(ms.PauseNs)) {
+		n = uint32(len(ms.PauseNs))
+	}
+
+	// the pause of the i-th GC cycle, counting from one, is stored at (i+255)%256
+	for i := ms.NumGC - n + 1; i <= ms.NumGC; i++ {
+		j := (i + uint32(len(ms.PauseNs)) - 1) % uint32(len(ms.PauseNs))
+		ps = append(ps, Pause{
+			End:      time.Unix(0, int64(ms.PauseEnd[j])),
+			Duration: time.Duration(ms.PauseNs[j]),
+		})
+	}
+
+	return
+}
+
+// Pauses responds with the most recent individual GC pauses as JSON, ordered from latest to oldest,
+// to drill down into the gcpauses columns. The pauses can be limited to those that ended within a window by ?window=1m.
+func Pauses(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		window, err := getQueryDuration(r, "window", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+
+		ps := getPauses(&ms, 0)
+
+		latest := make([]Pause, 0, len(ps))
+		for i := len(ps) - 1; i >= 0; i-- {
+			if window > 0 && time.Since(ps[i].End) > window {
+				break
+			}
+
+			latest = append(latest, ps[i])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(latest)
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
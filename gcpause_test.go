@@ -0,0 +1,63 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetGCPauses(t *testing.T) {
+	var ms runtime.MemStats
+	ms.NumGC = 3
+	ms.PauseNs[0], ms.PauseEnd[0] = 100, 1000
+	ms.PauseNs[1], ms.PauseEnd[1] = 300, 2000
+	ms.PauseNs[2], ms.PauseEnd[2] = 200, 3000
+
+	assert.Equal(t, GCPauses{Count: 3, MaxNs: 300}, getGCPauses(&ms, 0))
+	assert.Equal(t, GCPauses{Count: 1, MaxNs: 200}, getGCPauses(&ms, 2))
+	assert.Equal(t, GCPauses{}, getGCPauses(&ms, 3))
+
+	assert.Equal(t, []Pause{
+		{End: time.Unix(0, 2000), Duration: 300},
+		{End: time.Unix(0, 3000), Duration: 200},
+	}, getPauses(&ms, 1))
+}
+
+func TestGetPausesWrapped(t *testing.T) {
+	var ms runtime.MemStats
+	ms.NumGC = 300
+	for i := range ms.PauseNs {
+		ms.PauseNs[i] = uint64(i)
+	}
+
+	ps := getPauses(&ms, 0)
+	require.Len(t, ps, len(ms.PauseNs))
+	assert.Equal(t, time.Duration(300%256), ps[0].Duration)
+	assert.Equal(t, time.Duration((300-1)%256), ps[len(ps)-1].Duration)
+}
+
+func TestPauses(t *testing.T) {
+	runtime.GC()
+
+	rec := newTestRecorder(t, RecorderOpts{})
+
+	w := httptest.NewRecorder()
+	Pauses(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/gcpauses?window=1m", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var ps []Pause
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&ps))
+	require.NotEmpty(t, ps)
+	assert.WithinDuration(t, time.Now(), ps[0].End, time.Minute)
+
+	w = httptest.NewRecorder()
+	Pauses(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/gcpauses?window=x", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
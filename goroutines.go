@@ -0,0 +1,206 @@
+package pprofrec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GoroutineBreakdown counts the goroutines of a goroutine profile by their pprof.Labels and by their top function,
+// which is the innermost function of their stack outside of the runtime, so that it points at which goroutines grew.
+type GoroutineBreakdown struct {
+	Total     int              `json:"total"`
+	Labels    []GoroutineCount `json:"labels"`
+	Functions []GoroutineCount `json:"functions"`
+}
+
+// GoroutineCount is the number of goroutines with a label, given as key=value, or top function.
+type GoroutineCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// captureGoroutineBreakdown captures the goroutine profile in its text format with labels and breaks it down.
+func captureGoroutineBreakdown() (b *GoroutineBreakdown, err error) {
+	var buf bytes.Buffer
+	err = pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	if err != nil {
+		return
+	}
+
+	return parseGoroutineBreakdown(&buf)
+}
+
+var goroutineLabel = regexp.MustCompile(`"((?:[^"\\]|\\.)*)":"((?:[^"\\]|\\.)*)"`)
+
+// parseGoroutineBreakdown parses a goroutine profile in the text format of debug 1, in which goroutines
+// with the same stack and labels are grouped, e.g.
+//
+//	3 @ 0x43a0b6 0x4083ec
+//	# labels: {"handler":"/api"}
+//	#	0x43a0b5	runtime.gopark+0xd5	/usr/local/go/src/runtime/proc.go:363
+//	#	0x4083eb	main.serve+0x2b	/app/main.go:12
+func parseGoroutineBreakdown(r io.Reader) (b *GoroutineBreakdown, err error) {
+	labels := map[string]int{}
+	functions := map[string]int{}
+
+	var n int
+	var function string
+	flush := func() {
+		if n > 0 && function != "" {
+			functions[function] += n
+		}
+		n = 0
+		function = ""
+	}
+
+	b = &GoroutineBreakdown{}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64<<10), 1<<20)
+	for s.Scan() {
+		line := s.Text()
+
+		switch {
+		case strings.Contains(line, " @ "):
+			flush()
+
+			n, err = strconv.Atoi(line[:strings.Index(line, " @ ")])
+			if err != nil {
+				return nil, fmt.Errorf("invalid goroutine count: %v", line)
+			}
+			b.Total += n
+		case strings.HasPrefix(line, "# labels: "):
+			for _, m := range goroutineLabel.FindAllStringSubmatch(line, -1) {
+				labels[m[1]+"="+m[2]] += n
+			}
+		case strings.HasPrefix(line, "#\t"):
+			fs := strings.Split(line, "\t")
+			if len(fs) < 3 {
+				continue
+			}
+
+			name := fs[2]
+			if i := strings.LastIndex(name, "+0x"); i >= 0 {
+				name = name[:i]
+			}
+
+			// the innermost frames are usually the runtime parking the goroutine
+			if function == "" || strings.HasPrefix(function, "runtime.") {
+				function = name
+			}
+		}
+	}
+	flush()
+
+	err = s.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	b.Labels = getGoroutineCounts(labels)
+	b.Functions = getGoroutineCounts(functions)
+
+	return
+}
+
+// getGoroutineCounts returns the counts ordered from highest to lowest and by key.
+func getGoroutineCounts(m map[string]int) (cs []GoroutineCount) {
+	for k, n := range m {
+		cs = append(cs, GoroutineCount{Key: k, Count: n})
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].Count != cs[j].Count {
+			return cs[i].Count > cs[j].Count
+		}
+
+		return cs[i].Key < cs[j].Key
+	})
+
+	return
+}
+
+// getGoroutineDelta returns by how much the count of key grew since previous, all goroutines are new without previous.
+func getGoroutineDelta(previous []GoroutineCount, key string, count int) int {
+	for _, c := range previous {
+		if c.Key == key {
+			return count - c.Count
+		}
+	}
+
+	return count
+}
+
+// writeGoroutineBreakdown responds with the breakdown of the goroutine profile with the given id as a html page,
+// the counts are compared to the breakdown of the previous goroutine profile if there is one.
+func writeGoroutineBreakdown(w http.ResponseWriter, r *http.Request, rec *Recorder, id int) {
+	p, ok := rec.Profile(id)
+	if !ok || p.Breakdown == nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	var previous *GoroutineBreakdown
+	for _, pp := range rec.profiles.list() {
+		if pp.ID < p.ID && pp.Breakdown != nil {
+			previous = pp.Breakdown
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+	<style>
+		body {
+			font-family: Courier, monospace;
+			font-size: 13px;
+			margin: 10px;
+		}
+
+		td {
+			padding-right: 20px;
+		}
+	</style>
+	<title>pprofrec goroutines</title>
+</head>
+<body>
+`)
+	fmt.Fprintf(&b, "\t<h3>%d goroutines at %s", p.Breakdown.Total, p.Time.Format("15:04:05"))
+	if previous != nil {
+		fmt.Fprintf(&b, ", %+d since the previous profile", p.Breakdown.Total-previous.Total)
+	} else {
+		previous = &GoroutineBreakdown{}
+	}
+	b.WriteString("</h3>\n")
+	writeGoroutineCounts(&b, "label", p.Breakdown.Labels, previous.Labels)
+	writeGoroutineCounts(&b, "function", p.Breakdown.Functions, previous.Functions)
+	b.WriteString(`</body>
+</html>
+`)
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+	_, err := w.Write([]byte(b.String()))
+	if err != nil {
+		reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+	}
+}
+
+func writeGoroutineCounts(b *strings.Builder, title string, cs []GoroutineCount, previous []GoroutineCount) {
+	fmt.Fprintf(b, "\t<table>\n\t\t<tr><th>%s</th><th>goroutines</th><th>delta</th></tr>\n", title)
+	for _, c := range cs {
+		fmt.Fprintf(b, "\t\t<tr><td>%s</td><td>%d</td><td>%+d</td></tr>\n", html.EscapeString(c.Key), c.Count, getGoroutineDelta(previous, c.Key, c.Count))
+	}
+	b.WriteString("\t</table>\n")
+}
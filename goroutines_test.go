@@ -0,0 +1,85 @@
+package pprofrec
+
+import (
+	"context"
+	"net/http/httptest"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGoroutineBreakdown(t *testing.T) {
+	b, err := parseGoroutineBreakdown(strings.NewReader(`goroutine profile: total 6
+3 @ 0x43a0b6 0x4083ec
+# labels: {"handler":"/api", "tenant":"a\"b"}
+#	0x43a0b5	runtime.gopark+0xd5	/usr/local/go/src/runtime/proc.go:363
+#	0x4083eb	main.serve+0x2b	/app/main.go:12
+
+2 @ 0x43a0b6 0x4083ec
+# labels: {"handler":"/api"}
+#	0x4083eb	main.poll+0x2b	/app/main.go:20
+
+1 @ 0x43a0b6
+#	0x43a0b5	runtime.gopark+0xd5	/usr/local/go/src/runtime/proc.go:363
+#	0x43a0b5	main.main+0xd5	/app/main.go:5
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &GoroutineBreakdown{
+		Total: 6,
+		Labels: []GoroutineCount{
+			{Key: "handler=/api", Count: 5},
+			{Key: `tenant=a\"b`, Count: 3},
+		},
+		Functions: []GoroutineCount{
+			{Key: "main.serve", Count: 3},
+			{Key: "main.poll", Count: 2},
+			{Key: "main.main", Count: 1},
+		},
+	}, b)
+
+	_, err = parseGoroutineBreakdown(strings.NewReader("x @ 0x43a0b6\n"))
+	assert.EqualError(t, err, "invalid goroutine count: x @ 0x43a0b6")
+}
+
+func TestWindowGoroutineBreakdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{})
+	f := Window(ctx, WindowOpts{Recorder: rec})
+
+	p1, err := rec.Capture(ctx, ProfileTrigger{Profile: "goroutine"})
+	require.NoError(t, err)
+	require.NotNil(t, p1.Breakdown)
+
+	done := make(chan struct{})
+	defer close(done)
+	started := make(chan struct{})
+	go pprof.Do(ctx, pprof.Labels("worker", "breakdown-test"), func(ctx context.Context) {
+		close(started)
+		<-done
+	})
+	<-started
+
+	p2, err := rec.Capture(ctx, ProfileTrigger{Profile: "goroutine"})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	f(w, httptest.NewRequest("GET", "/?profile="+strconv.Itoa(p2.ID)+"&breakdown=true", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "since the previous profile")
+	assert.Contains(t, w.Body.String(), "<tr><td>worker=breakdown-test</td><td>1</td><td>+1</td></tr>")
+
+	w = httptest.NewRecorder()
+	f(w, httptest.NewRequest("GET", "/?profile=100&breakdown=true", nil))
+	assert.Equal(t, 404, w.Code)
+
+	assert.Equal(t, []link{
+		{href: "?profile=" + strconv.Itoa(p2.ID), label: "goroutine"},
+		{href: "?profile=" + strconv.Itoa(p2.ID) + "&amp;breakdown=true", label: "breakdown"},
+	}, getProfileLinks([]Profile{p2}, p1.Time, p2.Time))
+}
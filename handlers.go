@@ -0,0 +1,148 @@
+package pprofrec
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// HandlersOpts configures the handlers mounted by RegisterHandlers.
+type HandlersOpts struct {
+	// Window configures the recorder that is shared by the Window, Charts, Health and Annotate handlers.
+	Window WindowOpts
+	// Stream configures the Stream handler.
+	Stream StreamOpts
+	// Auth restricts access to all handlers, it overrides the Auth of Window and Stream if set.
+	Auth Auth
+	// Routes is rendered by the Routes handler, defaults to DefaultRouteStats, which Middleware records to.
+	Routes *RouteStats
+	// Tune mounts the Tune handler, which changes GOGC and GOMEMLIMIT of the process.
+	// It is only mounted if Auth or the Auth of Window is configured.
+	Tune bool
+}
+
+// RegisterHandlers mounts the handlers under prefix, e.g. /debug/pprof:
+//   - <prefix>/window renders the recorded window, see Window
+//   - <prefix>/window.json and <prefix>/window.csv export the recorded window
+//   - <prefix>/window/stats returns statistics of a metric, see Stats
+//   - <prefix>/stream streams metrics, see Stream
+//   - <prefix>/charts plots the recorded window, see Charts
+//   - <prefix>/health reports goroutine leaks, see Health
+//   - <prefix>/annotate records annotations, see Annotate
+//   - <prefix>/gcpauses lists the recent GC pauses, see Pauses
+//   - <prefix>/capture captures a profile or trace on demand, see Capture
+//   - <prefix>/routes renders the resource usage per route, see Routes
+//   - <prefix>/heapprofiles serves the periodic heap profiles and their diffs, see HeapProfiles
+//   - <prefix>/tune changes GOGC and GOMEMLIMIT if opts.Tune and auth are set, see Tune
+//   - <prefix>/index links the views above alongside the net/http/pprof profiles, see Index
+//
+// The returned Recorder records the window until it is stopped.
+func RegisterHandlers(mux *http.ServeMux, prefix string, opts HandlersOpts) *Recorder {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	if opts.Auth.enabled() {
+		opts.Window.Auth = opts.Auth
+		opts.Stream.Auth = opts.Auth
+	}
+
+	rec := opts.Window.Recorder
+	if rec == nil {
+		rec = opts.Window.newRecorder()
+		opts.Window.Recorder = rec
+	}
+
+	if opts.Stream.Recorder == nil {
+		opts.Stream.Recorder = rec
+	}
+
+	if opts.Routes == nil {
+		opts.Routes = DefaultRouteStats
+	}
+
+	window := Window(context.Background(), opts.Window)
+
+	mux.HandleFunc(prefix+"/window", window)
+	mux.HandleFunc(prefix+"/window.json", withFormat(window, formatJSON))
+	mux.HandleFunc(prefix+"/window.csv", withFormat(window, formatCSV))
+	mux.HandleFunc(prefix+"/window/stats", opts.Window.Auth.Wrap(Stats(rec)))
+	mux.HandleFunc(prefix+"/stream", Stream(opts.Stream))
+	mux.HandleFunc(prefix+"/charts", opts.Window.Auth.Wrap(Charts(rec)))
+	mux.HandleFunc(prefix+"/health", opts.Window.Auth.Wrap(Health(rec)))
+	mux.HandleFunc(prefix+"/annotate", opts.Window.Auth.Wrap(Annotate(rec)))
+	mux.HandleFunc(prefix+"/gcpauses", opts.Window.Auth.Wrap(Pauses(rec)))
+	mux.HandleFunc(prefix+"/capture", opts.Window.Auth.Wrap(Capture(rec)))
+	mux.HandleFunc(prefix+"/routes", opts.Window.Auth.Wrap(Routes(opts.Routes)))
+	mux.HandleFunc(prefix+"/heapprofiles", opts.Window.Auth.Wrap(HeapProfiles(rec)))
+
+	// the tune handler affects the whole process, so that it is refused without auth
+	if opts.Tune && !opts.Window.Auth.enabled() {
+		log.Printf("pprofrec: skipping the tune handler: auth is required")
+
+		opts.Tune = false
+	}
+
+	if opts.Tune {
+		mux.HandleFunc(prefix+"/tune", opts.Window.Auth.Wrap(Tune(rec)))
+	}
+
+	mux.HandleFunc(prefix+"/index", Index(IndexOpts{Prefix: prefix, Auth: opts.Window.Auth, Theme: opts.Window.Theme, OnError: opts.Window.OnError, Tune: opts.Tune}))
+
+	return rec
+}
+
+// WindowHandler serves the recorded window as an http.Handler, see Window.
+type WindowHandler struct {
+	rec   *Recorder
+	serve http.HandlerFunc
+}
+
+// NewWindowHandler returns a WindowHandler that records with opts unless opts.Recorder is set.
+// Recording starts right away and stops once ctx is done.
+func NewWindowHandler(ctx context.Context, opts WindowOpts) *WindowHandler {
+	if opts.Recorder == nil {
+		opts.Recorder = opts.newRecorder()
+	}
+
+	return &WindowHandler{rec: opts.Recorder, serve: Window(ctx, opts)}
+}
+
+// ServeHTTP responds with the recorded window.
+func (h *WindowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r)
+}
+
+// Recorder returns the recorder of the window, e.g. to mount the Charts or Health handlers for the same window.
+func (h *WindowHandler) Recorder() *Recorder {
+	return h.rec
+}
+
+// StreamHandler streams runtime metrics as an http.Handler, see Stream.
+type StreamHandler struct {
+	serve http.HandlerFunc
+}
+
+// NewStreamHandler returns a StreamHandler configured by opts.
+func NewStreamHandler(opts StreamOpts) *StreamHandler {
+	return &StreamHandler{serve: Stream(opts)}
+}
+
+// ServeHTTP streams runtime metrics until the request is canceled.
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r)
+}
+
+// withFormat serves h as if the request specified ?format=format.
+func withFormat(h func(w http.ResponseWriter, r *http.Request), format string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := *r.URL
+		q := u.Query()
+		q.Set("format", format)
+		u.RawQuery = q.Encode()
+
+		r = r.Clone(r.Context())
+		r.URL = &u
+
+		h(w, r)
+	}
+}
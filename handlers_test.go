@@ -0,0 +1,90 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterHandlers(t *testing.T) {
+	mux := http.NewServeMux()
+	rec := RegisterHandlers(mux, "/debug/pprof/", HandlersOpts{
+		Window: WindowOpts{Window: time.Second, Frequency: 10 * time.Millisecond},
+		Stream: StreamOpts{Frequency: 10 * time.Millisecond},
+	})
+	defer rec.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	for _, path := range []string{"/debug/pprof/window", "/debug/pprof/window.json", "/debug/pprof/charts", "/debug/pprof/health", "/debug/pprof/gcpauses", "/debug/pprof/window/stats?metric=goroutine", "/debug/pprof/stream?window=30ms"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window.csv?cols=goroutine", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"time", "pprof.Lookup.goroutine", "annotations", "tags"}, rows[0])
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/annotate?label=deploy", http.NoBody))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, rec.Annotations(), 1)
+}
+
+func TestRegisterHandlersAuth(t *testing.T) {
+	mux := http.NewServeMux()
+	rec := RegisterHandlers(mux, "/debug/pprof", HandlersOpts{Auth: Auth{Token: "token"}})
+	defer rec.Stop()
+
+	for _, path := range []string{"/debug/pprof/window", "/debug/pprof/window.csv", "/debug/pprof/stream", "/debug/pprof/charts", "/debug/pprof/health", "/debug/pprof/annotate", "/debug/pprof/gcpauses"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/pprof/health", http.NoBody)
+	r.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "{"))
+}
+
+func TestHandlerTypes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	window := NewWindowHandler(ctx, WindowOpts{Window: time.Second, Frequency: 10 * time.Millisecond})
+	require.NotNil(t, window.Recorder())
+
+	time.Sleep(50 * time.Millisecond)
+
+	s := &RouteStats{}
+	mux := http.NewServeMux()
+	mux.Handle("/window", s.Middleware(window))
+	mux.Handle("/stream", s.Middleware(NewStreamHandler(StreamOpts{Frequency: 10 * time.Millisecond, Recorder: window.Recorder()})))
+	mux.Handle("/health", s.Middleware(Health(window.Recorder())))
+	mux.Handle("/charts", Auth{Token: "secret"}.Wrap(Charts(window.Recorder())))
+
+	for _, path := range []string{"/window?last=1", "/stream?window=30ms", "/health"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+	assert.Len(t, s.Stats(), 3)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/charts", http.NoBody))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
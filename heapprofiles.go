@@ -0,0 +1,391 @@
+package pprofrec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeapDelta is the change of the memory allocated at a stack between two heap profiles, i.e. the sample values
+// of the later profile minus those of the base profile as in pprof -base. The values are scaled by the sampling rate.
+type HeapDelta struct {
+	// Function is the innermost function of the stack outside of the runtime.
+	Function string `json:"function"`
+	// Stack lists the functions of the stack from the innermost function outwards.
+	Stack []string `json:"stack"`
+	// InuseObjects and InuseBytes are the change of the live objects and bytes.
+	InuseObjects int64 `json:"inuseObjects"`
+	InuseBytes   int64 `json:"inuseBytes"`
+	// AllocObjects and AllocBytes are the objects and bytes that were allocated in between.
+	AllocObjects int64 `json:"allocObjects"`
+	AllocBytes   int64 `json:"allocBytes"`
+}
+
+// heapSample holds the scaled values of a record of a heap profile.
+type heapSample struct {
+	// addrs identifies the stack by the addresses of its frames.
+	addrs        string
+	stack        []string
+	inuseObjects float64
+	inuseBytes   float64
+	allocObjects float64
+	allocBytes   float64
+}
+
+// captureHeapProfile captures a heap profile in the gzipped protobuf format for download
+// alongside its samples in the text format of debug 1 for diffing.
+func captureHeapProfile(t time.Time) (p Profile, err error) {
+	var buf bytes.Buffer
+	err = pprof.Lookup("heap").WriteTo(&buf, 0)
+	if err != nil {
+		return
+	}
+
+	var text bytes.Buffer
+	err = pprof.Lookup("heap").WriteTo(&text, 1)
+	if err != nil {
+		return
+	}
+
+	ss, err := parseHeapProfile(&text)
+	if err != nil {
+		return
+	}
+
+	return Profile{Name: "heap", Rule: "periodic", Time: t, Data: buf.Bytes(), heap: ss}, nil
+}
+
+var (
+	heapHeader = regexp.MustCompile(`^heap profile: *\d+: *\d+ *\[ *\d+: *\d+ *\] *@ *heap/(\d+)`)
+	heapRecord = regexp.MustCompile(`^(\d+): (\d+) \[(\d+): (\d+)\] @((?: 0x[0-9a-f]+)*)$`)
+)
+
+// parseHeapProfile parses a heap profile in the text format of debug 1, in which allocations
+// with the same stack are grouped, e.g.
+//
+//	heap profile: 3: 6144 [10: 20480] @ heap/1048576
+//	1: 4096 [2: 8192] @ 0x40f1b5 0x40f2c6
+//	#	0x40f1b4	main.alloc+0x54	/app/main.go:10
+//	#	0x40f2c5	main.main+0x25	/app/main.go:5
+func parseHeapProfile(r io.Reader) (ss []heapSample, err error) {
+	// the runtime writes twice the sampling rate into the header
+	var rate float64
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64<<10), 1<<20)
+	for s.Scan() {
+		line := s.Text()
+
+		if m := heapHeader.FindStringSubmatch(line); m != nil {
+			rate, _ = strconv.ParseFloat(m[1], 64)
+			rate /= 2
+
+			continue
+		}
+
+		// the records are followed by the runtime.MemStats
+		if strings.HasPrefix(line, "# runtime.MemStats") {
+			break
+		}
+
+		if m := heapRecord.FindStringSubmatch(line); m != nil {
+			var vs [4]float64
+			for i := range vs {
+				vs[i], _ = strconv.ParseFloat(m[i+1], 64)
+			}
+
+			hs := heapSample{addrs: strings.TrimSpace(m[5])}
+			hs.inuseObjects, hs.inuseBytes = scaleHeapSample(vs[0], vs[1], rate)
+			hs.allocObjects, hs.allocBytes = scaleHeapSample(vs[2], vs[3], rate)
+			ss = append(ss, hs)
+
+			continue
+		}
+
+		if strings.HasPrefix(line, "#\t") && len(ss) > 0 {
+			fs := strings.Split(line, "\t")
+			if len(fs) < 3 {
+				continue
+			}
+
+			name := fs[2]
+			if i := strings.LastIndex(name, "+0x"); i >= 0 {
+				name = name[:i]
+			}
+
+			ss[len(ss)-1].stack = append(ss[len(ss)-1].stack, name)
+		}
+	}
+
+	err = s.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// scaleHeapSample estimates the number of objects and bytes that were allocated from the sampled count and size,
+// as each allocation is sampled with a probability that depends on its size, see runtime.MemProfileRate.
+func scaleHeapSample(count float64, size float64, rate float64) (float64, float64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+
+	if rate <= 1 {
+		return count, size
+	}
+
+	scale := 1 / (1 - math.Exp(-(size/count)/rate))
+
+	return count * scale, size * scale
+}
+
+// getHeapDiff returns the change of the samples of p since base per stack, ordered by the absolute
+// change of the live bytes and then by the allocated bytes. Stacks without change are omitted.
+func getHeapDiff(base []heapSample, p []heapSample) (ds []HeapDelta) {
+	deltas := map[string]*HeapDelta{}
+	var keys []string
+
+	add := func(hs heapSample, sign float64) {
+		d, ok := deltas[hs.addrs]
+		if !ok {
+			d = &HeapDelta{Function: getTopFunction(hs.stack), Stack: hs.stack}
+			deltas[hs.addrs] = d
+			keys = append(keys, hs.addrs)
+		}
+
+		d.InuseObjects += int64(sign * hs.inuseObjects)
+		d.InuseBytes += int64(sign * hs.inuseBytes)
+		d.AllocObjects += int64(sign * hs.allocObjects)
+		d.AllocBytes += int64(sign * hs.allocBytes)
+	}
+
+	for _, hs := range base {
+		add(hs, -1)
+	}
+	for _, hs := range p {
+		add(hs, 1)
+	}
+
+	for _, k := range keys {
+		d := deltas[k]
+		if d.InuseObjects != 0 || d.InuseBytes != 0 || d.AllocObjects != 0 || d.AllocBytes != 0 {
+			ds = append(ds, *d)
+		}
+	}
+
+	sort.SliceStable(ds, func(i, j int) bool {
+		if absInt64(ds[i].InuseBytes) != absInt64(ds[j].InuseBytes) {
+			return absInt64(ds[i].InuseBytes) > absInt64(ds[j].InuseBytes)
+		}
+
+		return ds[i].AllocBytes > ds[j].AllocBytes
+	})
+
+	return
+}
+
+// getTopFunction returns the innermost function of stack outside of the runtime, or the innermost function.
+func getTopFunction(stack []string) string {
+	for _, f := range stack {
+		if !strings.HasPrefix(f, "runtime.") {
+			return f
+		}
+	}
+
+	if len(stack) > 0 {
+		return stack[0]
+	}
+
+	return ""
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+// HeapProfiles serves the periodic heap profiles of rec, which are captured if ProfileOpts.HeapInterval is set.
+// It lists the profiles as a html page, or as JSON via ?format=json, downloads a profile via ?id=<id>
+// and diffs two profiles via ?base=<id>&id=<id>, which responds with the change of the live and allocated memory
+// per stack as a html table or as JSON. The diff is limited to the 50 stacks that changed most by default,
+// ?limit=0 lists all of them.
+func HeapProfiles(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		id, ok, err := getQueryProfile(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		baseID, diff, err := getQueryProfile(r.URL.Query().Get("base"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		if diff && !ok {
+			http.Error(w, "id is required to diff against base", http.StatusBadRequest)
+
+			return
+		}
+
+		format := getFormat(r)
+
+		if !ok {
+			writeHeapProfiles(w, rec, format)
+
+			return
+		}
+
+		p, found := rec.heapProfiles.get(id)
+		if !found {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		if !diff {
+			writeProfileData(w, p, rec.opts.OnError)
+
+			return
+		}
+
+		base, found := rec.heapProfiles.get(baseID)
+		if !found {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		limit := 50
+		if q := r.URL.Query().Get("limit"); q != "" {
+			limit, err = strconv.Atoi(q)
+			if err != nil || limit < 0 {
+				http.Error(w, fmt.Sprintf("invalid limit: %v", q), http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		ds := getHeapDiff(base.heap, p.heap)
+		if limit > 0 && len(ds) > limit {
+			ds = ds[:limit]
+		}
+
+		switch format {
+		case formatJSON:
+			w.Header().Set("Content-Type", "application/json")
+
+			err = json.NewEncoder(w).Encode(ds)
+		case formatHTML:
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+			_, err = w.Write([]byte(getHeapDiffPage(base, p, ds)))
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+
+			return
+		}
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
+
+const heapProfilesHead = `<!DOCTYPE html>
+<html>
+<head>
+	<style>
+		body {
+			font-family: Courier, monospace;
+			font-size: 13px;
+			margin: 10px;
+		}
+
+		td, th {
+			padding-right: 20px;
+			text-align: left;
+		}
+	</style>
+	<title>pprofrec heap profiles</title>
+</head>
+<body>
+`
+
+// writeHeapProfiles responds with the list of the periodic heap profiles, each linked to its download
+// and to its diff against the previous profile.
+func writeHeapProfiles(w http.ResponseWriter, rec *Recorder, format string) {
+	ps := rec.heapProfiles.list()
+
+	var err error
+	switch format {
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(ps)
+	case formatHTML:
+		var b strings.Builder
+		b.WriteString(heapProfilesHead)
+		b.WriteString("\t<h3>heap profiles</h3>\n\t<table>\n")
+		for i := len(ps) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "\t\t<tr><td>%s</td><td><a href=\"?id=%d\">download</a></td><td>", ps[i].Time.Format("15:04:05"), ps[i].ID)
+			if i > 0 {
+				fmt.Fprintf(&b, "<a href=\"?base=%d&amp;id=%d\">diff to %s</a>", ps[i-1].ID, ps[i].ID, ps[i-1].Time.Format("15:04:05"))
+			}
+			b.WriteString("</td></tr>\n")
+		}
+		b.WriteString("\t</table>\n</body>\n</html>\n")
+
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		_, err = w.Write([]byte(b.String()))
+	default:
+		http.Error(w, "unsupported format", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+	}
+}
+
+// getHeapDiffPage renders the deltas between base and p as a html table, the stack of a function is shown on hover.
+func getHeapDiffPage(base Profile, p Profile, ds []HeapDelta) string {
+	var b strings.Builder
+	b.WriteString(heapProfilesHead)
+	fmt.Fprintf(&b, "\t<h3>heap %s compared to %s</h3>\n", p.Time.Format("15:04:05"), base.Time.Format("15:04:05"))
+	b.WriteString("\t<table>\n\t\t<tr><th>inuse</th><th>inuse objects</th><th>allocated</th><th>allocated objects</th><th>function</th></tr>\n")
+	for _, d := range ds {
+		sign := ""
+		if d.InuseBytes > 0 {
+			sign = "+"
+		}
+
+		fmt.Fprintf(&b, "\t\t<tr><td>%s%s</td><td>%+d</td><td>%s</td><td>%d</td><td title=\"%s\">%s</td></tr>\n",
+			sign, appendHumanBytes(nil, d.InuseBytes), d.InuseObjects, appendHumanBytes(nil, d.AllocBytes), d.AllocObjects,
+			html.EscapeString(strings.Join(d.Stack, "\n")), html.EscapeString(d.Function))
+	}
+	b.WriteString("\t</table>\n</body>\n</html>\n")
+
+	return b.String()
+}
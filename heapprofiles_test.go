@@ -0,0 +1,121 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const heapProfileText = `heap profile: 3: 6144 [10: 20480] @ heap/2
+1: 4096 [2: 8192] @ 0x40f1b5 0x40f2c6
+#	0x40f1b4	runtime.makeslice+0x54	/usr/local/go/src/runtime/slice.go:98
+#	0x40f2c5	main.alloc+0x25	/app/main.go:5
+
+2: 2048 [8: 12288] @ 0x40f3d7
+#	0x40f3d6	main.cache+0x16	/app/cache.go:20
+
+
+# runtime.MemStats
+# Alloc = 1024
+`
+
+func TestParseHeapProfile(t *testing.T) {
+	ss, err := parseHeapProfile(strings.NewReader(heapProfileText))
+	require.NoError(t, err)
+
+	assert.Equal(t, []heapSample{
+		{addrs: "0x40f1b5 0x40f2c6", stack: []string{"runtime.makeslice", "main.alloc"}, inuseObjects: 1, inuseBytes: 4096, allocObjects: 2, allocBytes: 8192},
+		{addrs: "0x40f3d7", stack: []string{"main.cache"}, inuseObjects: 2, inuseBytes: 2048, allocObjects: 8, allocBytes: 12288},
+	}, ss)
+}
+
+func TestScaleHeapSample(t *testing.T) {
+	count, size := scaleHeapSample(1, 512, 512)
+	assert.InDelta(t, 1.582, count, 0.001)
+	assert.InDelta(t, 810, size, 1)
+
+	count, size = scaleHeapSample(0, 0, 512)
+	assert.Zero(t, count)
+	assert.Zero(t, size)
+}
+
+func TestGetHeapDiff(t *testing.T) {
+	base := []heapSample{
+		{addrs: "a", stack: []string{"runtime.makeslice", "main.alloc"}, inuseObjects: 1, inuseBytes: 4096, allocObjects: 2, allocBytes: 8192},
+		{addrs: "b", stack: []string{"main.cache"}, inuseObjects: 2, inuseBytes: 2048, allocObjects: 8, allocBytes: 12288},
+		{addrs: "c", stack: []string{"main.freed"}, inuseObjects: 1, inuseBytes: 100, allocObjects: 1, allocBytes: 100},
+	}
+	p := []heapSample{
+		{addrs: "a", stack: []string{"runtime.makeslice", "main.alloc"}, inuseObjects: 1, inuseBytes: 4096, allocObjects: 2, allocBytes: 8192},
+		{addrs: "b", stack: []string{"main.cache"}, inuseObjects: 10, inuseBytes: 10240, allocObjects: 20, allocBytes: 20480},
+		{addrs: "d", stack: []string{"runtime.malg"}, allocObjects: 1, allocBytes: 64},
+	}
+
+	assert.Equal(t, []HeapDelta{
+		{Function: "main.cache", Stack: []string{"main.cache"}, InuseObjects: 8, InuseBytes: 8192, AllocObjects: 12, AllocBytes: 8192},
+		{Function: "main.freed", Stack: []string{"main.freed"}, InuseObjects: -1, InuseBytes: -100, AllocObjects: -1, AllocBytes: -100},
+		{Function: "runtime.malg", Stack: []string{"runtime.malg"}, AllocObjects: 1, AllocBytes: 64},
+	}, getHeapDiff(base, p))
+
+	assert.Equal(t, "main.alloc", getTopFunction(base[0].stack))
+}
+
+func TestHeapProfiles(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{
+		Frequency: 10 * time.Millisecond,
+		Columns:   Columns{Include: []string{"goroutine"}},
+		Profiles:  ProfileOpts{HeapInterval: 20 * time.Millisecond, HeapMax: 3},
+	})
+	rec.Start(context.Background())
+	time.Sleep(150 * time.Millisecond)
+	rec.Stop()
+
+	ps := rec.HeapProfiles()
+	require.Len(t, ps, 3)
+	assert.Empty(t, rec.Profiles())
+	assert.Equal(t, "periodic", ps[0].Rule)
+
+	h := HeapProfiles(rec)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heapprofiles", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<a href="?base=`)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heapprofiles?format=json", http.NoBody))
+	var listed []Profile
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+	assert.Len(t, listed, 3)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heapprofiles?id="+strconv.Itoa(ps[2].ID), http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.NotEmpty(t, w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heapprofiles?format=json&base="+strconv.Itoa(ps[0].ID)+"&id="+strconv.Itoa(ps[2].ID), http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ds []HeapDelta
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&ds))
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heapprofiles?base="+strconv.Itoa(ps[0].ID)+"&id="+strconv.Itoa(ps[2].ID), http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "compared to")
+
+	for q, code := range map[string]int{"?id=x": http.StatusBadRequest, "?base=1": http.StatusBadRequest, "?id=1000": http.StatusNotFound, "?base=1000&id=" + strconv.Itoa(ps[2].ID): http.StatusNotFound, "?base=" + strconv.Itoa(ps[0].ID) + "&id=" + strconv.Itoa(ps[2].ID) + "&limit=-1": http.StatusBadRequest} {
+		w = httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heapprofiles"+q, http.NoBody))
+		assert.Equal(t, code, w.Code, q)
+	}
+}
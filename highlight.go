@@ -0,0 +1,59 @@
+package pprofrec
+
+import (
+	"log"
+)
+
+// Highlight configures thresholds above which the cells of a column are highlighted.
+type Highlight struct {
+	// Column names the column the thresholds apply to, as selected by Columns.
+	Column string
+	// Warning highlights values above it as warning, bytes are given in bytes and durations in nanoseconds.
+	// It is disabled if zero.
+	Warning float64
+	// Critical highlights values above it as critical, it takes precedence over Warning and is disabled if zero.
+	Critical float64
+}
+
+// defaultHighlights are applied to the columns that are recorded unless they are configured otherwise.
+var defaultHighlights = []Highlight{
+	{Column: "memlimit.used", Warning: 80, Critical: 95},
+}
+
+// getHighlights returns the highlights keyed by the qualified name of the column they apply to,
+// including the default highlights of the columns.
+func getHighlights(hs []Highlight, cols []column) map[string]Highlight {
+	m := map[string]Highlight{}
+
+	for _, h := range defaultHighlights {
+		col, ok := findColumn(cols, h.Column)
+		if ok {
+			m[col.qualifiedName()] = h
+		}
+	}
+
+	for _, h := range hs {
+		col, ok := findColumn(cols, h.Column)
+		if !ok {
+			log.Printf("pprofrec: skipping highlight: unknown column: %v", h.Column)
+
+			continue
+		}
+
+		m[col.qualifiedName()] = h
+	}
+
+	return m
+}
+
+// class returns the class of a cell with value v, empty if no threshold is exceeded.
+func (h Highlight) class(v float64) string {
+	switch {
+	case h.Critical != 0 && v > h.Critical:
+		return "tbl__cell--critical"
+	case h.Warning != 0 && v > h.Warning:
+		return "tbl__cell--warning"
+	default:
+		return ""
+	}
+}
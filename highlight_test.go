@@ -0,0 +1,54 @@
+package pprofrec
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlightClass(t *testing.T) {
+	h := Highlight{Warning: 10, Critical: 20}
+	assert.Equal(t, "", h.class(10))
+	assert.Equal(t, "tbl__cell--warning", h.class(11))
+	assert.Equal(t, "tbl__cell--critical", h.class(21))
+
+	assert.Equal(t, "tbl__cell--critical", Highlight{Critical: 20}.class(21))
+	assert.Equal(t, "", Highlight{}.class(21))
+}
+
+func TestGetHighlights(t *testing.T) {
+	cols := getColumns(capabilities{})
+	hs := getHighlights([]Highlight{{Column: "goroutine", Warning: 5000}, {Column: "unknown", Warning: 1}}, cols)
+
+	assert.Equal(t, map[string]Highlight{"pprof.Lookup.goroutine": {Column: "goroutine", Warning: 5000}}, hs)
+}
+
+func TestWriteRowHighlights(t *testing.T) {
+	cols := Columns{Include: []string{"goroutine"}}.filter(getColumns(capabilities{}))
+	rs := goroutineRecords(time.Now(), 1, 6000)
+	meta := rowMeta{highlights: getHighlights([]Highlight{{Column: "goroutine", Warning: 5000}}, cols)}
+
+	var b bytes.Buffer
+	require.NoError(t, writeRow(&b, cols, rs[0], rs[0], meta))
+	assert.NotContains(t, b.String(), "tbl__cell--warning")
+
+	b.Reset()
+	require.NoError(t, writeRow(&b, cols, rs[0], rs[1], meta))
+	assert.Contains(t, b.String(), `<td class="tbl__cell--warning" style="padding-left: 10px;">6000`)
+}
+
+func TestStreamHighlights(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: 100 * time.Millisecond, Highlights: []Highlight{{Column: "goroutine", Critical: 1}}})
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost:8080?window=150ms", http.NoBody)
+	require.NoError(t, err)
+
+	w := &responseWriter{}
+	f(w, r)
+
+	assert.Contains(t, w.Buffer.String(), `<td class="tbl__cell--critical"`)
+}
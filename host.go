@@ -0,0 +1,78 @@
+package pprofrec
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// HostCollector collects host metrics, so that the metrics of the process can be interpreted
+// in the context of the overall machine pressure.
+// The metrics are recorded as the load averages, the total, available and used memory
+// and the CPU utilization of the host in percent since the previous collection, which is zero initially.
+// Metrics that are not available on the current OS are zero.
+type HostCollector struct {
+	mu       sync.Mutex
+	previous *cpu.TimesStat
+}
+
+// Name returns host.
+func (c *HostCollector) Name() string {
+	return "host"
+}
+
+// Collect returns the current host metrics.
+func (c *HostCollector) Collect(ctx context.Context) []Sample {
+	var l load.AvgStat
+	avg, err := load.AvgWithContext(ctx)
+	if err == nil {
+		l = *avg
+	}
+
+	var m mem.VirtualMemoryStat
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err == nil {
+		m = *vm
+	}
+
+	return []Sample{
+		{Name: "load1", Value: l.Load1},
+		{Name: "load5", Value: l.Load5},
+		{Name: "load15", Value: l.Load15},
+		{Name: "total", Value: float64(m.Total), Unit: UnitBytes},
+		{Name: "available", Value: float64(m.Available), Unit: UnitBytes},
+		{Name: "used", Value: float64(m.Used), Unit: UnitBytes},
+		{Name: "cpu", Value: c.getCPUPercent(ctx), Unit: UnitPercent},
+	}
+}
+
+// getCPUPercent returns the share of the time the CPUs of the host were busy since the previous call.
+func (c *HostCollector) getCPUPercent(ctx context.Context) float64 {
+	ts, err := cpu.TimesWithContext(ctx, false)
+	if err != nil || len(ts) == 0 {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.previous
+	c.previous = &ts[0]
+
+	if previous == nil {
+		return 0
+	}
+
+	total := ts[0].Total() - previous.Total()
+	idle := (ts[0].Idle + ts[0].Iowait) - (previous.Idle + previous.Iowait)
+	if total <= 0 {
+		return 0
+	}
+
+	// iowait is not guaranteed to be monotonic, which can push the share out of bounds
+	return math.Max(0, math.Min(100, 100*(total-idle)/total))
+}
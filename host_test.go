@@ -0,0 +1,34 @@
+package pprofrec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostCollector(t *testing.T) {
+	c := &HostCollector{}
+
+	ss := c.Collect(context.Background())
+	require.Len(t, ss, 7)
+	assert.Equal(t, "total", ss[3].Name)
+	assert.True(t, ss[3].Value > 0)
+	assert.True(t, ss[4].Value <= ss[3].Value)
+	assert.Equal(t, float64(0), ss[6].Value)
+
+	time.Sleep(50 * time.Millisecond)
+
+	ss = c.Collect(context.Background())
+	assert.Equal(t, "cpu", ss[6].Name)
+	assert.True(t, ss[6].Value >= 0 && ss[6].Value <= 100)
+}
+
+func TestHostCollectorColumns(t *testing.T) {
+	cols := getCollectorColumns(context.Background(), []Collector{&HostCollector{}})
+	require.Len(t, cols, 7)
+	assert.Equal(t, "host.load1", cols[0].qualifiedName())
+	assert.Equal(t, unitBytes, cols[3].unit)
+}
@@ -0,0 +1,126 @@
+package pprofrec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// hub feeds the streams of a frequency from a single sampling loop,
+// so that the process is sampled once per frequency rather than once per stream.
+// The loop of a frequency runs while it has subscribers.
+type hub struct {
+	onError    func(error)
+	collectors []Collector
+	columns    Columns
+
+	mu    sync.Mutex
+	loops map[time.Duration]*hubLoop
+}
+
+// hubLoop samples the metrics at a frequency and broadcasts the records to its subscribers.
+type hubLoop struct {
+	s           *sampler
+	cancel      context.CancelFunc
+	subscribers map[chan Record]bool
+	latest      Record
+}
+
+func newHub(onError func(error), collectors []Collector, columns Columns) *hub {
+	return &hub{
+		onError:    onError,
+		collectors: collectors,
+		columns:    columns,
+		loops:      map[time.Duration]*hubLoop{},
+	}
+}
+
+// subscribe returns the recorded columns and the latest record of the loop of frequency along with a channel
+// that receives the following records, records are dropped while the subscriber falls behind.
+// The loop is started if it is not running yet, unsubscribe stops it once it has no subscribers left.
+// Subscribers of a running loop receive its latest broadcast record, the initial record of a new loop
+// is sampled without holding the lock, so that concurrent subscribers do not wait for it.
+func (h *hub) subscribe(frequency time.Duration) (cols []column, latest Record, rs <-chan Record, unsubscribe func()) {
+	h.mu.Lock()
+	l, ok := h.loops[frequency]
+	h.mu.Unlock()
+
+	var ctx context.Context
+	if !ok {
+		l, ctx = h.newLoop(frequency)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !ok {
+		running, ok := h.loops[frequency]
+		if ok {
+			// another subscriber started the loop while the initial record was sampled
+			l.cancel()
+			l = running
+		} else {
+			h.loops[frequency] = l
+			go h.run(ctx, l, frequency)
+		}
+	}
+
+	c := make(chan Record, 16)
+	l.subscribers[c] = true
+
+	unsubscribe = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(l.subscribers, c)
+		if len(l.subscribers) == 0 && h.loops[frequency] == l {
+			l.cancel()
+			delete(h.loops, frequency)
+		}
+	}
+
+	return l.s.cols, l.latest, c, unsubscribe
+}
+
+// newLoop returns a loop of frequency holding its initial record along with the context the loop runs in,
+// the loop is not started.
+func (h *hub) newLoop(frequency time.Duration) (l *hubLoop, ctx context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l = &hubLoop{
+		s:           newSampler(ctx, h.onError, h.collectors, h.columns),
+		cancel:      cancel,
+		subscribers: map[chan Record]bool{},
+	}
+	l.s.frequency = frequency
+	l.latest = l.s.getRecord(ctx)
+
+	return l, ctx
+}
+
+// run samples the metrics at frequency until ctx is done.
+func (h *hub) run(ctx context.Context, l *hubLoop, frequency time.Duration) {
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r := l.s.getRecord(ctx)
+
+			h.mu.Lock()
+			l.latest = r
+			for c := range l.subscribers {
+				select {
+				case c <- r:
+				default:
+					reportError(h.onError, fmt.Errorf("dropping record of %v: stream falls behind", r.Time))
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
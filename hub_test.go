@@ -0,0 +1,98 @@
+package pprofrec
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	c := &queueCollector{}
+	h := newHub(nil, []Collector{c}, Columns{Include: []string{"queue"}})
+
+	cols1, latest1, rs1, unsubscribe1 := h.subscribe(20 * time.Millisecond)
+	cols2, latest2, rs2, unsubscribe2 := h.subscribe(20 * time.Millisecond)
+	require.Len(t, h.loops, 1)
+	assert.Equal(t, cols1, cols2)
+	assert.Equal(t, latest1.Time, latest2.Time)
+
+	r1 := <-rs1
+	r2 := <-rs2
+	assert.Equal(t, r1.Time, r2.Time)
+
+	_, _, _, unsubscribe3 := h.subscribe(40 * time.Millisecond)
+	assert.Len(t, h.loops, 2)
+	unsubscribe3()
+
+	unsubscribe1()
+	h.mu.Lock()
+	assert.Len(t, h.loops, 1)
+	h.mu.Unlock()
+
+	unsubscribe2()
+	h.mu.Lock()
+	assert.Len(t, h.loops, 0)
+	h.mu.Unlock()
+
+	calls := atomic.LoadInt64(&c.calls)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, calls, atomic.LoadInt64(&c.calls))
+}
+
+// blockingCollector blocks collecting until release is closed.
+type blockingCollector struct {
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCollector) Name() string {
+	return "blocking"
+}
+
+func (c *blockingCollector) Collect(ctx context.Context) []Sample {
+	c.once.Do(func() { close(c.started) })
+	<-c.release
+
+	return []Sample{{Name: "value", Value: 1}}
+}
+
+func TestHubSubscribeUnlocked(t *testing.T) {
+	c := &blockingCollector{started: make(chan struct{}), release: make(chan struct{})}
+	h := newHub(nil, []Collector{c}, Columns{})
+
+	subscribed := make(chan func())
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _, _, unsubscribe := h.subscribe(20 * time.Millisecond)
+			subscribed <- unsubscribe
+		}()
+	}
+
+	<-c.started
+
+	// the lock is not held while the initial record is sampled
+	h.mu.Lock()
+	assert.Len(t, h.loops, 0)
+	h.mu.Unlock()
+
+	close(c.release)
+	unsubscribe1 := <-subscribed
+	unsubscribe2 := <-subscribed
+
+	h.mu.Lock()
+	require.Len(t, h.loops, 1)
+	assert.Len(t, h.loops[20*time.Millisecond].subscribers, 2)
+	h.mu.Unlock()
+
+	unsubscribe1()
+	unsubscribe2()
+	h.mu.Lock()
+	assert.Len(t, h.loops, 0)
+	h.mu.Unlock()
+}
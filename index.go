@@ -0,0 +1,129 @@
+package pprofrec
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// IndexOpts configures the Index handler.
+type IndexOpts struct {
+	// Prefix is the prefix the pprofrec handlers are mounted under, defaults to /debug/pprof.
+	Prefix string
+	// PprofPrefix is the prefix the net/http/pprof handlers are mounted under, defaults to /debug/pprof.
+	PprofPrefix string
+	// Auth restricts access to the handler.
+	Auth Auth
+	// Theme configures the look of the page, e.g. DarkTheme.
+	Theme Theme
+	// Tune links the Tune handler, which is only mounted on request.
+	Tune bool
+	// OnError is called with failures to render the page, they are logged if nil.
+	OnError func(error)
+}
+
+type indexLink struct {
+	path        string
+	description string
+}
+
+var (
+	indexViews = []indexLink{
+		{"/window", "recorded window as html table"},
+		{"/window.json", "recorded window as JSON"},
+		{"/window.csv", "recorded window as CSV"},
+		{"/window/stats?metric=HeapAlloc", "statistics of a metric as JSON"},
+		{"/stream", "live metrics as html table"},
+		{"/charts", "recorded window as line charts"},
+		{"/health", "goroutine leak verdict as JSON"},
+		{"/gcpauses", "recent GC pauses as JSON"},
+		{"/routes", "resource usage per route"},
+		{"/heapprofiles", "periodic heap profiles and their diffs"},
+	}
+	indexProfiles = []indexLink{
+		{"/heap?debug=1", "heap profile of live objects"},
+		{"/allocs?debug=1", "sampling of all past memory allocations"},
+		{"/goroutine?debug=2", "stack traces of all goroutines"},
+		{"/profile?seconds=30", "30s CPU profile"},
+		{"/trace?seconds=5", "5s execution trace"},
+		{"/block?debug=1", "stack traces that led to blocking on synchronization primitives"},
+		{"/mutex?debug=1", "stack traces of holders of contended mutexes"},
+		{"/threadcreate?debug=1", "stack traces that led to the creation of new OS threads"},
+		{"/cmdline", "command line of the program"},
+	}
+)
+
+// Index responds with a html page that links the pprofrec views alongside the net/http/pprof profiles,
+// the profiles have to be mounted separately, e.g. by importing net/http/pprof.
+func Index(opts IndexOpts) http.HandlerFunc {
+	if opts.Prefix == "" {
+		opts.Prefix = "/debug/pprof"
+	}
+	opts.Prefix = strings.TrimSuffix(opts.Prefix, "/")
+
+	if opts.PprofPrefix == "" {
+		opts.PprofPrefix = "/debug/pprof"
+	}
+	opts.PprofPrefix = strings.TrimSuffix(opts.PprofPrefix, "/")
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+	<style>
+		body {
+			font-family: Courier, monospace;
+			font-size: 13px;
+			margin: 10px;
+		}
+
+		td {
+			padding-right: 20px;
+		}
+	</style>
+`)
+	_ = writeTheme(&b, opts.Theme)
+	b.WriteString(`	<title>pprofrec</title>
+</head>
+<body>
+	<h3>pprofrec</h3>
+	<table>
+`)
+	writeIndexLinks(&b, opts.Prefix, indexViews)
+	if opts.Tune {
+		writeIndexLinks(&b, opts.Prefix, []indexLink{{"/tune", "change GOGC and GOMEMLIMIT, trigger a GC"}})
+	}
+	b.WriteString(`	</table>
+	<h3>net/http/pprof</h3>
+	<table>
+`)
+	writeIndexLinks(&b, opts.PprofPrefix, indexProfiles)
+	b.WriteString(`	</table>
+</body>
+</html>
+`)
+	page := b.String()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(opts.OnError, r)
+
+		if !opts.Auth.authorize(w, r) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		_, err := w.Write([]byte(page))
+		if err != nil {
+			reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
+
+func writeIndexLinks(b *strings.Builder, prefix string, links []indexLink) {
+	for _, l := range links {
+		href := html.EscapeString(prefix + l.path)
+		fmt.Fprintf(b, "\t\t<tr><td><a href=\"%s\">%s</a></td><td>%s</td></tr>\n", href, href, l.description)
+	}
+}
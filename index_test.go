@@ -0,0 +1,34 @@
+package pprofrec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	h := Index(IndexOpts{Prefix: "/debug/pprofrec/"})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprofrec/index", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=UTF-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `<a href="/debug/pprofrec/window">`)
+	assert.Contains(t, w.Body.String(), `<a href="/debug/pprof/heap?debug=1">`)
+	assert.Contains(t, w.Body.String(), `<a href="/debug/pprof/profile?seconds=30">`)
+	assert.Contains(t, w.Body.String(), `<a href="/debug/pprof/trace?seconds=5">`)
+}
+
+func TestRegisterHandlersIndex(t *testing.T) {
+	mux := http.NewServeMux()
+	rec := RegisterHandlers(mux, "/debug/pprofrec", HandlersOpts{})
+	defer rec.Stop()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprofrec/index", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<a href="/debug/pprofrec/charts">`)
+}
@@ -0,0 +1,92 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InfluxSink writes records as InfluxDB line protocol, one line per record with a field per column.
+type InfluxSink struct {
+	// URL receives the lines via POST, e.g. http://localhost:8086/api/v2/write?org=org&bucket=bucket&precision=ns.
+	URL string
+	// Token authenticates the requests to the URL if set.
+	Token string
+	// Client sends the requests, defaults to a client with a 10s timeout.
+	Client *http.Client
+	// Writer receives the lines instead if URL is empty, e.g. os.Stdout when run by Telegraf's execd input.
+	Writer io.Writer
+	// Measurement names the measurement, defaults to pprofrec.
+	Measurement string
+	// Tags are added to every line, e.g. host or service.
+	Tags map[string]string
+}
+
+// Write writes r as a line to the URL or the Writer.
+func (s InfluxSink) Write(ctx context.Context, r Record, ms []Metric) (err error) {
+	if s.URL == "" && s.Writer == nil {
+		return fmt.Errorf("missing url or writer")
+	}
+
+	var b bytes.Buffer
+	s.writeLine(&b, r, ms)
+
+	if s.URL == "" {
+		_, err = s.Writer.Write(b.Bytes())
+
+		return
+	}
+
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	if s.Token != "" {
+		header.Set("Authorization", "Token "+s.Token)
+	}
+
+	return post(ctx, s.Client, s.URL, header, b.Bytes())
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxKeyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+func (s InfluxSink) writeLine(b *bytes.Buffer, r Record, ms []Metric) {
+	measurement := s.Measurement
+	if measurement == "" {
+		measurement = "pprofrec"
+	}
+	b.WriteString(influxMeasurementEscaper.Replace(measurement))
+
+	keys := make([]string, 0, len(s.Tags))
+	for k := range s.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b.WriteByte(',')
+		b.WriteString(influxKeyEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(influxKeyEscaper.Replace(s.Tags[k]))
+	}
+
+	for i, m := range ms {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(influxKeyEscaper.Replace(m.Group + "." + m.Name))
+		b.WriteByte('=')
+		b.WriteString(strconv.FormatFloat(m.Value, 'f', -1, 64))
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(r.Time.UnixNano(), 10))
+	b.WriteByte('\n')
+}
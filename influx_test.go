@@ -0,0 +1,48 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxSinkWriter(t *testing.T) {
+	var b bytes.Buffer
+	s := InfluxSink{Writer: &b, Tags: map[string]string{"service": "api server", "host": "a,b"}}
+
+	r := Record{Time: time.Unix(1, 5)}
+	ms := []Metric{
+		{Group: "pprof", Sample: Sample{Name: "goroutine", Value: 12}},
+		{Group: "my queue", Sample: Sample{Name: "depth=x", Value: 0.5}},
+	}
+
+	require.NoError(t, s.Write(context.Background(), r, ms))
+	assert.Equal(t, "pprofrec,host=a\\,b,service=api\\ server pprof.goroutine=12,my\\ queue.depth\\=x=0.5 1000000005\n", b.String())
+
+	assert.EqualError(t, InfluxSink{}.Write(context.Background(), r, ms), "missing url or writer")
+}
+
+func TestInfluxSinkURL(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := InfluxSink{URL: srv.URL, Token: "secret", Measurement: "runtime"}
+
+	err := s.Write(context.Background(), Record{Time: time.Unix(2, 0)}, []Metric{{Group: "pprof", Sample: Sample{Name: "goroutine", Value: 3}}})
+	require.NoError(t, err)
+	assert.Equal(t, "Token secret", header.Get("Authorization"))
+	assert.Equal(t, "runtime pprof.goroutine=3 2000000000\n", string(body))
+}
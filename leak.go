@@ -0,0 +1,82 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// LeakOpts configures the goroutine leak detection.
+type LeakOpts struct {
+	// Span defines for how long the goroutine count has to grow without decreasing
+	// to be considered leaking, the detection is disabled if zero.
+	Span time.Duration
+}
+
+// HealthStatus is the verdict of the goroutine leak detection.
+type HealthStatus struct {
+	// GoroutineLeak reports whether the goroutine count grew without decreasing for at least the span.
+	GoroutineLeak bool `json:"goroutineLeak"`
+	// Since is the time of the record from which on the goroutine count grew.
+	Since time.Time `json:"since,omitempty"`
+	// Goroutines is the latest goroutine count.
+	Goroutines int `json:"goroutines"`
+	// Growth is by how much the goroutine count grew since Since.
+	Growth int `json:"growth"`
+}
+
+// detectLeaks reports for each record whether the goroutine count grew without decreasing
+// for at least span until the record, and since which record it grew.
+func detectLeaks(rs []Record, span time.Duration) (leaks []bool, since []int) {
+	leaks = make([]bool, len(rs))
+	since = make([]int, len(rs))
+	if span <= 0 {
+		return
+	}
+
+	for i := range rs {
+		if i > 0 && rs[i].Pprof.Goroutine >= rs[i-1].Pprof.Goroutine {
+			since[i] = since[i-1]
+		} else {
+			since[i] = i
+		}
+
+		leaks[i] = rs[i].Time.Sub(rs[since[i]].Time) >= span && rs[i].Pprof.Goroutine > rs[since[i]].Pprof.Goroutine
+	}
+
+	return
+}
+
+// getHealth returns the verdict of the goroutine leak detection for the latest record.
+func getHealth(rs []Record, span time.Duration) (h HealthStatus) {
+	if len(rs) == 0 {
+		return
+	}
+
+	leaks, since := detectLeaks(rs, span)
+	latest := len(rs) - 1
+
+	h.Goroutines = rs[latest].Pprof.Goroutine
+	if leaks[latest] {
+		h.GoroutineLeak = true
+		h.Since = rs[since[latest]].Time
+		h.Growth = rs[latest].Pprof.Goroutine - rs[since[latest]].Pprof.Goroutine
+	}
+
+	return
+}
+
+// Health responds with the verdict of the goroutine leak detection of rec as JSON.
+func Health(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(getHealth(rec.Records(), rec.opts.Leak.Span))
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
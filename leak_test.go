@@ -0,0 +1,84 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func goroutineRecords(ts time.Time, counts ...int) (rs []Record) {
+	for i, c := range counts {
+		r := Record{Time: ts.Add(time.Duration(i) * time.Second)}
+		r.Pprof.Goroutine = c
+		rs = append(rs, r)
+	}
+
+	return
+}
+
+func TestDetectLeaks(t *testing.T) {
+	ts := time.Now()
+	rs := goroutineRecords(ts, 10, 11, 11, 12, 13, 9, 9, 9, 9)
+
+	leaks, since := detectLeaks(rs, 3*time.Second)
+	assert.Equal(t, []bool{false, false, false, true, true, false, false, false, false}, leaks)
+	assert.Equal(t, []int{0, 0, 0, 0, 0, 5, 5, 5, 5}, since)
+
+	leaks, _ = detectLeaks(rs, 0)
+	assert.Equal(t, make([]bool, len(rs)), leaks)
+}
+
+func TestGetHealth(t *testing.T) {
+	ts := time.Now()
+
+	h := getHealth(goroutineRecords(ts, 10, 11, 12, 14), 2*time.Second)
+	assert.Equal(t, HealthStatus{GoroutineLeak: true, Since: ts, Goroutines: 14, Growth: 4}, h)
+
+	h = getHealth(goroutineRecords(ts, 10, 11, 9, 14), 2*time.Second)
+	assert.Equal(t, HealthStatus{Goroutines: 14}, h)
+
+	assert.Equal(t, HealthStatus{}, getHealth(nil, time.Second))
+}
+
+func TestHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Second, Frequency: 10 * time.Millisecond, Leak: LeakOpts{Span: time.Hour}})
+	rec.Start(ctx)
+	defer rec.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Health(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/health", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var h HealthStatus
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&h))
+	assert.False(t, h.GoroutineLeak)
+	assert.NotZero(t, h.Goroutines)
+}
+
+func TestWindowLeak(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Second, Frequency: 10 * time.Millisecond, Columns: Columns{Include: []string{"pprof"}}})
+	for _, r := range goroutineRecords(time.Now(), 1, 2, 3, 4) {
+		rec.rs.push(r)
+	}
+	rec.opts.Leak.Span = 2 * time.Second
+
+	w := httptest.NewRecorder()
+	Window(ctx, WindowOpts{Recorder: rec})(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<tr class="tbl__row--leak"`)
+}
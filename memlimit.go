@@ -0,0 +1,43 @@
+//go:build go1.19
+// +build go1.19
+
+package pprofrec
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+)
+
+// memoryLimitSupported returns true as the soft memory limit is available since go1.19.
+func memoryLimitSupported() bool {
+	return true
+}
+
+// getMemoryLimit returns the utilization of limit by the memory the runtime counts towards it,
+// the utilization is zero if the limit was lifted.
+func getMemoryLimit(ms *runtime.MemStats, limit int64) *MemoryLimit {
+	l := &MemoryLimit{Limit: limit}
+	if limit > 0 {
+		l.Used = 100 * float64(ms.Sys-ms.HeapReleased) / float64(limit)
+	}
+
+	return l
+}
+
+// setMemoryLimit sets the soft memory limit of the runtime and returns the previous limit,
+// math.MaxInt64 lifts the limit.
+func setMemoryLimit(limit int64) (previous int64, err error) {
+	return debug.SetMemoryLimit(limit), nil
+}
+
+// readMemoryLimit returns the soft memory limit of the runtime, zero if there is none.
+func readMemoryLimit() int64 {
+	// a negative limit reads the limit without changing it
+	l := debug.SetMemoryLimit(-1)
+	if l == math.MaxInt64 {
+		return 0
+	}
+
+	return l
+}
@@ -0,0 +1,29 @@
+//go:build !go1.19
+// +build !go1.19
+
+package pprofrec
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// memoryLimitSupported returns false as the soft memory limit requires go1.19.
+func memoryLimitSupported() bool {
+	return false
+}
+
+// getMemoryLimit returns nil as the soft memory limit requires go1.19.
+func getMemoryLimit(ms *runtime.MemStats, limit int64) *MemoryLimit {
+	return nil
+}
+
+// setMemoryLimit fails as the soft memory limit requires go1.19.
+func setMemoryLimit(limit int64) (previous int64, err error) {
+	return 0, fmt.Errorf("GOMEMLIMIT requires go1.19")
+}
+
+// readMemoryLimit returns zero as the soft memory limit requires go1.19.
+func readMemoryLimit() int64 {
+	return 0
+}
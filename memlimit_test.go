@@ -0,0 +1,72 @@
+//go:build go1.19
+// +build go1.19
+
+package pprofrec
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMemoryLimit(t *testing.T) {
+	previous := debug.SetMemoryLimit(1 << 30)
+	defer debug.SetMemoryLimit(previous)
+
+	assert.Equal(t, int64(1<<30), readMemoryLimit())
+
+	debug.SetMemoryLimit(math.MaxInt64)
+	assert.Equal(t, int64(0), readMemoryLimit())
+}
+
+func TestGetMemoryLimit(t *testing.T) {
+	ms := &runtime.MemStats{Sys: 600, HeapReleased: 100}
+
+	assert.Equal(t, &MemoryLimit{Limit: 1000, Used: 50}, getMemoryLimit(ms, 1000))
+	assert.Equal(t, &MemoryLimit{}, getMemoryLimit(ms, 0))
+}
+
+func TestSamplerMemoryLimit(t *testing.T) {
+	previous := debug.SetMemoryLimit(math.MaxInt64)
+	defer debug.SetMemoryLimit(previous)
+
+	s := newSampler(context.Background(), nil, nil, Columns{Include: []string{"memlimit"}})
+	require.Len(t, s.cols, len(memoryLimitColumns))
+
+	r := s.getRecord(context.Background())
+	assert.Equal(t, &MemoryLimit{}, r.MemoryLimit)
+
+	// the limit is read with every record, so that a limit set after the sampler started shows up
+	debug.SetMemoryLimit(1 << 40)
+
+	r = s.getRecord(context.Background())
+	require.NotNil(t, r.MemoryLimit)
+	assert.Equal(t, int64(1<<40), r.MemoryLimit.Limit)
+	assert.True(t, r.MemoryLimit.Used > 0 && r.MemoryLimit.Used < 1)
+
+	hs := getHighlights(nil, s.cols)
+	assert.Equal(t, map[string]Highlight{"debug.SetMemoryLimit.used": {Column: "memlimit.used", Warning: 80, Critical: 95}}, hs)
+
+	hs = getHighlights([]Highlight{{Column: "memlimit.used", Critical: 90}}, s.cols)
+	assert.Equal(t, map[string]Highlight{"debug.SetMemoryLimit.used": {Column: "memlimit.used", Critical: 90}}, hs)
+}
+
+func TestTuneMemoryLimit(t *testing.T) {
+	previous := debug.SetMemoryLimit(math.MaxInt64)
+	defer debug.SetMemoryLimit(previous)
+
+	label, err := tune("gomemlimit", "512MiB")
+	require.NoError(t, err)
+	assert.Equal(t, "GOMEMLIMIT=0.500 GiB (was off)", label)
+	assert.Equal(t, int64(512<<20), readMemoryLimit())
+
+	label, err = tune("gomemlimit", "off")
+	require.NoError(t, err)
+	assert.Equal(t, "GOMEMLIMIT=off (was 0.500 GiB)", label)
+	assert.Equal(t, int64(0), readMemoryLimit())
+}
@@ -0,0 +1,67 @@
+package pprofrec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+)
+
+// Metadata describes the runtime configuration and the host that metrics are recorded with,
+// it is rendered above the html tables and written to recordings so that they are self-describing.
+type Metadata struct {
+	GoVersion  string `json:"goVersion"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	NumCPU     int    `json:"numCPU"`
+	// GOGC is the value of the GOGC environment variable, 100 if unset, or the value set by Tune.
+	GOGC string `json:"gogc"`
+	// GOMEMLIMIT is the soft memory limit in bytes, zero if there is none.
+	GOMEMLIMIT int64  `json:"gomemlimit"`
+	Hostname   string `json:"hostname"`
+	// Tags are the tags of the recorder, e.g. the build SHA or region, without the tags of its ranges.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// getMetadata returns the metadata of the current process.
+func getMetadata() Metadata {
+	m := Metadata{
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		GOGC:       readGOGC(),
+		GOMEMLIMIT: readMemoryLimit(),
+	}
+
+	hostname, err := os.Hostname()
+	if err == nil {
+		m.Hostname = hostname
+	}
+
+	return m
+}
+
+// String formats m as space separated key value pairs.
+func (m Metadata) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%v GOMAXPROCS=%v NumCPU=%v GOGC=%v GOMEMLIMIT=", m.GoVersion, m.GOMAXPROCS, m.NumCPU, m.GOGC)
+
+	if m.GOMEMLIMIT > 0 {
+		_, _ = writeHumanBytes(&b, m.GOMEMLIMIT)
+	} else {
+		b.WriteString("off")
+	}
+
+	fmt.Fprintf(&b, " hostname=%v", m.Hostname)
+
+	if len(m.Tags) > 0 {
+		b.WriteString(" " + formatTags(m.Tags))
+	}
+
+	return b.String()
+}
+
+// writeMetadata writes a marker row that lists the metadata.
+func writeMetadata(w io.Writer, cols []column, m Metadata, d display) (err error) {
+	return writeMarker(w, cols, "tbl__row--annotation tbl__row--metadata", "metadata", m.String(), d)
+}
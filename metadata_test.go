@@ -0,0 +1,34 @@
+package pprofrec
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMetadata(t *testing.T) {
+	m := getMetadata()
+	assert.Equal(t, runtime.Version(), m.GoVersion)
+	assert.Equal(t, runtime.GOMAXPROCS(0), m.GOMAXPROCS)
+	assert.Equal(t, runtime.NumCPU(), m.NumCPU)
+	assert.NotEmpty(t, m.GOGC)
+	assert.NotEmpty(t, m.Hostname)
+}
+
+func TestMetadataString(t *testing.T) {
+	m := Metadata{GoVersion: "go1.21.0", GOMAXPROCS: 4, NumCPU: 8, GOGC: "100", Hostname: "api-0"}
+	assert.Equal(t, "go1.21.0 GOMAXPROCS=4 NumCPU=8 GOGC=100 GOMEMLIMIT=off hostname=api-0", m.String())
+
+	m.GOMEMLIMIT = 1 << 30
+	assert.Equal(t, "go1.21.0 GOMAXPROCS=4 NumCPU=8 GOGC=100 GOMEMLIMIT=1.000 GiB hostname=api-0", m.String())
+}
+
+func TestWriteMetadata(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, writeMetadata(&b, pprofColumns, Metadata{GoVersion: "go1.21.0", Hostname: "<api-0>"}, display{}))
+	assert.Contains(t, b.String(), `<tr class="tbl__row--annotation tbl__row--metadata"><td class="tbl__col1">metadata</td><td colspan="12">`)
+	assert.Contains(t, b.String(), "hostname=&lt;api-0&gt;")
+}
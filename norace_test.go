@@ -0,0 +1,7 @@
+//go:build !race
+// +build !race
+
+package pprofrec
+
+// raceEnabled is set if the tests run with the race detector, which allocates on its own.
+const raceEnabled = false
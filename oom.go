@@ -0,0 +1,115 @@
+package pprofrec
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/process"
+)
+
+// OOMCollector projects when the RSS of the process reaches its memory limit at the trend of the RSS
+// over a window, to decide whether to restart now or after peak traffic. The limit is the memory limit
+// of the cgroup or the total memory of the host, whichever is lower.
+// The metrics are recorded as the limit, the growth of the RSS per minute fitted by least squares
+// and the time until the limit is reached, which is zero if the RSS is not growing or the limit is unknown.
+type OOMCollector struct {
+	// Window defines over which window the trend of the RSS is fitted, defaults to 5 minutes.
+	Window time.Duration
+
+	mu     sync.Mutex
+	points []oomPoint
+}
+
+// oomPoint is the RSS at a time.
+type oomPoint struct {
+	time time.Time
+	rss  float64
+}
+
+// Name returns oom.
+func (c *OOMCollector) Name() string {
+	return "oom"
+}
+
+// Collect returns the current limit and the projection of the RSS.
+func (c *OOMCollector) Collect(ctx context.Context) []Sample {
+	var rss float64
+	p, err := process.NewProcessWithContext(ctx, int32(os.Getpid()))
+	if err == nil {
+		mi, err := p.MemoryInfoWithContext(ctx)
+		if err == nil {
+			rss = float64(mi.RSS)
+		}
+	}
+
+	limit := readCgroup("/sys/fs/cgroup", "/proc/self/cgroup").memoryLimit
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err == nil && vm.Total > 0 && (limit == 0 || float64(vm.Total) < limit) {
+		limit = float64(vm.Total)
+	}
+
+	return c.collect(time.Now(), rss, limit)
+}
+
+// collect adds the RSS at t to the window and returns the projection towards limit.
+func (c *OOMCollector) collect(t time.Time, rss float64, limit float64) []Sample {
+	window := c.Window
+	if window == time.Duration(0) {
+		window = 5 * time.Minute
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.points = append(c.points, oomPoint{time: t, rss: rss})
+
+	i := 0
+	for i < len(c.points)-1 && t.Sub(c.points[i].time) > window {
+		i++
+	}
+	c.points = c.points[i:]
+
+	growth := getRSSGrowth(c.points)
+
+	var timeToLimit float64
+	if limit > 0 && growth > 0 && rss < limit {
+		timeToLimit = (limit - rss) / growth
+	}
+
+	return []Sample{
+		{Name: "limit", Value: limit, Unit: UnitBytes},
+		{Name: "growth", Value: growth * 60, Unit: UnitBytes},
+		{Name: "time_to_limit", Value: timeToLimit, Unit: UnitSeconds},
+	}
+}
+
+// getRSSGrowth returns the growth of the RSS per second over ps fitted by least squares,
+// it is zero with less than two points.
+func getRSSGrowth(ps []oomPoint) float64 {
+	if len(ps) < 2 {
+		return 0
+	}
+
+	var mx, my float64
+	for _, p := range ps {
+		mx += p.time.Sub(ps[0].time).Seconds()
+		my += p.rss
+	}
+	mx /= float64(len(ps))
+	my /= float64(len(ps))
+
+	var sxx, sxy float64
+	for _, p := range ps {
+		x := p.time.Sub(ps[0].time).Seconds() - mx
+		sxx += x * x
+		sxy += x * (p.rss - my)
+	}
+	if sxx == 0 {
+		return 0
+	}
+
+	return sxy / sxx
+}
@@ -0,0 +1,44 @@
+package pprofrec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOOMCollector(t *testing.T) {
+	c := &OOMCollector{Window: time.Minute}
+	assert.Equal(t, "oom", c.Name())
+
+	ts := time.Now()
+	ss := c.collect(ts, 100<<20, 1<<30)
+	assert.Equal(t, []Sample{
+		{Name: "limit", Value: 1 << 30, Unit: UnitBytes},
+		{Name: "growth", Value: 0, Unit: UnitBytes},
+		{Name: "time_to_limit", Value: 0, Unit: UnitSeconds},
+	}, ss)
+
+	// grows by 1 MiB per second
+	ss = c.collect(ts.Add(time.Second), 101<<20, 1<<30)
+	ss = c.collect(ts.Add(2*time.Second), 102<<20, 1<<30)
+	assert.InDelta(t, 60<<20, ss[1].Value, 0.001)
+	assert.InDelta(t, 1024-102, ss[2].Value, 0.001)
+
+	// the points before the window are dropped
+	ss = c.collect(ts.Add(2*time.Minute), 90<<20, 1<<30)
+	require.Len(t, c.points, 1)
+	assert.Equal(t, float64(0), ss[1].Value)
+
+	ss = c.collect(ts.Add(2*time.Minute+time.Second), 80<<20, 1<<30)
+	assert.Less(t, ss[1].Value, float64(0))
+	assert.Equal(t, float64(0), ss[2].Value)
+
+	ss = c.collect(ts.Add(2*time.Minute+2*time.Second), 100<<20, 0)
+	assert.Equal(t, float64(0), ss[2].Value)
+
+	ss = c.Collect(context.Background())
+	require.Len(t, ss, 3)
+}
@@ -0,0 +1,189 @@
+package pprofrec
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option configures a Recorder created by NewRecorder.
+type Option func(opts *RecorderOpts)
+
+// WithOpts replaces all options by opts, e.g. to set options that have no dedicated Option.
+// Options that follow it are applied on top, zero values are replaced by their defaults once all options are applied.
+func WithOpts(opts RecorderOpts) Option {
+	return func(o *RecorderOpts) {
+		*o = opts
+	}
+}
+
+// WithWindow sets the window within metrics are stored.
+func WithWindow(window time.Duration) Option {
+	return func(opts *RecorderOpts) {
+		opts.Window = window
+	}
+}
+
+// WithFrequency sets the frequency at which metrics are recorded.
+func WithFrequency(frequency time.Duration) Option {
+	return func(opts *RecorderOpts) {
+		opts.Frequency = frequency
+	}
+}
+
+// WithColumns selects the metrics that are recorded.
+func WithColumns(columns Columns) Option {
+	return func(opts *RecorderOpts) {
+		opts.Columns = columns
+	}
+}
+
+// WithCollectors adds collectors of application-defined metrics.
+func WithCollectors(collectors ...Collector) Option {
+	return func(opts *RecorderOpts) {
+		opts.Collectors = append(opts.Collectors, collectors...)
+	}
+}
+
+// WithSinks adds sinks that receive every record.
+func WithSinks(sinks ...Sink) Option {
+	return func(opts *RecorderOpts) {
+		opts.Sinks = append(opts.Sinks, sinks...)
+	}
+}
+
+// WithResolutions adds windows that are recorded at a lower frequency.
+func WithResolutions(resolutions ...Resolution) Option {
+	return func(opts *RecorderOpts) {
+		opts.Resolutions = append(opts.Resolutions, resolutions...)
+	}
+}
+
+// WithMaxBytes bounds the estimated memory used by the recorded metrics.
+func WithMaxBytes(maxBytes int) Option {
+	return func(opts *RecorderOpts) {
+		opts.MaxBytes = maxBytes
+	}
+}
+
+// WithOnError sets the function that is called with failures to sample, render, store or forward metrics.
+func WithOnError(onError func(error)) Option {
+	return func(opts *RecorderOpts) {
+		opts.OnError = onError
+	}
+}
+
+// withDefaults returns opts with the zero values replaced by their documented defaults.
+func (opts RecorderOpts) withDefaults() RecorderOpts {
+	if opts.Window == time.Duration(0) {
+		opts.Window = 30 * time.Second
+	}
+
+	if opts.Frequency == time.Duration(0) {
+		opts.Frequency = 1 * time.Second
+		if opts.Window > 0 && opts.Window < opts.Frequency {
+			opts.Frequency = opts.Window
+		}
+	}
+
+	if opts.Store.Interval == time.Duration(0) {
+		opts.Store.Interval = 10 * time.Second
+	}
+
+	if opts.Profiles.CPUDuration == time.Duration(0) {
+		opts.Profiles.CPUDuration = 10 * time.Second
+	}
+
+	if opts.Profiles.MaxCaptureDuration == time.Duration(0) {
+		opts.Profiles.MaxCaptureDuration = time.Minute
+	}
+
+	return opts
+}
+
+// Validate returns an error if the window or frequency of opts or of one of its resolutions is not positive,
+// if a frequency is greater than its window, if a resolution is not recorded at a lower frequency than opts,
+// if MaxBytes, the max capture duration or the store interval is negative or if the expression of an alert rule or profile trigger does not parse.
+func (opts RecorderOpts) Validate() (err error) {
+	err = validateFrequency(opts.Window, opts.Frequency)
+	if err != nil {
+		return
+	}
+
+	for _, res := range opts.Resolutions {
+		err = validateFrequency(res.Window, res.Frequency)
+		if err != nil {
+			return fmt.Errorf("invalid resolution: %w", err)
+		}
+
+		if res.Frequency <= opts.Frequency {
+			return fmt.Errorf("invalid resolution: frequency %v must be greater than %v", res.Frequency, opts.Frequency)
+		}
+	}
+
+	rules := append([]AlertRule{}, opts.Alerts.Rules...)
+	for _, t := range opts.Profiles.Triggers {
+		rules = append(rules, t.Rule)
+	}
+
+	for _, rule := range rules {
+		if rule.Expr == "" {
+			if rule.Increasing == 0 && !isAlertOp(rule.Op) {
+				return fmt.Errorf("invalid alert rule %v: unknown op: %v", rule.Name, rule.Op)
+			}
+
+			continue
+		}
+
+		_, err = parseAlertExpr(rule.Expr)
+		if err != nil {
+			return fmt.Errorf("invalid alert rule %v: %w", rule.Name, err)
+		}
+	}
+
+	if opts.MaxBytes < 0 {
+		return fmt.Errorf("max bytes %v must not be negative", opts.MaxBytes)
+	}
+
+	if opts.Profiles.HeapInterval < 0 {
+		return fmt.Errorf("heap profile interval %v must not be negative", opts.Profiles.HeapInterval)
+	}
+
+	if opts.Profiles.CPUInterval < 0 {
+		return fmt.Errorf("cpu profile interval %v must not be negative", opts.Profiles.CPUInterval)
+	}
+
+	cpuDuration := opts.Profiles.CPUDuration
+	if cpuDuration == time.Duration(0) {
+		cpuDuration = 10 * time.Second
+	}
+
+	if opts.Profiles.CPUInterval > 0 && cpuDuration >= opts.Profiles.CPUInterval {
+		return fmt.Errorf("cpu profile duration %v must be shorter than interval %v", cpuDuration, opts.Profiles.CPUInterval)
+	}
+
+	if opts.Profiles.MaxCaptureDuration < 0 {
+		return fmt.Errorf("max capture duration %v must not be negative", opts.Profiles.MaxCaptureDuration)
+	}
+
+	if opts.Store.Interval < 0 {
+		return fmt.Errorf("store interval %v must not be negative", opts.Store.Interval)
+	}
+
+	return
+}
+
+func validateFrequency(window time.Duration, frequency time.Duration) error {
+	if window <= 0 {
+		return fmt.Errorf("window %v must be positive", window)
+	}
+
+	if frequency <= 0 {
+		return fmt.Errorf("frequency %v must be positive", frequency)
+	}
+
+	if frequency > window {
+		return fmt.Errorf("frequency %v must not be greater than window %v", frequency, window)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package pprofrec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecorderOptions(t *testing.T) {
+	c := &queueCollector{}
+	rec, err := NewRecorder(
+		WithOpts(RecorderOpts{Expvar: true}),
+		WithWindow(time.Minute),
+		WithFrequency(100*time.Millisecond),
+		WithCollectors(c),
+		WithResolutions(Resolution{Window: time.Hour, Frequency: time.Minute}),
+		WithMaxBytes(1024),
+	)
+	require.NoError(t, err)
+	assert.True(t, rec.opts.Expvar)
+	assert.Equal(t, time.Minute, rec.opts.Window)
+	assert.Equal(t, 100*time.Millisecond, rec.opts.Frequency)
+	assert.Equal(t, []Collector{c}, rec.opts.Collectors)
+	assert.Len(t, rec.resolutions, 1)
+	assert.Equal(t, 1024, rec.opts.MaxBytes)
+
+	// the defaults are applied after WithOpts
+	rec, err = NewRecorder(WithOpts(RecorderOpts{}))
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, rec.opts.Window)
+	assert.Equal(t, time.Second, rec.opts.Frequency)
+
+	_, err = NewRecorder(WithOpts(RecorderOpts{Window: time.Second, Frequency: time.Minute}))
+	assert.EqualError(t, err, "frequency 1m0s must not be greater than window 1s")
+}
+
+func TestRecorderOptsValidate(t *testing.T) {
+	tcs := []struct {
+		name string
+		opts RecorderOpts
+		err  string
+	}{
+		{"valid", RecorderOpts{Window: time.Minute, Frequency: time.Second}, ""},
+		{"zero window", RecorderOpts{Frequency: time.Second}, "window 0s must be positive"},
+		{"negative frequency", RecorderOpts{Window: time.Minute, Frequency: -time.Second}, "frequency -1s must be positive"},
+		{"frequency greater than window", RecorderOpts{Window: time.Second, Frequency: time.Minute}, "frequency 1m0s must not be greater than window 1s"},
+		{"invalid resolution", RecorderOpts{Window: time.Minute, Frequency: time.Second, Resolutions: []Resolution{{Window: time.Hour}}}, "invalid resolution: frequency 0s must be positive"},
+		{"resolution frequency", RecorderOpts{Window: time.Minute, Frequency: time.Second, Resolutions: []Resolution{{Window: time.Hour, Frequency: time.Second}}}, "invalid resolution: frequency 1s must be greater than 1s"},
+		{"unknown alert op", RecorderOpts{Window: time.Minute, Frequency: time.Second, Alerts: AlertOpts{Rules: []AlertRule{{Name: "heap", Column: "HeapAlloc", Op: "!="}}}}, "invalid alert rule heap: unknown op: !="},
+		{"invalid alert rule", RecorderOpts{Window: time.Minute, Frequency: time.Second, Alerts: AlertOpts{Rules: []AlertRule{{Name: "heap", Expr: "HeapAlloc >"}}}}, "invalid alert rule heap: missing threshold after > at offset 11"},
+		{"negative max bytes", RecorderOpts{Window: time.Minute, Frequency: time.Second, MaxBytes: -1}, "max bytes -1 must not be negative"},
+		{"negative store interval", RecorderOpts{Window: time.Minute, Frequency: time.Second, Store: StoreOpts{Interval: -time.Second}}, "store interval -1s must not be negative"},
+		{"negative heap profile interval", RecorderOpts{Window: time.Minute, Frequency: time.Second, Profiles: ProfileOpts{HeapInterval: -time.Minute}}, "heap profile interval -1m0s must not be negative"},
+		{"negative cpu profile interval", RecorderOpts{Window: time.Minute, Frequency: time.Second, Profiles: ProfileOpts{CPUInterval: -time.Minute}}, "cpu profile interval -1m0s must not be negative"},
+		{"negative max capture duration", RecorderOpts{Window: time.Minute, Frequency: time.Second, Profiles: ProfileOpts{MaxCaptureDuration: -time.Minute}}, "max capture duration -1m0s must not be negative"},
+		{"cpu profile duration exceeds interval", RecorderOpts{Window: time.Minute, Frequency: time.Second, Profiles: ProfileOpts{CPUInterval: 5 * time.Second}}, "cpu profile duration 10s must be shorter than interval 5s"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			if tc.err == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
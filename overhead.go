@@ -0,0 +1,32 @@
+package pprofrec
+
+import (
+	"time"
+)
+
+// Overhead holds the cost of taking a record, to verify the overhead of the recorder itself.
+type Overhead struct {
+	// Duration is the time it took to take the record.
+	Duration time.Duration `json:"duration"`
+	// Allocs and AllocBytes are the number and size of the heap allocations while the record was taken,
+	// they include allocations of concurrently running goroutines and are zero before go1.16.
+	Allocs     uint64 `json:"allocs"`
+	AllocBytes uint64 `json:"allocBytes"`
+	// DroppedTicks is the number of ticks since the previous record that were dropped as recording fell behind.
+	DroppedTicks int `json:"droppedTicks"`
+}
+
+// getDroppedTicks returns the number of ticks of the given frequency that were missed between previous and current,
+// ticks that are late by less than half the frequency are not counted. It is zero if previous is unset.
+func getDroppedTicks(previous time.Time, current time.Time, frequency time.Duration) int {
+	if previous.IsZero() || frequency <= 0 {
+		return 0
+	}
+
+	n := int((current.Sub(previous)+frequency/2)/frequency) - 1
+	if n < 0 {
+		return 0
+	}
+
+	return n
+}
@@ -0,0 +1,37 @@
+package pprofrec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDroppedTicks(t *testing.T) {
+	ts := time.Now()
+
+	assert.Equal(t, 0, getDroppedTicks(time.Time{}, ts, time.Second))
+	assert.Equal(t, 0, getDroppedTicks(ts, ts.Add(time.Second), time.Second))
+	assert.Equal(t, 0, getDroppedTicks(ts, ts.Add(1400*time.Millisecond), time.Second))
+	assert.Equal(t, 1, getDroppedTicks(ts, ts.Add(1600*time.Millisecond), time.Second))
+	assert.Equal(t, 3, getDroppedTicks(ts, ts.Add(4*time.Second), time.Second))
+	assert.Equal(t, 0, getDroppedTicks(ts, ts.Add(4*time.Second), 0))
+}
+
+func TestSamplerOverhead(t *testing.T) {
+	s := newSampler(context.Background(), nil, nil, Columns{Include: []string{"overhead", "memstats"}})
+	s.frequency = 10 * time.Millisecond
+
+	r := s.getRecord(context.Background())
+	require.NotNil(t, r.Overhead)
+	assert.NotZero(t, r.Overhead.Duration)
+	assert.Equal(t, 0, r.Overhead.DroppedTicks)
+
+	time.Sleep(50 * time.Millisecond)
+
+	r = s.getRecord(context.Background())
+	require.NotNil(t, r.Overhead)
+	assert.True(t, r.Overhead.DroppedTicks >= 3)
+}
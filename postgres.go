@@ -0,0 +1,184 @@
+package pprofrec
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PostgresSink batch-inserts the values of the recorded columns into a PostgreSQL or TimescaleDB table,
+// a row per metric and record, for SQL analysis and Grafana dashboards over a long retention.
+// The table is created by MigratePostgres. The sink takes a *sql.DB of any PostgreSQL driver, e.g. pgx or lib/pq,
+// and has to be used as a pointer as it buffers the records until a batch is complete.
+//
+//	CREATE TABLE pprofrec (
+//		time     timestamptz      NOT NULL,
+//		instance text             NOT NULL,
+//		metric   text             NOT NULL,
+//		value    double precision NOT NULL
+//	)
+type PostgresSink struct {
+	// DB is the database the records are inserted into.
+	DB *sql.DB
+	// Table names the table, defaults to pprofrec. It is not quoted, so that it can be qualified by a schema.
+	Table string
+	// Instance identifies the process, defaults to <hostname>/<pid>.
+	Instance string
+	// BatchSize is the number of records that are inserted at once, defaults to 10.
+	BatchSize int
+
+	mu      sync.Mutex
+	pending []postgresRecord
+}
+
+// postgresRecord holds a record pending to be inserted.
+type postgresRecord struct {
+	r  Record
+	ms []Metric
+}
+
+// Write buffers r and inserts the buffered records once BatchSize is reached.
+func (s *PostgresSink) Write(ctx context.Context, r Record, ms []Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, postgresRecord{r: r, ms: ms})
+
+	batchSize := s.BatchSize
+	if batchSize == 0 {
+		batchSize = 10
+	}
+
+	if len(s.pending) < batchSize {
+		return nil
+	}
+
+	return s.flush(ctx)
+}
+
+// Flush inserts the buffered records, e.g. before the process exits.
+func (s *PostgresSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flush(ctx)
+}
+
+// flush inserts the pending records in a transaction, the records are dropped if the insert fails.
+func (s *PostgresSink) flush(ctx context.Context) (err error) {
+	pending := s.pending
+	s.pending = nil
+
+	if len(pending) == 0 {
+		return
+	}
+
+	instance := s.Instance
+	if instance == "" {
+		instance = getInstance()
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, p := range pending {
+		if len(p.ms) == 0 {
+			continue
+		}
+
+		query, args := getPostgresInsert(getPostgresTable(s.Table), instance, p.r, p.ms)
+
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to insert records: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return
+}
+
+// getPostgresInsert returns the statement that inserts a row per metric of r along with its arguments.
+func getPostgresInsert(table string, instance string, r Record, ms []Metric) (query string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(table)
+	b.WriteString(" (time, instance, metric, value) VALUES ")
+
+	args = make([]interface{}, 0, 4*len(ms))
+	for i, m := range ms {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		n := len(args)
+		b.WriteString("($" + strconv.Itoa(n+1) + ",$" + strconv.Itoa(n+2) + ",$" + strconv.Itoa(n+3) + ",$" + strconv.Itoa(n+4) + ")")
+
+		args = append(args, r.Time, instance, m.Group+"."+m.Name, m.Value)
+	}
+
+	return b.String(), args
+}
+
+// getPostgresTable returns table, pprofrec if empty.
+func getPostgresTable(table string) string {
+	if table == "" {
+		return "pprofrec"
+	}
+
+	return table
+}
+
+// getInstance returns <hostname>/<pid>, the hostname is omitted if it cannot be determined.
+func getInstance() string {
+	hostname, _ := os.Hostname()
+
+	return fmt.Sprintf("%v/%v", hostname, os.Getpid())
+}
+
+// MigratePostgres creates the table of a PostgresSink along with an index on the metric and time if they do not exist,
+// table defaults to pprofrec. If timescale is set the table is turned into a TimescaleDB hypertable partitioned by time,
+// which requires the timescaledb extension.
+func MigratePostgres(ctx context.Context, db *sql.DB, table string, timescale bool) (err error) {
+	table = getPostgresTable(table)
+
+	for _, stmt := range getPostgresMigration(table, timescale) {
+		_, err = db.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("failed to migrate %v: %w", table, err)
+		}
+	}
+
+	return
+}
+
+// getPostgresMigration returns the statements of MigratePostgres.
+func getPostgresMigration(table string, timescale bool) []string {
+	index := strings.Replace(table, ".", "_", -1) + "_metric_time_idx"
+
+	stmts := []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (time timestamptz NOT NULL, instance text NOT NULL, metric text NOT NULL, value double precision NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS " + index + " ON " + table + " (metric, time DESC)",
+	}
+
+	if timescale {
+		stmts = append(stmts, "SELECT create_hypertable('"+table+"', 'time', if_not_exists => TRUE)")
+	}
+
+	return stmts
+}
@@ -0,0 +1,182 @@
+package pprofrec
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSQLDriver records the statements that are executed along with their arguments and whether they were committed.
+type testSQLDriver struct {
+	mu      sync.Mutex
+	execs   []string
+	args    [][]driver.Value
+	commits int
+	err     error
+}
+
+func (d *testSQLDriver) Open(name string) (driver.Conn, error) {
+	return testSQLConn{d: d}, nil
+}
+
+type testSQLConn struct {
+	d *testSQLDriver
+}
+
+func (c testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return testSQLStmt{d: c.d, query: query}, nil
+}
+
+func (c testSQLConn) Close() error {
+	return nil
+}
+
+func (c testSQLConn) Begin() (driver.Tx, error) {
+	return testSQLTx{d: c.d}, nil
+}
+
+type testSQLStmt struct {
+	d     *testSQLDriver
+	query string
+}
+
+func (s testSQLStmt) Close() error {
+	return nil
+}
+
+func (s testSQLStmt) NumInput() int {
+	return -1
+}
+
+func (s testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+
+	if s.d.err != nil {
+		return nil, s.d.err
+	}
+
+	s.d.execs = append(s.d.execs, s.query)
+	s.d.args = append(s.d.args, args)
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s testSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+type testSQLTx struct {
+	d *testSQLDriver
+}
+
+func (tx testSQLTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+
+	tx.d.commits++
+
+	return nil
+}
+
+func (tx testSQLTx) Rollback() error {
+	return nil
+}
+
+// testSQLDrivers counts the registered drivers, as drivers cannot be unregistered.
+var testSQLDrivers int64
+
+// openTestSQL returns a database backed by a new testSQLDriver.
+func openTestSQL(t *testing.T) (*sql.DB, *testSQLDriver) {
+	d := &testSQLDriver{}
+
+	name := fmt.Sprintf("pprofrec-%v", atomic.AddInt64(&testSQLDrivers, 1))
+	sql.Register(name, d)
+
+	db, err := sql.Open(name, "")
+	require.NoError(t, err)
+
+	return db, d
+}
+
+func TestPostgresSink(t *testing.T) {
+	db, d := openTestSQL(t)
+	defer db.Close()
+
+	s := &PostgresSink{DB: db, Instance: "api-1", BatchSize: 2}
+
+	now := time.Unix(1, 0)
+	ms := []Metric{
+		{Group: "pprof", Sample: Sample{Name: "goroutine", Value: 12}},
+		{Group: "memstats", Sample: Sample{Name: "HeapAlloc", Value: 1024}},
+	}
+
+	require.NoError(t, s.Write(context.Background(), Record{Time: now}, ms))
+	assert.Empty(t, d.execs)
+
+	require.NoError(t, s.Write(context.Background(), Record{Time: now.Add(time.Second)}, ms))
+	require.Len(t, d.execs, 2)
+	assert.Equal(t, 1, d.commits)
+	assert.Equal(t, "INSERT INTO pprofrec (time, instance, metric, value) VALUES ($1,$2,$3,$4),($5,$6,$7,$8)", d.execs[0])
+	assert.Equal(t, []driver.Value{now, "api-1", "pprof.goroutine", 12.0, now, "api-1", "memstats.HeapAlloc", 1024.0}, d.args[0])
+
+	require.NoError(t, s.Write(context.Background(), Record{Time: now.Add(2 * time.Second)}, ms))
+	require.NoError(t, s.Flush(context.Background()))
+	assert.Len(t, d.execs, 3)
+	assert.Equal(t, 2, d.commits)
+
+	require.NoError(t, s.Flush(context.Background()))
+	assert.Equal(t, 2, d.commits)
+
+	d.err = errors.New("relation does not exist")
+	require.NoError(t, s.Write(context.Background(), Record{Time: now}, ms))
+	assert.EqualError(t, s.Flush(context.Background()), "failed to insert records: relation does not exist")
+	assert.Empty(t, s.pending)
+}
+
+func TestRecorderPostgresSinkStop(t *testing.T) {
+	db, d := openTestSQL(t)
+	defer db.Close()
+
+	s := &PostgresSink{DB: db, BatchSize: 1000}
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: 10 * time.Millisecond, Columns: Columns{Include: []string{"goroutine"}}, Sinks: []Sink{s}})
+
+	rec.Start(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	rec.Stop()
+
+	rs := rec.Records()
+	require.NotEmpty(t, rs)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	assert.Len(t, d.execs, len(rs))
+	assert.Equal(t, 1, d.commits)
+}
+
+func TestMigratePostgres(t *testing.T) {
+	db, d := openTestSQL(t)
+	defer db.Close()
+
+	require.NoError(t, MigratePostgres(context.Background(), db, "metrics.runtime", true))
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS metrics.runtime (time timestamptz NOT NULL, instance text NOT NULL, metric text NOT NULL, value double precision NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS metrics_runtime_metric_time_idx ON metrics.runtime (metric, time DESC)",
+		"SELECT create_hypertable('metrics.runtime', 'time', if_not_exists => TRUE)",
+	}, d.execs)
+
+	assert.Len(t, getPostgresMigration("pprofrec", false), 2)
+
+	d.err = errors.New("permission denied")
+	assert.EqualError(t, MigratePostgres(context.Background(), db, "", false), "failed to migrate pprofrec: permission denied")
+}
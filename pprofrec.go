@@ -1,1054 +1,1102 @@
 // Provides a single pane of glass across all runtime metrics.
-// pprofrec records pprof lookups, runtime.MemStats and gopsutil`metrics,
+// pprofrec records pprof lookups, runtime.MemStats, runtime/metrics and gopsutil`metrics,
 // and exposes them via http endpoints to inspect and troubleshoot an application in an idiomatic, fast and boring way.
 package pprofrec
 
 import (
 	"context"
 	"fmt"
+	"html"
+	"html/template"
 	"io"
-	"log"
+	"math"
 	"math/bits"
 	"net/http"
-	"os"
-	"runtime"
-	"runtime/pprof"
 	"strconv"
+	"sync"
 	"time"
-
-	"github.com/shirou/gopsutil/cpu"
-	"github.com/shirou/gopsutil/process"
 )
 
-type record struct {
-	ts             time.Time
-	memStats       runtime.MemStats
-	pprofPair      pprofStat
-	cpuTimeStat    cpu.TimesStat
-	iOCounterStat  process.IOCountersStat
-	memoryInfoStat process.MemoryInfoStat
-}
-
-type pprofStat struct {
-	goroutine    int
-	threadcreate int
-	heap         int
-	allocs       int
-	block        int
-	mutex        int
-}
-
-type capabilities struct {
-	cpuTimeStat    bool
-	iOCounterStat  bool
-	memoryInfoStat bool
-}
-
 // WindowOpts configures the Window handler.
 type WindowOpts struct {
-	// Window defines a window within metrics are stored.
+	// Window defines a window within metrics are stored, defaults to 30s if zero.
 	Window time.Duration
-	// Frequency defines at what frequency metrics are recorded.
+	// Frequency defines at what frequency metrics are recorded, defaults to 1s or to Window if it is shorter.
 	Frequency time.Duration
+	// Columns selects the metrics that are recorded.
+	Columns Columns
+	// Collectors collect application-defined metrics that are recorded alongside the runtime metrics.
+	Collectors []Collector
+	// OnRecord is called with every record after it has been recorded,
+	// it is called from the recording goroutine and delays the next record until it returns.
+	OnRecord func(r Record)
+	// Alerts configures alerting on the recorded metrics.
+	Alerts AlertOpts
+	// Profiles configures capturing profiles when metrics spike.
+	Profiles ProfileOpts
+	// Leak configures the goroutine leak detection, rows of a leak are marked.
+	Leak LeakOpts
+	// Store configures checkpointing the recorded metrics to disk across restarts.
+	Store StoreOpts
+	// Sinks receive every record after it has been recorded.
+	Sinks []Sink
+	// OnError is called with failures to sample, render, store or forward metrics,
+	// they are logged if nil.
+	OnError func(error)
+	// Expvar publishes the latest recorded values under the pprofrec expvar map.
+	Expvar bool
+	// Resolutions defines additional windows that are recorded at a lower frequency
+	// by the same sampling goroutine and selected by ?res=<frequency>.
+	Resolutions []Resolution
+	// MaxBytes bounds the estimated memory used by the recorded metrics, the oldest rows are dropped once it is exceeded.
+	MaxBytes int
+	// BlockProfileRate enables the block profile while recording, so that the block column is populated.
+	BlockProfileRate int
+	// MutexProfileFraction enables the mutex profile while recording, so that the mutex column is populated.
+	MutexProfileFraction int
+	// Tags are attached to every record and listed in the metadata, e.g. the build SHA or region.
+	Tags map[string]string
+	// Highlights configures thresholds above which cells of the html table are highlighted.
+	Highlights []Highlight
+	// Theme configures the look of the html table, e.g. DarkTheme.
+	Theme Theme
+	// Time configures how the time column of the html table is rendered.
+	Time TimeOpts
+	// Display selects whether values, deltas or both are rendered per column, it can be overridden by ?display=values.
+	Display DisplayMode
+	// NewestFirst renders the latest row at the top of the html table, it can be overridden by ?order=asc.
+	NewestFirst bool
+	// Template renders the html response instead of the builtin table if set, e.g. to brand the page or restructure the layout.
+	// It is executed with TemplateData.
+	Template *template.Template
+	// Auth restricts access to the handler.
+	Auth Auth
+	// Recorder is rendered instead of recording with the options above if set,
+	// e.g. to share it with the Health handler.
+	Recorder *Recorder
 }
 
-// Window records runtime metrics at a given frequency within a given window and
-// responds with a html table that lists the recorded metrics.
-func Window(ctx context.Context, opts WindowOpts) func(w http.ResponseWriter, r *http.Request) {
-	if opts.Window == time.Duration(0) {
-		opts.Window = 30 * time.Second
-	}
+// newRecorder returns the recorder that is rendered if no Recorder is set. As the handler constructors do not return
+// an error, invalid options are reported to OnError and the window is recorded with the default options instead.
+func (opts WindowOpts) newRecorder() *Recorder {
+	rec, err := NewRecorder(WithOpts(opts.recorderOpts()))
+	if err != nil {
+		reportError(opts.OnError, fmt.Errorf("invalid window options, recording with the defaults: %w", err))
 
-	if opts.Frequency == time.Duration(0) {
-		opts.Frequency = 1 * time.Second
+		rec = newRecorder(RecorderOpts{Columns: opts.Columns, Collectors: opts.Collectors, OnError: opts.OnError}.withDefaults())
 	}
 
-	var c capabilities
-	p, err := process.NewProcess(int32(os.Getpid()))
-	if err != nil {
-		log.Printf("pprofrec: failed to create process instance: %v", err.Error())
-	} else {
-		c = getCapabilities(ctx, p)
+	return rec
+}
+
+// recorderOpts returns the options of the recorder that is rendered if no Recorder is set.
+func (opts WindowOpts) recorderOpts() RecorderOpts {
+	return RecorderOpts{
+		Window:               opts.Window,
+		Frequency:            opts.Frequency,
+		Columns:              opts.Columns,
+		Collectors:           opts.Collectors,
+		OnRecord:             opts.OnRecord,
+		Alerts:               opts.Alerts,
+		Profiles:             opts.Profiles,
+		Leak:                 opts.Leak,
+		Store:                opts.Store,
+		Sinks:                opts.Sinks,
+		OnError:              opts.OnError,
+		Expvar:               opts.Expvar,
+		Resolutions:          opts.Resolutions,
+		MaxBytes:             opts.MaxBytes,
+		BlockProfileRate:     opts.BlockProfileRate,
+		MutexProfileFraction: opts.MutexProfileFraction,
+		Tags:                 opts.Tags,
 	}
+}
 
-	var rs []record
-	go func() {
-		max := int((opts.Window / opts.Frequency) + 1)
-		ticker := time.NewTicker(opts.Frequency)
-		for range ticker.C {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				if len(rs) < max {
-					rs = append(rs, getRecord(ctx, c, p))
-				} else {
-					rs = append(rs[1:], getRecord(ctx, c, p))
-				}
-			}
-		}
-	}()
+// display returns how the html table is rendered unless overridden by the request.
+func (opts WindowOpts) display() display {
+	return display{timeFormat: opts.Time.Format, location: opts.Time.Location, mode: opts.Display, newestFirst: opts.NewestFirst}
+}
+
+// Window records runtime metrics at a given frequency within a given window and
+// responds with a html table that lists the recorded metrics.
+// The recorded metrics are returned as a JSON array instead if the request
+// specifies ?format=json or accepts application/json, and as CSV with a header row
+// if the request specifies ?format=csv or accepts text/csv.
+// The html table and CSV can be trimmed to the columns selected by ?cols=goroutine,HeapAlloc,RSS.
+// The records can be limited to a shorter window by ?window=5m and thinned out
+// to a lower frequency by ?freq=10s, both bounded by what has been recorded.
+// Additional resolutions are selected by their frequency, e.g. ?res=1m.
+// The rows are limited to a time range by ?from=15:04:05&to=15:09:05, either bound can be omitted
+// and times can also be given in RFC 3339. The delta of every metric between the records nearest
+// to both times is rendered as a single row instead by ?from=15:04:05&to=15:34:05&diff=true.
+// Captured profiles are linked from the row that triggered them and served via ?profile=<id>,
+// goroutine profiles are broken down by label and top function via ?profile=<id>&breakdown=true,
+// rows during which goroutines leaked are marked and annotations are rendered as marker rows.
+// The response is downloaded as a standalone file by ?download=html, ?download=json or ?download=csv,
+// e.g. to attach the recorded window to an incident ticket.
+// The first row lists the Metadata of the process, e.g. GOMAXPROCS, GOGC and GOMEMLIMIT,
+// and the last rows list the min, max, avg and p95 of every column, which are returned as JSON by ?format=json&summary=true.
+// Times are rendered in the timezone given by ?tz=UTC, which clock times of ?from= and ?to= refer to as well.
+// Bytes and durations are rendered as exact integers by ?units=raw, durations in nanoseconds, e.g. for automation.
+// Only the values or only the deltas of the columns are rendered by ?display=values or ?display=deltas.
+// The latest row is rendered at the top by ?order=desc.
+// The response is limited to the latest records by ?last=50, e.g. for quick curls or dashboards that embed the table.
+// The html response is rendered by opts.Template instead if set.
+// Responses are gzip encoded if the request accepts it.
+// Invalid options are reported to opts.OnError and the window is recorded with the defaults instead.
+func Window(ctx context.Context, opts WindowOpts) http.HandlerFunc {
+	rec := opts.Recorder
+	if rec == nil {
+		rec = opts.newRecorder()
+	}
+	rec.Start(ctx)
+
+	highlights := getHighlights(opts.Highlights, rec.s.cols)
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			err := r.Body.Close()
-			if err != nil {
-				log.Printf("pprofrec: failed to close request body: %v", err.Error())
-			}
-		}()
+		defer closeBody(opts.OnError, r)
 
-		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		if !opts.Auth.authorize(w, r) {
+			return
+		}
 
-		err := writeHead(w, c)
+		id, ok, err := getQueryProfile(r.URL.Query().Get("profile"))
 		if err != nil {
-			log.Printf("pprofrec: failed to write to response writer: %v", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
 
 			return
 		}
+		if ok && r.URL.Query().Get("breakdown") == "true" {
+			writeGoroutineBreakdown(w, r, rec, id)
 
-		switch {
-		case len(rs) == 0:
-			break
-		case len(rs) == 1:
-			err = writeRow(w, c, rs[0], rs[0])
-			if err != nil {
-				log.Printf("pprofrec: failed to write to response writer: %v", err.Error())
-			}
-		default:
-			err = writeRow(w, c, rs[0], rs[1])
-			if err != nil {
-				log.Printf("pprofrec: failed to write to response writer: %v", err.Error())
-			}
-
-			for i := 2; i < len(rs); i++ {
-				err := writeRow(w, c, rs[i-1], rs[i])
-				if err != nil {
-					log.Printf("pprofrec: failed to write to response writer: %v", err.Error())
-				}
-			}
+			return
 		}
-	}
-}
+		if ok {
+			writeProfile(w, r, rec, id)
 
-// StreamOpts configures the Stream handler.
-type StreamOpts struct {
-	// Frequency defines at what frequency metrics are recorded and streamed.
-	Frequency time.Duration
-}
+			return
+		}
 
-// Stream streams runtime metrics at a given frequency as a html table.
-func Stream(opts StreamOpts) func(w http.ResponseWriter, r *http.Request) {
-	if opts.Frequency == time.Duration(0) {
-		opts.Frequency = 1 * time.Second
-	}
+		if acceptsGzip(r) {
+			gw := newGzipResponseWriter(w)
+			defer func() {
+				err := gw.Close()
+				if err != nil {
+					reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+				}
+			}()
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			err := r.Body.Close()
-			if err != nil {
-				log.Printf("pprofrec: failed to close request body: %v", err.Error())
-			}
-		}()
+			w = gw
+		}
 
-		var c capabilities
-		p, err := process.NewProcess(int32(os.Getpid()))
+		window, err := getQueryDuration(r, "window", 0)
 		if err != nil {
-			log.Printf("pprofrec: failed to create process instance: %v", err.Error())
-		} else {
-			c = getCapabilities(r.Context(), p)
-		}
+			http.Error(w, err.Error(), http.StatusBadRequest)
 
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			http.NotFound(w, r)
 			return
 		}
 
-		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
-
-		err = writeHead(w, c)
+		frequency, err := getQueryDuration(r, "freq", 0)
 		if err != nil {
-			log.Printf("pprofrec: failed to write to response writer: %v", err.Error())
-		}
-		flusher.Flush()
+			http.Error(w, err.Error(), http.StatusBadRequest)
 
-		previous := getRecord(r.Context(), c, p)
-		var current record
-		ticker := time.NewTicker(opts.Frequency)
-		for range ticker.C {
-			select {
-			case <-r.Context().Done():
-				return
-			default:
-				current = getRecord(r.Context(), c, p)
+			return
+		}
 
-				err = writeRow(w, c, previous, current)
-				if err != nil {
-					log.Printf("pprofrec: failed to write to response writer: %v", err.Error())
-				}
-				flusher.Flush()
+		last, err := getQueryCount(r, "last")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 
-				previous = current
-			}
+			return
 		}
-	}
-}
-
-// getCapabilities determines what metrics are available on the current OS
-func getCapabilities(ctx context.Context, p *process.Process) (c capabilities) {
-	_, err := p.TimesWithContext(ctx)
-	if err == nil || err.Error() != "not implemented yet" {
-		c.cpuTimeStat = true
-	}
 
-	_, err = p.IOCountersWithContext(ctx)
-	if err == nil || err.Error() != "not implemented yet" {
-		c.iOCounterStat = true
-	}
+		res, err := getQueryDuration(r, "res", rec.opts.Frequency)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 
-	_, err = p.MemoryInfoWithContext(ctx)
-	if err == nil || err.Error() != "not implemented yet" {
-		c.memoryInfoStat = true
-	}
+			return
+		}
 
-	return
-}
+		rs, ok := rec.RecordsAt(res)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown resolution: %v", res), http.StatusBadRequest)
 
-// getRecords records a snapshot of the available metrics
-func getRecord(ctx context.Context, c capabilities, p *process.Process) (r record) {
-	r.ts = time.Now()
-
-	var ms runtime.MemStats
-	runtime.ReadMemStats(&ms)
-	r.memStats = ms
-
-	r.pprofPair = pprofStat{
-		goroutine:    pprof.Lookup("goroutine").Count(),
-		threadcreate: pprof.Lookup("threadcreate").Count(),
-		heap:         pprof.Lookup("heap").Count(),
-		allocs:       pprof.Lookup("allocs").Count(),
-		block:        pprof.Lookup("block").Count(),
-		mutex:        pprof.Lookup("mutex").Count(),
-	}
+			return
+		}
+		rs = limitWindow(rs, window)
+		rs = limitFrequency(rs, frequency, res/2)
 
-	if c.cpuTimeStat {
-		cpuTimeStat, err := p.TimesWithContext(ctx)
+		d, err := getQueryDisplay(r, opts.display())
 		if err != nil {
-			log.Printf("pprofrec: failed to get cpu time stats: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
 		}
-		if cpuTimeStat != nil {
-			r.cpuTimeStat = *cpuTimeStat
-		} else {
-			r.cpuTimeStat = cpu.TimesStat{}
+
+		// clock times refer to the timezone that times are rendered in
+		var ref time.Time
+		if len(rs) > 0 {
+			ref = rs[len(rs)-1].Time.In(d.getLocation())
 		}
-	}
 
-	if c.iOCounterStat {
-		iOCounterStat, err := p.IOCountersWithContext(ctx)
+		from, fromOK, err := getQueryTime(r, "from", ref)
 		if err != nil {
-			log.Printf("pprofrec: failed to get io counter stats: %s", err)
-		}
-		if iOCounterStat != nil {
-			r.iOCounterStat = *iOCounterStat
-		} else {
-			r.iOCounterStat = process.IOCountersStat{}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
 		}
-	}
 
-	if c.memoryInfoStat {
-		memoryInfoStat, err := p.MemoryInfoWithContext(ctx)
+		to, toOK, err := getQueryTime(r, "to", ref)
 		if err != nil {
-			log.Printf("pprofrec: failed to get memory info stats: %s", err)
-		}
-		if memoryInfoStat != nil {
-			r.memoryInfoStat = *memoryInfoStat
-		} else {
-			r.memoryInfoStat = process.MemoryInfoStat{}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
 		}
-	}
 
-	return
-}
+		diff := r.URL.Query().Get("diff") == "true"
+		if diff && !(fromOK && toOK) {
+			http.Error(w, "from and to have to be specified together with diff", http.StatusBadRequest)
 
-func writeHead(w io.Writer, c capabilities) (err error) {
-	_, err = w.Write([]byte(`
-<!DOCTYPE html>
-<html>
-<head>
-	<style>
-		body, table {
-			font-family:Courier, monospace;
-			font-size: 13px;
-			white-space: nowrap;
-			border-spacing: 0px;
-			margin: 0px;
-			padding: 0px;
+			return
 		}
 
-		table          { 
-			overflow-y: auto; 
-			height: 100px; 
+		// the records nearest to from and to are rendered as a single row that shows the delta between them
+		switch {
+		case diff && len(rs) > 0:
+			rs = []Record{nearestRecord(rs, from), nearestRecord(rs, to)}
+		case fromOK || toOK:
+			rs = limitTimeRange(rs, from, to)
 		}
+		cols := getQueryColumns(r).filter(rec.s.cols)
 
-		table thead th { 
-			background-color: white; 
-			border-color: white;
-			text-align: left;
+		format := getFormat(r)
+		if download := r.URL.Query().Get("download"); download != "" {
+			switch download {
+			case formatHTML, formatJSON, formatCSV:
+			default:
+				http.Error(w, "unsupported download format", http.StatusBadRequest)
+
+				return
+			}
+
+			format = download
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v"`, getDownloadFilename(ref, format)))
 		}
 
-		table td { 
-			padding-left: 5px; 
+		// the first record of the html table only serves as the previous record of its first row
+		switch {
+		case diff || last == 0:
+		case format == formatHTML:
+			rs = limitLast(rs, last+1)
+		default:
+			rs = limitLast(rs, last)
 		}
 
+		switch format {
+		case formatHTML:
+		case formatJSON:
+			w.Header().Set("Content-Type", "application/json")
 
-		.tbl__head1 th {
-			position: sticky;
-			top: 0px;
-			left: 69px;
-			padding-left: 1px;
-			background-color: white;
-		}
+			if r.URL.Query().Get("summary") == "true" {
+				err = writeJSONSummaries(w, getSummaries(cols, rs))
+			} else {
+				w.Header().Set(schemaVersionHeader, strconv.Itoa(SchemaVersion))
 
-		.tbl__head1__th1 { 
-			left: 0px !important;
-			z-index: 50;
-			border-right: 1px solid gray;
-		}
+				err = writeJSON(w, rs)
+			}
+			if err != nil {
+				reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+			}
 
-		.tbl__head2 th { 
-			position: sticky; 
-			top: 15px; 
-			padding-bottom: 5px;
-			border-bottom: 1px solid gray;
-		}
-		
+			return
+		case formatCSV:
+			w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
 
-		.tbl__th-time { 
-			position: sticky;
-			top: 0;
-			left: 0;
-			border-right: 1px solid gray;
-			z-index: 20;
-		}
+			err = writeCSV(w, cols, rs, d.raw)
+			if err != nil {
+				reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+			}
 
-		.tbl__col1 {
-		  position: -webkit-sticky;
-		  position: sticky;
-		  background-color: white;
-		  left: 0px;
-		  padding-left: 0px;
-		  padding-right: 5px;
-		  font-weight: bold;
-		  border-right: 1px solid gray;
+			return
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+
+			return
 		}
-	</style>
-	<title></title>
-</head>
-<body>
-	<table>
-			<thead class="tbl__head1">
-				<th class="tbl__head1__th1" colspan="1"></th>`))
-	if err != nil {
-		return
-	}
 
-	_, err = w.Write([]byte(`<th colspan="12"><a target="_blank" href="https://godoc.org/runtime/pprof#Lookup">pprof.Lookup</a></th>`))
-	if err != nil {
-		return
-	}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
-	_, err = w.Write([]byte(`<th colspan="52"><a target="_blank" href="https://godoc.org/runtime#MemStats">runtime.MemStats</a></th>`))
-	if err != nil {
-		return
-	}
+		if opts.Template != nil {
+			leaks, _ := detectLeaks(rs, rec.opts.Leak.Span)
+
+			err = writeTemplate(w, opts.Template, getTemplateData(cols, rs, leaks, rec.metadata()))
+			if err != nil {
+				reportError(opts.OnError, fmt.Errorf("failed to execute template: %w", err))
+			}
 
-	if c.memoryInfoStat {
-		_, err = w.Write([]byte(`<th colspan="14"><a target="_blank" href="https://godoc.org/github.com/shirou/gopsutil/process#MemoryInfoStat">process.MemoryInfoStat</a></th>`))
-		if err != nil {
 			return
 		}
-	}
 
-	if c.cpuTimeStat {
-		_, err = w.Write([]byte(`<th colspan="20"><a target="_blank" href="https://godoc.org/github.com/shirou/gopsutil/cpu#TimesStat">cpu.TimesStat</a></th>`))
+		err = writeHead(w, cols, rs, opts.Theme, d)
 		if err != nil {
+			reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+
 			return
 		}
-	}
 
-	if c.iOCounterStat {
-		_, err = w.Write([]byte(`<th colspan="8"><a target="_blank" href="https://godoc.org/github.com/shirou/gopsutil/process#IOCountersStat">process.IOCountersStat</a></th>`))
+		m := rec.metadata()
+
+		err = writeMetadata(w, cols, m, d)
 		if err != nil {
+			reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+
 			return
 		}
-	}
-
-	_, err = w.Write([]byte(`</thead>
-			<thead class="tbl__head2">
-				<th class="tbl__th-time">time</th>`))
-	if err != nil {
-		return
-	}
 
-	err = writePprofTLookupMetricsHead(w)
-	if err != nil {
-		return
-	}
+		// profiles and annotations of records that are no longer rendered are attached to the first row
+		ps := rec.Profiles()
+		leaks, _ := detectLeaks(rs, rec.opts.Leak.Span)
 
-	err = writeRuntimeMemStatsMetricsTHead(w)
-	if err != nil {
-		return
-	}
+		err = writeRows(w, cols, rs, d.newestFirst, func(i int, from time.Time, to time.Time) rowMeta {
+			// changes of the tags are marked, those of the first row relative to the metadata
+			previous := m.Tags
+			if i > 1 {
+				previous = rs[i-1].Tags
+			}
 
-	if c.memoryInfoStat {
-		err = writeProcessMemoryInfoStatMetricsTHead(w)
+			return rowMeta{
+				links:       getProfileLinks(ps, from, to),
+				leak:        leaks[i],
+				highlights:  highlights,
+				annotations: append(rec.annotations.between(from, to), getTagAnnotations(previous, rs[i])...),
+				display:     d,
+			}
+		})
 		if err != nil {
+			reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+
 			return
 		}
-	}
 
-	if c.cpuTimeStat {
-		err = writeProcessCPUTimesStatMetricsTHead(w)
+		err = writeSummaries(w, cols, getSummaries(cols, rs), d)
 		if err != nil {
-			return
+			reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
 		}
 	}
+}
+
+// writeRows writes a row for every record in rs that shows the delta to the previous record,
+// the first row shows the delta between the first two records. meta returns the metadata of the row
+// of the record at i, which covers the time after from until to, from is zero for the first row.
+// The rows are written from latest to oldest if newestFirst.
+func writeRows(w io.Writer, cols []column, rs []Record, newestFirst bool, meta func(i int, from time.Time, to time.Time) rowMeta) (err error) {
+	switch {
+	case len(rs) == 0:
+		return
+	case len(rs) == 1:
+		return writeRow(w, cols, rs[0], rs[0], meta(0, time.Time{}, rs[0].Time))
+	}
+
+	for k := 1; k < len(rs); k++ {
+		i := k
+		if newestFirst {
+			i = len(rs) - k
+		}
 
-	if c.iOCounterStat {
-		err = writeProcessIOCountersStatMetricsTHead(w)
+		var from time.Time
+		if i > 1 {
+			from = rs[i-1].Time
+		}
+
+		err = writeRow(w, cols, rs[i-1], rs[i], meta(i, from, rs[i].Time))
 		if err != nil {
 			return
 		}
 	}
 
-	_, err = w.Write([]byte(`</thead><tbody>`))
-	if err != nil {
-		return
-	}
-
 	return
 }
 
-func writePprofTLookupMetricsHead(w io.Writer) (err error) {
-	_, err = w.Write([]byte(`<th colspan="2">goroutine</th>
-<th colspan="2">threadcreate</th>
-<th colspan="2">heap</th>
-<th colspan="2">allocs</th>
-<th colspan="2">block</th>
-<th colspan="2">mutex</th>`))
-	if err != nil {
-		return
-	}
+// StreamOpts configures the Stream handler.
+type StreamOpts struct {
+	// Frequency defines at what frequency metrics are recorded and streamed, defaults to 1s if zero.
+	Frequency time.Duration
+	// MinFrequency bounds the frequency that a request can specify via ?freq=, defaults to 100ms if zero.
+	MinFrequency time.Duration
+	// MaxClients caps the number of concurrent streams, further requests are rejected with 503.
+	// It is unlimited if zero.
+	MaxClients int
+	// MaxDuration ends streams after the given duration, also those that request a longer ?window=.
+	// It is unlimited if zero.
+	MaxDuration time.Duration
+	// Heartbeat is the interval at which a heartbeat is written regardless of the frequency,
+	// so that proxies and load balancers don't close idle streams. It is disabled if zero.
+	Heartbeat time.Duration
+	// Columns selects the metrics that are recorded and streamed.
+	Columns Columns
+	// Collectors collect application-defined metrics that are recorded alongside the runtime metrics.
+	Collectors []Collector
+	// Highlights configures thresholds above which cells of the html table are highlighted.
+	Highlights []Highlight
+	// Theme configures the look of the html table, e.g. DarkTheme.
+	Theme Theme
+	// Time configures how the time column of the html table is rendered.
+	Time TimeOpts
+	// Display selects whether values, deltas or both are rendered per column, it can be overridden by ?display=values.
+	Display DisplayMode
+	// Auth restricts access to the handler.
+	Auth Auth
+	// OnError is called with failures to sample and render metrics, they are logged if nil.
+	OnError func(error)
+	// Recorder's annotations are rendered as marker rows in the html table if set.
+	Recorder *Recorder
+}
 
-	return
+// display returns how the html table is rendered unless overridden by the request,
+// rows are always appended as they are recorded.
+func (opts StreamOpts) display() display {
+	return display{timeFormat: opts.Time.Format, location: opts.Time.Location, mode: opts.Display}
 }
 
-func writeProcessMemoryInfoStatMetricsTHead(w io.Writer) (err error) {
-	_, err = w.Write([]byte(`<th colspan="2">.RSS</th>
-<th colspan="2">.VMS</th>
-<th colspan="2">.HWM</th>
-<th colspan="2">.Data</th>
-<th colspan="2">.Stack</th>
-<th colspan="2">.Locked</th>
-<th colspan="2">.Swap</th>`))
-	if err != nil {
-		return
+// withDefaults returns opts with the zero values replaced by their documented defaults.
+func (opts StreamOpts) withDefaults() StreamOpts {
+	if opts.Frequency == time.Duration(0) {
+		opts.Frequency = 1 * time.Second
 	}
 
-	return
+	if opts.MinFrequency == time.Duration(0) {
+		opts.MinFrequency = 100 * time.Millisecond
+	}
+
+	return opts
 }
 
-func writeProcessIOCountersStatMetricsTHead(w io.Writer) (err error) {
-	_, err = w.Write([]byte(`<th colspan="2">.ReadCount</th> 
-<th colspan="2">.WriteCount</th>
-<th colspan="2">.ReadBytes</th> 
-<th colspan="2">.WriteBytes</th>`))
-	if err != nil {
-		return
+// Validate returns an error if the frequency or min frequency of opts is negative,
+// or if MaxClients, MaxDuration or Heartbeat is negative. Zero values are valid as they select the defaults.
+func (opts StreamOpts) Validate() error {
+	if opts.Frequency < 0 {
+		return fmt.Errorf("frequency %v must not be negative", opts.Frequency)
 	}
 
-	return
-}
+	if opts.MinFrequency < 0 {
+		return fmt.Errorf("min frequency %v must not be negative", opts.MinFrequency)
+	}
 
-func writeProcessCPUTimesStatMetricsTHead(w io.Writer) (err error) {
-	_, err = w.Write([]byte(`<th colspan="2">.User</th>
-<th colspan="2">.System</th>
-<th colspan="2">.Idle</th>
-<th colspan="2">.Nice</th>
-<th colspan="2">.Iowait</th>
-<th colspan="2">.Irq</th>
-<th colspan="2">.Softirq</th>
-<th colspan="2">.Steal</th>
-<th colspan="2">.Guest</th>
-<th colspan="2">.GuestNice</th>`))
-	if err != nil {
-		return
+	if opts.MaxClients < 0 {
+		return fmt.Errorf("max clients %v must not be negative", opts.MaxClients)
 	}
 
-	return
-}
+	if opts.MaxDuration < 0 {
+		return fmt.Errorf("max duration %v must not be negative", opts.MaxDuration)
+	}
 
-func writeRuntimeMemStatsMetricsTHead(w io.Writer) (err error) {
-	_, err = w.Write([]byte(`<th colspan="2">.Alloc</th>
-<th colspan="2">.TotalAlloc</th>
-<th colspan="2">.Sys</th>
-<th colspan="2">.Lookups</th>
-<th colspan="2">.Mallocs</th>
-<th colspan="2">.Frees</th>
-<th colspan="2">.HeapAlloc</th>
-<th colspan="2">.HeapSys</th>
-<th colspan="2">.HeapIdle</th>
-<th colspan="2">.HeapInuse</th>
-<th colspan="2">.HeapReleased</th>
-<th colspan="2">.HeapObjects</th>
-<th colspan="2">.StackInuse</th>
-<th colspan="2">.StackSys</th>
-<th colspan="2">.MSpanInuse</th>
-<th colspan="2">.MSpanSys</th>
-<th colspan="2">.MCacheInuse</th>
-<th colspan="2">.MCacheSys</th>
-<th colspan="2">.BuckHashSys</th>
-<th colspan="2">.GCSys</th>
-<th colspan="2">.OtherSys</th>
-<th colspan="2">.NextGC</th>
-<th colspan="2">.LastGC</th>
-<th colspan="2">.PauseTotalNs</th>
-<th colspan="2">.NumGC</th>
-<th colspan="2">.NumForcedGC</th>
-<th colspan="2">.OtherSys</th>`))
-	if err != nil {
-		return
+	if opts.Heartbeat < 0 {
+		return fmt.Errorf("heartbeat %v must not be negative", opts.Heartbeat)
 	}
 
-	return
+	return nil
 }
 
-func writeRow(w io.Writer, c capabilities, previous record, current record) (err error) {
-	_, err = w.Write([]byte(`<tr><td class="tbl__col1">`))
+// Stream streams runtime metrics at a given frequency as a html table.
+// The metrics are streamed as newline-delimited JSON instead if the request
+// specifies ?format=ndjson or accepts application/x-ndjson, and as server-sent events
+// with a JSON payload if the request specifies ?format=sse or accepts text/event-stream.
+// The html table can be trimmed to the columns selected by ?cols=goroutine,HeapAlloc,RSS.
+// All streams of a frequency are fed from a single sampling loop, which runs while streams are connected.
+// The frequency can be overridden by ?freq=250ms, bounded by MinFrequency,
+// and the stream can be limited to a duration by ?window=5m, bounded by MaxDuration.
+// The html table can be paused while the connection is kept, ?paused=true starts it paused.
+// Heartbeats are written as a html or SSE comment or as an empty NDJSON line, see StreamOpts.Heartbeat.
+// Times are rendered in the timezone given by ?tz=UTC and bytes and durations as exact integers by ?units=raw.
+// Only the values or only the deltas of the columns are rendered by ?display=values or ?display=deltas.
+// Responses are gzip encoded and flushed with every record if the request accepts it.
+// Invalid options are reported to opts.OnError and replaced by their defaults, see StreamOpts.Validate.
+func Stream(opts StreamOpts) http.HandlerFunc {
+	err := opts.Validate()
 	if err != nil {
-		return
-	}
+		reportError(opts.OnError, fmt.Errorf("invalid stream options, streaming with the defaults: %w", err))
 
-	_, err = w.Write([]byte(current.ts.Format("15:04:05")))
-	if err != nil {
-		return
+		opts.Frequency, opts.MinFrequency, opts.MaxClients, opts.MaxDuration, opts.Heartbeat = 0, 0, 0, 0, 0
 	}
+	opts = opts.withDefaults()
 
-	err = writePprof(w, previous.pprofPair, current.pprofPair)
-	if err != nil {
-		return
+	var clients chan struct{}
+	if opts.MaxClients > 0 {
+		clients = make(chan struct{}, opts.MaxClients)
 	}
 
-	err = writeMemStats(w, previous.memStats, current.memStats)
-	if err != nil {
-		return
-	}
+	h := newHub(opts.OnError, opts.Collectors, opts.Columns)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(opts.OnError, r)
+
+		if !opts.Auth.authorize(w, r) {
+			return
+		}
 
-	if c.memoryInfoStat {
-		err = writeMemoryInfoStat(w, previous.memoryInfoStat, current.memoryInfoStat)
+		format := getFormat(r)
+		switch format {
+		case formatHTML, formatNDJSON, formatSSE:
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+
+			return
+		}
+
+		d, err := getQueryDisplay(r, opts.display())
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
 			return
 		}
-	}
 
-	if c.cpuTimeStat {
-		err = writeCPUTimeStat(w, previous.cpuTimeStat, current.cpuTimeStat)
+		if clients != nil {
+			select {
+			case clients <- struct{}{}:
+				defer func() {
+					<-clients
+				}()
+			default:
+				http.Error(w, "too many streams", http.StatusServiceUnavailable)
+
+				return
+			}
+		}
+
+		frequency, err := getQueryDuration(r, "freq", opts.Frequency)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
 			return
 		}
-	}
+		if frequency < opts.MinFrequency {
+			frequency = opts.MinFrequency
+		}
 
-	if c.iOCounterStat {
-		err = writeIOCounterStat(w, previous.iOCounterStat, current.iOCounterStat)
+		window, err := getQueryDuration(r, "window", 0)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
 			return
 		}
-	}
 
-	_, err = w.Write([]byte("</td></tr>"))
-	if err != nil {
-		return
-	}
+		// streams are bounded by MaxDuration so that forgotten browser tabs can't hold connections forever
+		if opts.MaxDuration > 0 && (window == 0 || window > opts.MaxDuration) {
+			window = opts.MaxDuration
+		}
 
-	return
-}
+		ctx := r.Context()
+		if window > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, window)
+			defer cancel()
+		}
 
-func writePprof(w io.Writer, previous pprofStat, current pprofStat) (err error) {
-	err = writeIntCol(w, current.goroutine, current.goroutine-previous.goroutine)
-	if err != nil {
-		return
-	}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
 
-	err = writeIntCol(w, current.threadcreate, current.threadcreate-previous.threadcreate)
-	if err != nil {
-		return
-	}
+		if acceptsGzip(r) {
+			gw := newGzipResponseWriter(w)
+			defer func() {
+				err := gw.Close()
+				if err != nil {
+					reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+				}
+			}()
 
-	err = writeIntCol(w, current.heap, current.heap-previous.heap)
-	if err != nil {
-		return
-	}
+			w, flusher = gw, gw
+		}
 
-	err = writeIntCol(w, current.allocs, current.allocs-previous.allocs)
-	if err != nil {
-		return
-	}
+		hubCols, previous, rs, unsubscribe := h.subscribe(frequency)
+		defer unsubscribe()
 
-	err = writeIntCol(w, current.block, current.block-previous.block)
-	if err != nil {
-		return
-	}
+		cols := getQueryColumns(r).filter(hubCols)
 
-	err = writeIntCol(w, current.mutex, current.mutex-previous.mutex)
-	if err != nil {
-		return
-	}
+		// the tags of the recorder are attached to the streamed records, the rows mark changes relative to the metadata
+		m := getMetadata()
+		if opts.Recorder != nil {
+			m = opts.Recorder.metadata()
+			previous.Tags = opts.Recorder.tags.get()
+		}
+		shownTags := m.Tags
 
-	return
-}
+		switch format {
+		case formatNDJSON:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set(schemaVersionHeader, strconv.Itoa(SchemaVersion))
 
-func writeMemoryInfoStat(w io.Writer, previous process.MemoryInfoStat, current process.MemoryInfoStat) (err error) {
-	err = writeBytesCol(w, current.RSS, int64(current.RSS-previous.RSS))
-	if err != nil {
-		return
-	}
+			err = writeNDJSON(w, previous)
+		case formatSSE:
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set(schemaVersionHeader, strconv.Itoa(SchemaVersion))
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
 
-	err = writeBytesCol(w, current.VMS, int64(current.VMS-previous.VMS))
-	if err != nil {
-		return
-	}
+			err = writeSSE(w, previous)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
-	err = writeBytesCol(w, current.HWM, int64(current.HWM-previous.HWM))
-	if err != nil {
-		return
-	}
+			err = writeHead(w, cols, nil, opts.Theme, d)
+			if err != nil {
+				break
+			}
 
-	err = writeBytesCol(w, current.Data, int64(current.Data-previous.Data))
-	if err != nil {
-		return
-	}
+			err = writeMetadata(w, cols, m, d)
+			if err != nil {
+				break
+			}
 
-	err = writeBytesCol(w, current.Stack, int64(current.Stack-previous.Stack))
-	if err != nil {
-		return
-	}
+			_, err = w.Write([]byte(pauseControl))
+		}
+		if err != nil {
+			reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+		flusher.Flush()
 
-	err = writeBytesCol(w, current.Locked, int64(current.Locked-previous.Locked))
-	if err != nil {
-		return
-	}
+		meta := rowMeta{highlights: getHighlights(opts.Highlights, cols), display: d}
 
-	err = writeBytesCol(w, current.Swap, int64(current.Swap-previous.Swap))
-	if err != nil {
-		return
-	}
+		var heartbeats <-chan time.Time
+		if opts.Heartbeat > 0 {
+			heartbeat := time.NewTicker(opts.Heartbeat)
+			defer heartbeat.Stop()
 
-	return
-}
+			heartbeats = heartbeat.C
+		}
 
-func writeIOCounterStat(w io.Writer, previous process.IOCountersStat, current process.IOCountersStat) (err error) {
-	err = writeUint64Col(w, current.ReadCount, int64(current.ReadCount-previous.ReadCount))
-	if err != nil {
-		return
-	}
+		var current Record
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeats:
+				err = writeHeartbeat(w, format)
+				if err != nil {
+					reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+				}
+				flusher.Flush()
+			case current = <-rs:
+				if opts.Recorder != nil {
+					current.Tags = opts.Recorder.tags.get()
+				}
 
-	err = writeUint64Col(w, current.WriteCount, int64(current.WriteCount-previous.WriteCount))
-	if err != nil {
-		return
-	}
+				switch format {
+				case formatNDJSON:
+					err = writeNDJSON(w, current)
+				case formatSSE:
+					err = writeSSE(w, current)
+				default:
+					if opts.Recorder != nil {
+						meta.annotations = append(opts.Recorder.annotations.between(previous.Time, current.Time), getTagAnnotations(shownTags, current)...)
+						shownTags = current.Tags
+					}
+
+					err = writeRow(w, cols, previous, current, meta)
+				}
+				if err != nil {
+					reportError(opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+				}
+				flusher.Flush()
 
-	err = writeBytesCol(w, current.ReadBytes, int64(current.ReadBytes-previous.ReadBytes))
-	if err != nil {
-		return
+				previous = current
+			}
+		}
 	}
+}
 
-	err = writeBytesCol(w, current.WriteBytes, int64(current.WriteBytes-previous.WriteBytes))
-	if err != nil {
-		return
-	}
+// writeHead writes the stylesheet of theme and the table head, a sparkline that summarizes
+// each column over rs is rendered next to its label. Each column spans the cells rendered per column by d.
+func writeHead(w io.Writer, cols []column, rs []Record, theme Theme, d display) (err error) {
+	_, err = w.Write([]byte(`
+<!DOCTYPE html>
+<html>
+<head>
+	<style>
+		body, table {
+			font-family:Courier, monospace;
+			font-size: 13px;
+			white-space: nowrap;
+			border-spacing: 0px;
+			margin: 0px;
+			padding: 0px;
+		}
 
-	return
-}
+		table          { 
+			overflow-y: auto; 
+			height: 100px; 
+		}
 
-func writeCPUTimeStat(w io.Writer, previous cpu.TimesStat, current cpu.TimesStat) (err error) {
-	err = writeDuration(w, time.Duration(current.User*float64(time.Second)), time.Duration((current.User-previous.User)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		table thead th { 
+			background-color: white; 
+			border-color: white;
+			text-align: left;
+		}
 
-	err = writeDuration(w, time.Duration(current.System*float64(time.Second)), time.Duration((current.System-previous.System)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		table td { 
+			padding-left: 5px; 
+		}
 
-	err = writeDuration(w, time.Duration(current.Idle*float64(time.Second)), time.Duration((current.Idle-previous.Idle)*float64(time.Second)))
-	if err != nil {
-		return
-	}
 
-	err = writeDuration(w, time.Duration(current.Nice*float64(time.Second)), time.Duration((current.Nice-previous.Nice)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		.tbl__head1 th {
+			position: sticky;
+			top: 0px;
+			left: 69px;
+			padding-left: 1px;
+			background-color: white;
+		}
+
+		.tbl__head1__th1 { 
+			left: 0px !important;
+			z-index: 50;
+			border-right: 1px solid gray;
+		}
+
+		.tbl__head2 th { 
+			position: sticky; 
+			top: 15px; 
+			padding-bottom: 5px;
+			border-bottom: 1px solid gray;
+		}
+		
+
+		.tbl__th-time { 
+			position: sticky;
+			top: 0;
+			left: 0;
+			border-right: 1px solid gray;
+			z-index: 20;
+		}
 
-	err = writeDuration(w, time.Duration(current.Iowait*float64(time.Second)), time.Duration((current.Iowait-previous.Iowait)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		.tbl__col1 {
+		  position: -webkit-sticky;
+		  position: sticky;
+		  background-color: white;
+		  left: 0px;
+		  padding-left: 0px;
+		  padding-right: 5px;
+		  font-weight: bold;
+		  border-right: 1px solid gray;
+		}
 
-	err = writeDuration(w, time.Duration(current.Irq*float64(time.Second)), time.Duration((current.Irq-previous.Irq)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		.tbl__row--leak td {
+			background-color: #fde2e2;
+		}
 
-	err = writeDuration(w, time.Duration(current.Softirq*float64(time.Second)), time.Duration((current.Softirq-previous.Softirq)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		.tbl__row--annotation td {
+			background-color: #dbeafe;
+			font-weight: bold;
+		}
 
-	err = writeDuration(w, time.Duration(current.Steal*float64(time.Second)), time.Duration((current.Steal-previous.Steal)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		.tbl__row--instance td {
+			background-color: #e5e7eb;
+		}
 
-	err = writeDuration(w, time.Duration(current.Guest*float64(time.Second)), time.Duration((current.Guest-previous.Guest)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		.tbl__row--metadata td {
+			background-color: #f3f4f6;
+			font-weight: normal;
+		}
 
-	err = writeDuration(w, time.Duration(current.GuestNice*float64(time.Second)), time.Duration((current.GuestNice-previous.GuestNice)*float64(time.Second)))
-	if err != nil {
-		return
-	}
+		.tbl__row--summary td {
+			background-color: #f3f4f6;
+			border-top: 1px solid gray;
+		}
 
-	return
-}
+		td.tbl__cell--warning {
+			background-color: #fff3c4;
+		}
 
-func writeMemStats(w io.Writer, previous runtime.MemStats, current runtime.MemStats) (err error) {
-	err = writeBytesCol(w, current.Alloc, int64(current.Alloc-previous.Alloc))
-	if err != nil {
-		return
-	}
+		td.tbl__cell--critical {
+			background-color: #f8b4b4;
+		}
 
-	err = writeBytesCol(w, current.TotalAlloc, int64(current.TotalAlloc-previous.TotalAlloc))
-	if err != nil {
-		return
-	}
+		.pause {
+			margin: 5px 0px;
+		}
 
-	err = writeBytesCol(w, current.Sys, int64(current.Sys-previous.Sys))
-	if err != nil {
-		return
-	}
+		.cols label {
+			margin-right: 10px;
+			cursor: pointer;
+		}
 
-	err = writeUint64Col(w, current.Lookups, int64(current.Lookups-previous.Lookups))
-	if err != nil {
-		return
-	}
+		.tbl__sparkline {
+			margin-left: 4px;
+			vertical-align: middle;
+		}
 
-	err = writeUint64Col(w, current.Mallocs, int64(current.Mallocs-previous.Mallocs))
+		.tbl__sparkline polyline {
+			fill: none;
+			stroke: steelblue;
+			stroke-width: 1;
+		}
+	</style>
+`))
 	if err != nil {
 		return
 	}
 
-	err = writeUint64Col(w, current.Frees, int64(current.Frees-previous.Frees))
+	err = writeTheme(w, theme)
 	if err != nil {
 		return
 	}
 
-	err = writeBytesCol(w, current.HeapAlloc, int64(current.HeapAlloc-previous.HeapAlloc))
+	_, err = w.Write([]byte(`	<title></title>
+</head>
+<body>
+	<table>
+			<thead class="tbl__head1">
+				<th class="tbl__head1__th1" colspan="1"></th>`))
 	if err != nil {
 		return
 	}
 
-	err = writeBytesCol(w, current.HeapSys, int64(current.HeapSys-previous.HeapSys))
-	if err != nil {
-		return
-	}
+	for i := 0; i < len(cols); {
+		n := 1
+		for i+n < len(cols) && cols[i+n].group == cols[i].group {
+			n++
+		}
 
-	err = writeBytesCol(w, current.HeapIdle, int64(current.HeapIdle-previous.HeapIdle))
-	if err != nil {
-		return
-	}
+		// the names of replayed and aggregated columns are read from untrusted input
+		g := cols[i].group
+		_, err = fmt.Fprintf(w, `<th colspan="%d" data-group="%s"><a target="_blank" href="%s">%s</a></th>`, d.cells()*n, html.EscapeString(g.name), html.EscapeString(g.href), html.EscapeString(g.title))
+		if err != nil {
+			return
+		}
 
-	err = writeBytesCol(w, current.HeapInuse, int64(current.HeapInuse-previous.HeapInuse))
-	if err != nil {
-		return
+		i += n
 	}
 
-	err = writeBytesCol(w, current.HeapReleased, int64(current.HeapReleased-previous.HeapReleased))
+	_, err = w.Write([]byte(`</thead>
+			<thead class="tbl__head2">
+				<th class="tbl__th-time">time</th>`))
 	if err != nil {
 		return
 	}
 
-	err = writeUint64Col(w, current.HeapObjects, int64(current.HeapObjects-previous.HeapObjects))
-	if err != nil {
-		return
-	}
+	for _, col := range cols {
+		_, err = fmt.Fprintf(w, "<th colspan=\"%d\" data-group=\"%s\" data-col=\"%s.%s\">%s", d.cells(), html.EscapeString(col.group.name), html.EscapeString(col.group.name), html.EscapeString(col.name), html.EscapeString(col.label()))
+		if err != nil {
+			return
+		}
 
-	err = writeBytesCol(w, current.StackInuse, int64(current.StackInuse-previous.StackInuse))
-	if err != nil {
-		return
-	}
+		err = writeSparkline(w, col, rs)
+		if err != nil {
+			return
+		}
 
-	err = writeBytesCol(w, current.StackSys, int64(current.StackSys-previous.StackSys))
-	if err != nil {
-		return
+		_, err = w.Write([]byte("</th>\n"))
+		if err != nil {
+			return
+		}
 	}
 
-	err = writeBytesCol(w, current.MSpanInuse, int64(current.MSpanInuse-previous.MSpanInuse))
+	_, err = w.Write([]byte(`</thead><tbody>`))
 	if err != nil {
 		return
 	}
 
-	err = writeBytesCol(w, current.MSpanSys, int64(current.MSpanSys-previous.MSpanSys))
+	_, err = w.Write([]byte(columnControls))
 	if err != nil {
 		return
 	}
 
-	err = writeBytesCol(w, current.MCacheInuse, int64(current.MCacheInuse-previous.MCacheInuse))
-	if err != nil {
-		return
-	}
+	return
+}
 
-	err = writeBytesCol(w, current.MCacheSys, int64(current.MCacheSys-previous.MCacheSys))
-	if err != nil {
-		return
-	}
+// rowMeta holds what is rendered alongside the metrics of a row.
+type rowMeta struct {
+	// links are rendered next to the time.
+	links []link
+	// leak marks the row as part of a goroutine leak.
+	leak bool
+	// highlights are keyed by the qualified name of the column they apply to.
+	highlights map[string]Highlight
+	// annotations are rendered as marker rows before the row.
+	annotations []Annotation
+	// display configures how the values of the row are rendered.
+	display display
+}
 
-	err = writeBytesCol(w, current.BuckHashSys, int64(current.BuckHashSys-previous.BuckHashSys))
-	if err != nil {
-		return
-	}
+// link is rendered next to the time of a row.
+type link struct {
+	href  string
+	label string
+}
 
-	err = writeBytesCol(w, current.GCSys, int64(current.GCSys-previous.GCSys))
-	if err != nil {
-		return
-	}
+// rowBuffers holds the buffers that rows are rendered into, so that streaming rows does not allocate per cell.
+var rowBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 4096)
 
-	err = writeBytesCol(w, current.OtherSys, int64(current.OtherSys-previous.OtherSys))
-	if err != nil {
-		return
-	}
+		return &b
+	},
+}
 
-	err = writeBytesCol(w, current.NextGC, int64(current.NextGC-previous.NextGC))
-	if err != nil {
-		return
+// writeRow renders the row into a pooled buffer and writes it with a single Write,
+// annotations are written as their own rows before, or after if the rows are rendered newest first.
+func writeRow(w io.Writer, cols []column, previous Record, current Record, meta rowMeta) (err error) {
+	if !meta.display.newestFirst {
+		err = writeAnnotations(w, cols, meta.annotations, meta.display)
+		if err != nil {
+			return
+		}
 	}
 
-	err = writeTime(w, time.Unix(0, int64(current.LastGC)), time.Unix(0, int64(current.LastGC)).Sub(time.Unix(0, int64(previous.LastGC))))
-	if err != nil {
-		return
-	}
+	bp := rowBuffers.Get().(*[]byte)
+	defer rowBuffers.Put(bp)
 
-	err = writeDuration(w, time.Duration(current.PauseTotalNs), time.Duration(current.PauseTotalNs-previous.PauseTotalNs))
-	if err != nil {
-		return
-	}
+	b := (*bp)[:0]
 
-	err = writeUint64Col(w, uint64(current.NumGC), int64(current.NumGC-previous.NumGC))
-	if err != nil {
-		return
+	if meta.leak {
+		b = append(b, `<tr class="tbl__row--leak" title="goroutines are leaking"><td class="tbl__col1">`...)
+	} else {
+		b = append(b, `<tr><td class="tbl__col1">`...)
 	}
 
-	err = writeUint64Col(w, uint64(current.NumForcedGC), int64(current.NumForcedGC-previous.NumForcedGC))
-	if err != nil {
-		return
-	}
+	b = meta.display.appendTime(b, current.Time)
 
-	err = writeBytesCol(w, current.OtherSys, int64(current.OtherSys-previous.OtherSys))
-	if err != nil {
-		return
+	for _, l := range meta.links {
+		b = append(b, ` <a href="`...)
+		b = append(b, l.href...)
+		b = append(b, `">`...)
+		b = append(b, l.label...)
+		b = append(b, `</a>`...)
 	}
 
-	return
-}
+	for _, col := range cols {
+		// the qualified name is only built if there are highlights as it allocates
+		var h Highlight
+		if len(meta.highlights) > 0 {
+			h = meta.highlights[col.qualifiedName()]
+		}
 
-func writeDuration(w io.Writer, value time.Duration, diff time.Duration) (err error) {
-	_, err = w.Write([]byte("</td><td style=\"padding-left: 10px;\">"))
-	if err != nil {
-		return
+		b = appendCol(b, col, previous, current, h, meta.display)
 	}
 
-	_, err = w.Write([]byte(value.String()))
+	b = append(b, "</td></tr>"...)
+	*bp = b
+
+	_, err = w.Write(b)
 	if err != nil {
 		return
 	}
 
-	switch {
-	case diff > 0:
-		_, err = w.Write([]byte(`</td><td style="color: green;">`))
-		if err != nil {
-			return
-		}
-	case diff < 0:
-		_, err = w.Write([]byte(`</td><td style="color: red;">`))
-		if err != nil {
-			return
-		}
-	case diff == 0:
-		_, err = w.Write([]byte(`</td><td style="color: gray;">`))
+	if meta.display.newestFirst {
+		err = writeAnnotations(w, cols, meta.annotations, meta.display)
 		if err != nil {
 			return
 		}
 	}
 
-	_, err = w.Write([]byte(diff.String()))
-	if err != nil {
-		return
-	}
-
 	return
 }
 
-func writeTime(w io.Writer, value time.Time, diff time.Duration) (err error) {
-	_, err = w.Write([]byte("</td><td style=\"padding-left: 10px;\">"))
-	if err != nil {
-		return
-	}
+func appendCol(b []byte, col column, previous Record, current Record, h Highlight, d display) []byte {
+	v := col.value(current)
+	diff := v - col.value(previous)
 
-	_, err = w.Write([]byte(value.Format("15:04:05.000000000")))
-	if err != nil {
-		return
-	}
+	u := d.getUnit(col.unit)
+	class := h.class(v)
 
-	switch {
-	case diff > 0:
-		_, err = w.Write([]byte(`</td><td style="color: green;">`))
-		if err != nil {
-			return
-		}
-	case diff < 0:
-		_, err = w.Write([]byte(`</td><td style="color: red;">`))
-		if err != nil {
-			return
-		}
-	case diff == 0:
-		_, err = w.Write([]byte(`</td><td style="color: gray;">`))
-		if err != nil {
-			return
-		}
-	}
+	switch d.mode {
+	case DisplayValues:
+		b = appendCell(b, class, "padding-left: 10px;")
 
-	_, err = w.Write([]byte(diff.String()))
-	if err != nil {
-		return
-	}
+		return appendValue(b, u, v, diff, d)
+	case DisplayDeltas:
+		b = appendCell(b, class, "padding-left: 10px;", getDiffStyle(diff))
 
-	return
-}
+		return appendDelta(b, u, v, diff)
+	default:
+		b = appendCell(b, class, "padding-left: 10px;")
+		b = appendValue(b, u, v, diff, d)
+		b = appendCell(b, "", getDiffStyle(diff))
 
-func writeIntCol(w io.Writer, v int, diff int) (err error) {
-	_, err = w.Write([]byte("</td><td style=\"padding-left: 10px;\">"))
-	if err != nil {
-		return
+		return appendDelta(b, u, v, diff)
 	}
+}
 
-	_, err = w.Write([]byte(strconv.FormatInt(int64(v), 10)))
-	if err != nil {
-		return
+// appendCell closes the previous cell and opens a cell of class with the given styles, class may be empty.
+func appendCell(b []byte, class string, styles ...string) []byte {
+	b = append(b, `</td><td`...)
+	if class != "" {
+		b = append(b, ` class="`...)
+		b = append(b, class...)
+		b = append(b, '"')
 	}
 
-	switch {
-	case diff > 0:
-		_, err = w.Write([]byte(`</td><td style="color: green;">`))
-		if err != nil {
-			return
-		}
-	case diff < 0:
-		_, err = w.Write([]byte(`</td><td style="color: red;">`))
-		if err != nil {
-			return
-		}
-	case diff == 0:
-		_, err = w.Write([]byte(`</td><td style="color: gray;">`))
-		if err != nil {
-			return
+	b = append(b, ` style="`...)
+	for i, s := range styles {
+		if i > 0 {
+			b = append(b, ' ')
 		}
+		b = append(b, s...)
 	}
 
-	_, err = w.Write([]byte(strconv.FormatInt(int64(diff), 10)))
-	if err != nil {
-		return
-	}
-
-	return
+	return append(b, `">`...)
 }
 
-func writeUint64Col(w io.Writer, v uint64, diff int64) (err error) {
-	_, err = w.Write([]byte("</td><td style=\"padding-left: 10px;\">"))
-	if err != nil {
-		return
-	}
-
-	_, err = w.Write([]byte(strconv.FormatUint(v, 10)))
-	if err != nil {
-		return
-	}
-
+// getDiffStyle returns the style that colors a diff by its sign.
+func getDiffStyle(diff float64) string {
 	switch {
 	case diff > 0:
-		_, err = w.Write([]byte(`</td><td style="color: green;">`))
-		if err != nil {
-			return
-		}
+		return "color: green;"
 	case diff < 0:
-		_, err = w.Write([]byte(`</td><td style="color: red;">`))
-		if err != nil {
-			return
-		}
-	case diff == 0:
-		_, err = w.Write([]byte(`</td><td style="color: gray;">`))
-		if err != nil {
-			return
-		}
-	}
-
-	_, err = w.Write([]byte(strconv.FormatInt(diff, 10)))
-	if err != nil {
-		return
+		return "color: red;"
+	default:
+		return "color: gray;"
 	}
-
-	return
 }
 
-func writeBytesCol(w io.Writer, v uint64, diff int64) (err error) {
-	_, err = w.Write([]byte("</td><td style=\"padding-left: 10px;\">"))
-	if err != nil {
-		return
-	}
+// appendValue appends v rendered as u, counts are rendered with two decimals if v or diff has a fraction.
+func appendValue(b []byte, u unit, v float64, diff float64, d display) []byte {
+	switch u {
+	case unitBytes:
+		return appendHumanBytes(b, int64(v))
+	case unitDuration:
+		return append(b, time.Duration(v).String()...)
+	case unitTime:
+		return time.Unix(0, int64(v)).In(d.getLocation()).AppendFormat(b, "15:04:05.000000000")
+	case unitPercent:
+		b = strconv.AppendFloat(b, v, 'f', 2, 64)
+
+		return append(b, " %"...)
+	default:
+		if v != math.Trunc(v) || diff != math.Trunc(diff) {
+			return strconv.AppendFloat(b, v, 'f', 2, 64)
+		}
 
-	_, err = writeHumanBytes(w, int64(v))
-	if err != nil {
-		return
+		return strconv.AppendInt(b, int64(v), 10)
 	}
+}
 
-	switch {
-	case diff > 0:
-		_, err = w.Write([]byte(`</td><td style="color: green;">`))
-		if err != nil {
-			return
+// appendDelta appends diff rendered as u, counts are rendered with two decimals if v or diff has a fraction.
+func appendDelta(b []byte, u unit, v float64, diff float64) []byte {
+	switch u {
+	case unitBytes:
+		return appendHumanBytes(b, int64(diff))
+	case unitDuration, unitTime:
+		return append(b, time.Duration(diff).String()...)
+	case unitPercent:
+		b = strconv.AppendFloat(b, diff, 'f', 2, 64)
+
+		return append(b, " %"...)
+	default:
+		if v != math.Trunc(v) || diff != math.Trunc(diff) {
+			return strconv.AppendFloat(b, diff, 'f', 2, 64)
 		}
-	case diff < 0:
-		_, err = w.Write([]byte(`</td><td style="color: red;">`))
-		if err != nil {
-			return
-		}
-	case diff == 0:
-		_, err = w.Write([]byte(`</td><td style="color: gray;">`))
-		if err != nil {
-			return
-		}
-	}
 
-	_, err = writeHumanBytes(w, diff)
-	if err != nil {
-		return
+		return strconv.AppendInt(b, int64(diff), 10)
 	}
-
-	return
 }
 
 func writeHumanBytes(w io.Writer, bytes int64) (n int, err error) {
+	return w.Write(appendHumanBytes(nil, bytes))
+}
+
+// appendHumanBytes appends bytes in binary units with three decimals, e.g. 1.500 KiB.
+func appendHumanBytes(b []byte, bytes int64) []byte {
 	var abs uint64
 	if bytes < 0 {
 		abs = uint64(-bytes)
@@ -1057,11 +1105,15 @@ func writeHumanBytes(w io.Writer, bytes int64) (n int, err error) {
 	}
 
 	if abs < 1024 {
-		return fmt.Fprintf(w, "%d B", bytes)
+		b = strconv.AppendInt(b, bytes, 10)
+
+		return append(b, " B"...)
 	}
 
 	base := uint(bits.Len64(abs) / 10)
 	val := float64(bytes) / float64(uint64(1<<(base*10)))
 
-	return fmt.Fprintf(w, "%.3f %ciB", val, " KMGTPE"[base])
+	b = strconv.AppendFloat(b, val, 'f', 3, 64)
+
+	return append(b, ' ', " KMGTPE"[base], 'i', 'B')
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +32,106 @@ func TestStream(t *testing.T) {
 	assert.Contains(t, w.Buffer.String(), "MiB")
 }
 
+func TestStreamMaxClients(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: 10 * time.Millisecond, MaxClients: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:8080?format=ndjson", nil)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(&responseWriter{}, r)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=ndjson", http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	cancel()
+	<-done
+
+	w = httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=ndjson&window=50ms", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestStreamOptsValidate(t *testing.T) {
+	assert.NoError(t, StreamOpts{}.Validate())
+	assert.EqualError(t, StreamOpts{Frequency: -time.Second}.Validate(), "frequency -1s must not be negative")
+	assert.EqualError(t, StreamOpts{MaxClients: -1}.Validate(), "max clients -1 must not be negative")
+	assert.EqualError(t, StreamOpts{Heartbeat: -time.Second}.Validate(), "heartbeat -1s must not be negative")
+
+	var errs []error
+	f := Stream(StreamOpts{MinFrequency: -time.Second, OnError: func(err error) { errs = append(errs, err) }})
+	require.NotNil(t, f)
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "invalid stream options, streaming with the defaults: min frequency -1s must not be negative")
+}
+
+func TestWriteCol(t *testing.T) {
+	g := &group{name: "host", title: "host"}
+	previous := Record{Samples: map[string][]Sample{"host": {{Name: "cpu", Value: 12.5}, {Name: "load1", Value: 0.25}}}}
+	current := Record{Samples: map[string][]Sample{"host": {{Name: "cpu", Value: 20}, {Name: "load1", Value: 0.5}}}}
+
+	b := appendCol(nil, getSampleColumn(g, Sample{Name: "cpu", Unit: UnitPercent}), previous, current, Highlight{}, display{})
+	assert.Equal(t, `</td><td style="padding-left: 10px;">20.00 %</td><td style="color: green;">7.50 %`, string(b))
+
+	b = appendCol(nil, getSampleColumn(g, Sample{Name: "load1"}), previous, current, Highlight{}, display{})
+	assert.Equal(t, `</td><td style="padding-left: 10px;">0.50</td><td style="color: green;">0.25`, string(b))
+
+	b = appendCol(nil, memStatsColumns[len(memStatsColumns)-1], Record{}, Record{MemStats: MemStats{GCCPUFraction: 0.0125}}, Highlight{}, display{})
+	assert.Equal(t, `</td><td style="padding-left: 10px;">1.25 %</td><td style="color: green;">1.25 %`, string(b))
+
+	previous = Record{Samples: map[string][]Sample{"host": {{Name: "delta", Value: 3}}}}
+	current = Record{Samples: map[string][]Sample{"host": {{Name: "delta", Value: -5}}}}
+	b = appendCol(nil, getSampleColumn(g, Sample{Name: "delta"}), previous, current, Highlight{}, display{})
+	assert.Equal(t, `</td><td style="padding-left: 10px;">-5</td><td style="color: red;">-8`, string(b))
+
+	current = Record{Samples: map[string][]Sample{"host": {{Name: "skew", Value: -1.5}}}}
+	b = appendCol(nil, getSampleColumn(g, Sample{Name: "skew", Unit: UnitSeconds}), Record{}, current, Highlight{}, display{raw: true})
+	assert.Equal(t, `</td><td style="padding-left: 10px;">-1500000000</td><td style="color: red;">-1500000000`, string(b))
+}
+
+func TestAppendHumanBytes(t *testing.T) {
+	assert.Equal(t, "512 B", string(appendHumanBytes(nil, 512)))
+	assert.Equal(t, "-512 B", string(appendHumanBytes(nil, -512)))
+	assert.Equal(t, "1.500 KiB", string(appendHumanBytes(nil, 1536)))
+	assert.Equal(t, "-2.000 MiB", string(appendHumanBytes(nil, -2<<20)))
+}
+
+type writeCounter struct {
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+
+	return len(p), nil
+}
+
+func TestWriteRowSingleWrite(t *testing.T) {
+	cols := getColumns(capabilities{})
+	rs := goroutineRecords(time.Now(), 1, 2)
+
+	w := &writeCounter{}
+	require.NoError(t, writeRow(w, cols, rs[0], rs[1], rowMeta{links: []link{{href: "/profile", label: "profile"}}}))
+	assert.Equal(t, 1, w.writes)
+
+	if raceEnabled {
+		t.Skip("skipping allocation check: the race detector allocates")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = writeRow(w, cols, rs[0], rs[1], rowMeta{})
+	})
+	assert.Zero(t, allocs)
+}
+
 type responseWriter struct {
 	Buffer     bytes.Buffer
 	StatusCode int
@@ -48,3 +150,33 @@ func (w *responseWriter) WriteHeader(statusCode int) {
 }
 
 func (w *responseWriter) Flush() {}
+
+func TestWriteRowsNewestFirst(t *testing.T) {
+	cols := Columns{Include: []string{"goroutine"}}.filter(getColumns(capabilities{}))
+	ts := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	rs := goroutineRecords(ts, 10, 20, 30, 40)
+
+	for _, newestFirst := range []bool{false, true} {
+		var metas []int
+		var b bytes.Buffer
+		require.NoError(t, writeRows(&b, cols, rs, newestFirst, func(i int, from time.Time, to time.Time) rowMeta {
+			metas = append(metas, i)
+			if i == 1 {
+				assert.True(t, from.IsZero())
+			} else {
+				assert.Equal(t, rs[i-1].Time, from)
+			}
+			assert.Equal(t, rs[i].Time, to)
+
+			return rowMeta{display: display{location: time.UTC, newestFirst: newestFirst}}
+		}))
+
+		if newestFirst {
+			assert.Equal(t, []int{3, 2, 1}, metas)
+			assert.True(t, strings.Index(b.String(), "12:00:03") < strings.Index(b.String(), "12:00:01"))
+		} else {
+			assert.Equal(t, []int{1, 2, 3}, metas)
+			assert.True(t, strings.Index(b.String(), "12:00:01") < strings.Index(b.String(), "12:00:03"))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package pprofrecclient consumes the streams of the pprofrec Stream handler,
+// so that other Go services and tests can react to the runtime metrics of a process programmatically.
+package pprofrecclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ppwfx/pprofrec"
+)
+
+// Watch streams the records of the Stream handler at rawURL, e.g. http://localhost:8080/debug/pprof/stream.
+// The records are requested as newline-delimited JSON unless rawURL specifies ?format=sse.
+// The returned channel is closed once ctx is done or the stream ends, the error is returned
+// if the stream cannot be opened. The channel is also closed if the stream breaks or sends a record that does not decode,
+// these errors are logged, use WatchWithClient to handle them.
+func Watch(ctx context.Context, rawURL string) (<-chan pprofrec.Record, error) {
+	return WatchWithClient(ctx, http.DefaultClient, rawURL, nil, nil)
+}
+
+// WatchWithClient is like Watch but opens the stream with client and sends header with the request,
+// e.g. to authorize against the Stream handler. onError is called if the stream breaks or sends a record
+// that does not decode before the channel is closed, the error is logged if onError is nil.
+func WatchWithClient(ctx context.Context, client *http.Client, rawURL string, header http.Header, onError func(error)) (<-chan pprofrec.Record, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	if q.Get("format") == "" {
+		q.Set("format", "ndjson")
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		b := make([]byte, 512)
+		n, _ := io.ReadFull(resp.Body, b)
+
+		return nil, fmt.Errorf("unexpected status code: %v: %v", resp.StatusCode, strings.TrimSpace(string(b[:n])))
+	}
+
+	rs := make(chan pprofrec.Record)
+
+	go func() {
+		defer close(rs)
+		defer resp.Body.Close()
+
+		sse := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+
+		s := bufio.NewScanner(resp.Body)
+		s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for s.Scan() {
+			line := s.Bytes()
+			if sse {
+				// records are sent as the data of server-sent events, other fields and blank lines are skipped
+				if !strings.HasPrefix(string(line), "data: ") {
+					continue
+				}
+				line = line[len("data: "):]
+			}
+			if len(line) == 0 {
+				continue
+			}
+
+			var r pprofrec.Record
+			err := json.Unmarshal(line, &r)
+			if err != nil {
+				reportError(onError, fmt.Errorf("failed to decode record: %w", err))
+
+				return
+			}
+
+			select {
+			case rs <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// reading the body fails once ctx is done, which ends the stream as requested
+		if s.Err() != nil && ctx.Err() == nil {
+			reportError(onError, fmt.Errorf("failed to read stream: %w", s.Err()))
+		}
+	}()
+
+	return rs, nil
+}
+
+// reportError passes err to onError, err is logged if onError is nil.
+func reportError(onError func(error), err error) {
+	if onError != nil {
+		onError(err)
+
+		return
+	}
+
+	log.Printf("pprofrecclient: %v", err.Error())
+}
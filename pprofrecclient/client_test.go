@@ -0,0 +1,88 @@
+package pprofrecclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ppwfx/pprofrec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(pprofrec.Stream(pprofrec.StreamOpts{Frequency: 10 * time.Millisecond})))
+	defer s.Close()
+
+	for _, path := range []string{"/", "/?format=sse"} {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		rs, err := Watch(ctx, s.URL+path)
+		require.NoError(t, err, path)
+
+		for i := 0; i < 3; i++ {
+			r, ok := <-rs
+			require.True(t, ok, path)
+			assert.NotZero(t, r.Pprof.Goroutine, path)
+		}
+
+		cancel()
+		for range rs {
+		}
+	}
+}
+
+func TestWatchStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(pprofrec.Stream(pprofrec.StreamOpts{Frequency: 10 * time.Millisecond, Auth: pprofrec.Auth{Token: "secret"}})))
+	defer s.Close()
+
+	_, err := Watch(context.Background(), s.URL)
+	assert.Error(t, err)
+
+	rs, err := WatchWithClient(context.Background(), http.DefaultClient, s.URL+"?window=50ms", http.Header{"Authorization": {"Bearer secret"}}, nil)
+	require.NoError(t, err)
+
+	var n int
+	for range rs {
+		n++
+	}
+	assert.NotZero(t, n)
+}
+
+func TestWatchErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{\"time\":\"2020-01-01T00:00:00Z\"}\n{\"time\":\n"))
+	}))
+	defer s.Close()
+
+	var errs []error
+	rs, err := WatchWithClient(context.Background(), http.DefaultClient, s.URL, nil, func(err error) { errs = append(errs, err) })
+	require.NoError(t, err)
+
+	var n int
+	for range rs {
+		n++
+	}
+	assert.Equal(t, 1, n)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "failed to decode record")
+}
+
+func TestWatchBrokenStream(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("{\"time\":\"2020-01-01T00:00:00Z\"}\n"))
+	}))
+	defer s.Close()
+
+	var errs []error
+	rs, err := WatchWithClient(context.Background(), http.DefaultClient, s.URL, nil, func(err error) { errs = append(errs, err) })
+	require.NoError(t, err)
+
+	for range rs {
+	}
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "failed to read stream")
+}
@@ -0,0 +1,35 @@
+package pprofrecgrpc
+
+import (
+	"context"
+
+	"github.com/ppwfx/pprofrec"
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor records the resource usage of unary RPCs in s under their full method name,
+// mirroring pprofrec.Middleware, so that the RPCs are rendered by the pprofrec.Routes handler.
+//
+//	grpcServer := grpc.NewServer(
+//		grpc.UnaryInterceptor(pprofrecgrpc.UnaryServerInterceptor(pprofrec.DefaultRouteStats)),
+//		grpc.StreamInterceptor(pprofrecgrpc.StreamServerInterceptor(pprofrec.DefaultRouteStats)),
+//	)
+func UnaryServerInterceptor(s *pprofrec.RouteStats) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done := s.Observe(info.FullMethod)
+		defer done()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor records the resource usage of streaming RPCs in s under their full method name,
+// a stream is recorded once it ends.
+func StreamServerInterceptor(s *pprofrec.RouteStats) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := s.Observe(info.FullMethod)
+		defer done()
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,49 @@
+package pprofrecgrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ppwfx/pprofrec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	s := &pprofrec.RouteStats{}
+	i := UnaryServerInterceptor(s)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pprofrec.Metrics/Get"}
+	resp, err := i(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return make([]byte, 1024), nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, resp, 1024)
+
+	_, err = i(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	stats := s.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, "/pprofrec.Metrics/Get", stats[0].Route)
+	assert.Equal(t, int64(2), stats[0].Requests)
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	s := &pprofrec.RouteStats{}
+	i := StreamServerInterceptor(s)
+
+	err := i(nil, nil, &grpc.StreamServerInfo{FullMethod: "/pprofrec.Metrics/Watch"}, func(srv interface{}, ss grpc.ServerStream) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	stats := s.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, "/pprofrec.Metrics/Watch", stats[0].Route)
+	assert.Equal(t, int64(1), stats[0].Requests)
+}
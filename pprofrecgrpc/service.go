@@ -0,0 +1,129 @@
+// Package pprofrecgrpc serves the records of a pprofrec recorder via gRPC,
+// for infrastructures where internal tooling speaks gRPC rather than scraping HTTP endpoints.
+// The protobuf schema is defined in record.proto.
+package pprofrecgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative record.proto
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/ppwfx/pprofrec"
+)
+
+// Service implements the Metrics service, it receives the records as a sink of the recorder.
+//
+//	svc := pprofrecgrpc.NewService()
+//	rec, err := pprofrec.NewRecorder(pprofrec.WithSinks(svc))
+//	pprofrecgrpc.RegisterMetricsServer(grpcServer, svc)
+type Service struct {
+	UnimplementedMetricsServer
+
+	mu       sync.Mutex
+	watchers map[chan *Record]bool
+}
+
+// NewService returns a Service without watchers.
+func NewService() *Service {
+	return &Service{watchers: map[chan *Record]bool{}}
+}
+
+// Write sends the record to all watchers, records are dropped for watchers that fall behind.
+func (s *Service) Write(ctx context.Context, r pprofrec.Record, ms []pprofrec.Metric) error {
+	pr := getRecord(r, ms)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for w := range s.watchers {
+		select {
+		case w <- pr:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Watch streams every record written after the call until the client cancels.
+func (s *Service) Watch(req *WatchRequest, stream Metrics_WatchServer) error {
+	w := make(chan *Record, 64)
+
+	s.mu.Lock()
+	s.watchers[w] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case r := <-w:
+			err := stream.Send(filterRecord(r, req.Columns))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// getRecord converts a record and the values of its columns to its protobuf message.
+func getRecord(r pprofrec.Record, ms []pprofrec.Metric) *Record {
+	pr := &Record{
+		TimeUnixNano: r.Time.UnixNano(),
+		Metrics:      make([]*Metric, len(ms)),
+		Annotations:  make([]*Annotation, len(r.Annotations)),
+	}
+
+	for i, m := range ms {
+		pr.Metrics[i] = &Metric{Group: m.Group, Name: m.Name, Value: m.Value, Unit: getUnit(m.Unit)}
+	}
+
+	for i, a := range r.Annotations {
+		pr.Annotations[i] = &Annotation{TimeUnixNano: a.Time.UnixNano(), Label: a.Label}
+	}
+
+	return pr
+}
+
+func getUnit(u pprofrec.Unit) Unit {
+	switch u {
+	case pprofrec.UnitBytes:
+		return Unit_UNIT_BYTES
+	case pprofrec.UnitSeconds:
+		return Unit_UNIT_SECONDS
+	case pprofrec.UnitTime:
+		return Unit_UNIT_TIME
+	case pprofrec.UnitPercent:
+		return Unit_UNIT_PERCENT
+	default:
+		return Unit_UNIT_COUNT
+	}
+}
+
+// filterRecord returns r with the metrics selected by columns, all metrics are selected if columns is empty.
+func filterRecord(r *Record, columns []string) *Record {
+	if len(columns) == 0 {
+		return r
+	}
+
+	filtered := &Record{TimeUnixNano: r.TimeUnixNano, Annotations: r.Annotations}
+	for _, m := range r.Metrics {
+		for _, c := range columns {
+			if strings.EqualFold(c, m.Group) || strings.EqualFold(c, m.Name) || strings.EqualFold(c, m.Group+"."+m.Name) {
+				filtered.Metrics = append(filtered.Metrics, m)
+
+				break
+			}
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,61 @@
+package pprofrecgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ppwfx/pprofrec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestService(t *testing.T) {
+	svc := NewService()
+
+	rec, err := pprofrec.NewRecorder(pprofrec.WithFrequency(10*time.Millisecond), pprofrec.WithSinks(svc))
+	require.NoError(t, err)
+	rec.Start(context.Background())
+	defer rec.Stop()
+
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterMetricsServer(s, svc)
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := NewMetricsClient(conn).Watch(ctx, &WatchRequest{Columns: []string{"goroutine", "HeapAlloc"}})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		r, err := stream.Recv()
+		require.NoError(t, err)
+		assert.NotZero(t, r.TimeUnixNano)
+		require.Len(t, r.Metrics, 2)
+		assert.Equal(t, "pprof", r.Metrics[0].Group)
+		assert.Equal(t, "goroutine", r.Metrics[0].Name)
+		assert.NotZero(t, r.Metrics[0].Value)
+		assert.Equal(t, Unit_UNIT_BYTES, r.Metrics[1].Unit)
+	}
+}
+
+func TestFilterRecord(t *testing.T) {
+	r := &Record{Metrics: []*Metric{{Group: "pprof", Name: "goroutine"}, {Group: "memstats", Name: "HeapAlloc"}, {Group: "memstats", Name: "Sys"}}}
+
+	assert.Equal(t, r, filterRecord(r, nil))
+	assert.Equal(t, r.Metrics[1:], filterRecord(r, []string{"memstats"}).Metrics)
+	assert.Equal(t, r.Metrics[:2], filterRecord(r, []string{"goroutine", "memstats.heapalloc"}).Metrics)
+}
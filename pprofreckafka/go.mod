@@ -0,0 +1,23 @@
+module github.com/ppwfx/pprofrec/pprofreckafka
+
+go 1.25.0
+
+require (
+	github.com/ppwfx/pprofrec v0.0.0-20261016200028-9df3d7a0c130
+	github.com/segmentio/kafka-go v0.4.50
+	github.com/stretchr/testify v1.11.1
+)
+
+require (
+	github.com/StackExchange/wmi v0.0.0-20190523213315-cbe66965904d // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-ole/go-ole v1.2.4 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/shirou/gopsutil v3.21.9+incompatible // indirect
+	github.com/tklauser/go-sysconf v0.3.9 // indirect
+	github.com/tklauser/numcpus v0.3.0 // indirect
+	golang.org/x/sys v0.0.0-20210816074244-15123e1e1f71 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
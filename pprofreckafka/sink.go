@@ -0,0 +1,101 @@
+// Package pprofreckafka publishes the records of a pprofrec recorder to a Kafka topic,
+// for infrastructures that funnel all telemetry through Kafka.
+package pprofreckafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ppwfx/pprofrec"
+	"github.com/segmentio/kafka-go"
+)
+
+// MessageWriter publishes messages, it is implemented by *kafka.Writer.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Encoder encodes a record along with the values of its columns into the value of a message.
+type Encoder func(r pprofrec.Record, ms []pprofrec.Metric) ([]byte, error)
+
+// JSONEncoder encodes the record as JSON, as streamed by the NDJSON format of the pprofrec handlers,
+// the encoding is versioned by pprofrec.SchemaVersion.
+func JSONEncoder(r pprofrec.Record, ms []pprofrec.Metric) ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// MetricsEncoder encodes the values of the recorded columns as a flat JSON object keyed by their qualified name,
+// e.g. {"time":1700000000000000000,"memstats.HeapAlloc":1024}, the time is given in unix nanoseconds.
+// The keys follow the recorded columns and are not versioned by pprofrec.SchemaVersion.
+func MetricsEncoder(r pprofrec.Record, ms []pprofrec.Metric) ([]byte, error) {
+	m := make(map[string]interface{}, len(ms)+1)
+	m["time"] = r.Time.UnixNano()
+	for _, metric := range ms {
+		m[metric.Group+"."+metric.Name] = metric.Value
+	}
+
+	return json.Marshal(m)
+}
+
+// Sink publishes every record as a message, it is a pprofrec.Sink.
+//
+//	w := &kafka.Writer{Addr: kafka.TCP("localhost:9092"), Topic: "pprofrec"}
+//	rec, err := pprofrec.NewRecorder(pprofrec.WithSinks(pprofreckafka.Sink{Writer: w}))
+type Sink struct {
+	// Writer publishes the messages, e.g. a *kafka.Writer with the addresses of the brokers and the topic.
+	Writer MessageWriter
+	// Encoder encodes the records into the values of the messages, defaults to JSONEncoder.
+	Encoder Encoder
+	// Key keys the messages, so that the records of an instance are kept in order within a partition,
+	// defaults to <hostname>/<pid>.
+	Key string
+	// Headers are added to every message, e.g. service or region.
+	Headers map[string]string
+}
+
+// Write publishes r as a message.
+func (s Sink) Write(ctx context.Context, r pprofrec.Record, ms []pprofrec.Metric) (err error) {
+	encode := s.Encoder
+	if encode == nil {
+		encode = JSONEncoder
+	}
+
+	v, err := encode(r, ms)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	key := s.Key
+	if key == "" {
+		key = getInstanceKey()
+	}
+
+	msg := kafka.Message{Key: []byte(key), Value: v, Time: r.Time}
+
+	keys := make([]string, 0, len(s.Headers))
+	for k := range s.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(s.Headers[k])})
+	}
+
+	err = s.Writer.WriteMessages(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return
+}
+
+// getInstanceKey returns <hostname>/<pid>, the hostname is omitted if it cannot be determined.
+func getInstanceKey() string {
+	hostname, _ := os.Hostname()
+
+	return fmt.Sprintf("%v/%v", hostname, os.Getpid())
+}
@@ -0,0 +1,62 @@
+package pprofreckafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ppwfx/pprofrec"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *testWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+
+	return w.err
+}
+
+func TestSink(t *testing.T) {
+	w := &testWriter{}
+	s := Sink{Writer: w, Headers: map[string]string{"service": "api", "region": "eu"}}
+
+	r := pprofrec.Record{Time: time.Unix(1, 0), Pprof: pprofrec.PprofStat{Goroutine: 12}}
+	require.NoError(t, s.Write(context.Background(), r, nil))
+	require.Len(t, w.msgs, 1)
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, fmt.Sprintf("%v/%v", hostname, os.Getpid()), string(w.msgs[0].Key))
+	assert.Equal(t, r.Time, w.msgs[0].Time)
+	assert.Equal(t, []kafka.Header{{Key: "region", Value: []byte("eu")}, {Key: "service", Value: []byte("api")}}, w.msgs[0].Headers)
+
+	var decoded pprofrec.Record
+	require.NoError(t, json.Unmarshal(w.msgs[0].Value, &decoded))
+	assert.Equal(t, 12, decoded.Pprof.Goroutine)
+
+	s = Sink{Writer: w, Key: "api-1", Encoder: MetricsEncoder}
+	ms := []pprofrec.Metric{{Group: "pprof", Sample: pprofrec.Sample{Name: "goroutine", Value: 12}}}
+	require.NoError(t, s.Write(context.Background(), r, ms))
+	require.Len(t, w.msgs, 2)
+	assert.Equal(t, "api-1", string(w.msgs[1].Key))
+	assert.JSONEq(t, `{"time":1000000000,"pprof.goroutine":12}`, string(w.msgs[1].Value))
+}
+
+func TestSinkError(t *testing.T) {
+	s := Sink{Writer: &testWriter{err: errors.New("no brokers")}}
+	assert.EqualError(t, s.Write(context.Background(), pprofrec.Record{}, nil), "failed to write message: no brokers")
+
+	s = Sink{Writer: &testWriter{}, Encoder: func(r pprofrec.Record, ms []pprofrec.Metric) ([]byte, error) {
+		return nil, errors.New("unsupported")
+	}}
+	assert.EqualError(t, s.Write(context.Background(), pprofrec.Record{}, nil), "failed to encode record: unsupported")
+}
@@ -0,0 +1,138 @@
+// Package pprofrecmqtt publishes the records of a pprofrec recorder via MQTT in a compact binary encoding,
+// for services on edge devices where pprofrec's low footprint fits but there is no HTTP ingress.
+package pprofrecmqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ppwfx/pprofrec"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Publisher publishes a payload on a topic, it is implemented by mqtt.Client.
+type Publisher interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token
+}
+
+// Encoding is the encoding of the payloads.
+type Encoding string
+
+const (
+	// EncodingCBOR encodes the payloads as CBOR, floats are encoded in the shortest lossless precision.
+	EncodingCBOR Encoding = "cbor"
+	// EncodingMsgpack encodes the payloads as MessagePack, floats are encoded in the shortest lossless precision.
+	EncodingMsgpack Encoding = "msgpack"
+	// EncodingJSON encodes the payloads as JSON, e.g. for debugging with mosquitto_sub.
+	EncodingJSON Encoding = "json"
+)
+
+// payload is the message of a record, the short keys keep it small.
+type payload struct {
+	// Time is the time of the record in unix nanoseconds.
+	Time int64 `json:"t" cbor:"t" msgpack:"t"`
+	// Metrics holds the values of the recorded columns keyed by their qualified name, e.g. memstats.HeapAlloc.
+	Metrics map[string]float64 `json:"m" cbor:"m" msgpack:"m"`
+}
+
+// cborEncMode encodes floats in the shortest lossless precision, e.g. a goroutine count of 12 as a half-precision float.
+var cborEncMode, _ = cbor.EncOptions{ShortestFloat: cbor.ShortestFloat16, Sort: cbor.SortCanonical}.EncMode()
+
+// encode encodes p in e.
+func (e Encoding) encode(p payload) (b []byte, err error) {
+	switch e {
+	case EncodingCBOR:
+		return cborEncMode.Marshal(p)
+	case EncodingMsgpack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.UseCompactFloats(true)
+		enc.SetSortMapKeys(true)
+
+		err = enc.Encode(p)
+		if err != nil {
+			return
+		}
+
+		return buf.Bytes(), nil
+	case EncodingJSON:
+		return json.Marshal(p)
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %v", e)
+	}
+}
+
+// Sink publishes the values of the recorded columns of every record as a message, it is a pprofrec.Sink.
+//
+//	client := mqtt.NewClient(mqtt.NewClientOptions().AddBroker("tcp://localhost:1883"))
+//	rec, err := pprofrec.NewRecorder(pprofrec.WithSinks(pprofrecmqtt.Sink{Client: client}))
+type Sink struct {
+	// Client publishes the messages, e.g. a connected mqtt.Client.
+	Client Publisher
+	// Topic is the topic the records are published on, defaults to pprofrec/<hostname>/<pid>.
+	Topic string
+	// QoS is the quality of service of the messages, 0, 1 or 2, defaults to 0 as a lost record is superseded by the next.
+	QoS byte
+	// Retained retains the latest record on the broker, so that subscribers receive it when they subscribe.
+	Retained bool
+	// Encoding encodes the payloads, defaults to EncodingCBOR.
+	Encoding Encoding
+}
+
+// Write publishes r and waits until the message is delivered according to its QoS or ctx is done.
+func (s Sink) Write(ctx context.Context, r pprofrec.Record, ms []pprofrec.Metric) (err error) {
+	encoding := s.Encoding
+	if encoding == "" {
+		encoding = EncodingCBOR
+	}
+
+	p := payload{Time: r.Time.UnixNano(), Metrics: make(map[string]float64, len(ms))}
+	for _, m := range ms {
+		p.Metrics[m.Group+"."+m.Name] = m.Value
+	}
+
+	b, err := encoding.encode(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	topic := s.getTopic()
+
+	t := s.Client.Publish(topic, s.QoS, s.Retained, b)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.Done():
+	}
+
+	err = t.Error()
+	if err != nil {
+		return fmt.Errorf("failed to publish record on %v: %w", topic, err)
+	}
+
+	return
+}
+
+// topicLevelEscaper replaces the characters that separate or wildcard the levels of a topic.
+var topicLevelEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+// getTopic returns the topic of s, pprofrec/<hostname>/<pid> if not set.
+func (s Sink) getTopic() string {
+	if s.Topic != "" {
+		return s.Topic
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("pprofrec/%v/%v", topicLevelEscaper.Replace(hostname), os.Getpid())
+}
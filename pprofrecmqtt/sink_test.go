@@ -0,0 +1,122 @@
+package pprofrecmqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ppwfx/pprofrec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var _ Publisher = mqtt.Client(nil)
+
+type testToken struct {
+	done chan struct{}
+	err  error
+}
+
+func (t testToken) Wait() bool {
+	<-t.done
+
+	return true
+}
+
+func (t testToken) WaitTimeout(d time.Duration) bool {
+	select {
+	case <-t.done:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+func (t testToken) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t testToken) Error() error {
+	return t.err
+}
+
+type testMessage struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+type testPublisher struct {
+	msgs    []testMessage
+	err     error
+	pending bool
+}
+
+func (p *testPublisher) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	p.msgs = append(p.msgs, testMessage{topic: topic, qos: qos, retained: retained, payload: payload.([]byte)})
+
+	t := testToken{done: make(chan struct{}), err: p.err}
+	if !p.pending {
+		close(t.done)
+	}
+
+	return t
+}
+
+func TestSink(t *testing.T) {
+	r := pprofrec.Record{Time: time.Unix(1, 0)}
+	ms := []pprofrec.Metric{
+		{Group: "pprof", Sample: pprofrec.Sample{Name: "goroutine", Value: 12}},
+		{Group: "memstats", Sample: pprofrec.Sample{Name: "HeapAlloc", Value: 1.5e6}},
+	}
+	expected := payload{Time: 1e9, Metrics: map[string]float64{"pprof.goroutine": 12, "memstats.HeapAlloc": 1.5e6}}
+
+	p := &testPublisher{}
+	require.NoError(t, Sink{Client: p, QoS: 1, Retained: true}.Write(context.Background(), r, ms))
+	require.NoError(t, Sink{Client: p, Topic: "devices/1", Encoding: EncodingMsgpack}.Write(context.Background(), r, ms))
+	require.NoError(t, Sink{Client: p, Topic: "devices/1", Encoding: EncodingJSON}.Write(context.Background(), r, ms))
+	require.Len(t, p.msgs, 3)
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, fmt.Sprintf("pprofrec/%v/%v", hostname, os.Getpid()), p.msgs[0].topic)
+	assert.Equal(t, byte(1), p.msgs[0].qos)
+	assert.True(t, p.msgs[0].retained)
+	assert.Equal(t, "devices/1", p.msgs[1].topic)
+
+	var decoded payload
+	require.NoError(t, cbor.Unmarshal(p.msgs[0].payload, &decoded))
+	assert.Equal(t, expected, decoded)
+
+	decoded = payload{}
+	require.NoError(t, msgpack.Unmarshal(p.msgs[1].payload, &decoded))
+	assert.Equal(t, expected, decoded)
+
+	decoded = payload{}
+	require.NoError(t, json.Unmarshal(p.msgs[2].payload, &decoded))
+	assert.Equal(t, expected, decoded)
+
+	assert.True(t, len(p.msgs[0].payload) < len(p.msgs[2].payload))
+	assert.True(t, len(p.msgs[1].payload) < len(p.msgs[2].payload))
+}
+
+func TestSinkError(t *testing.T) {
+	s := Sink{Client: &testPublisher{err: errors.New("not connected")}, Topic: "devices/1"}
+	assert.EqualError(t, s.Write(context.Background(), pprofrec.Record{}, nil), "failed to publish record on devices/1: not connected")
+
+	s = Sink{Client: &testPublisher{}, Encoding: "xml"}
+	assert.EqualError(t, s.Write(context.Background(), pprofrec.Record{}, nil), "failed to encode record: unsupported encoding: xml")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s = Sink{Client: &testPublisher{pending: true}, QoS: 2}
+	assert.Equal(t, context.Canceled, s.Write(ctx, pprofrec.Record{}, nil))
+}
@@ -0,0 +1,72 @@
+// Package pprofrecnats publishes the records of a pprofrec recorder on a NATS subject per instance,
+// so that internal tooling subscribes to the runtime metrics of a fleet without scraping HTTP endpoints.
+package pprofrecnats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ppwfx/pprofrec"
+)
+
+// Publisher publishes data on a subject, it is implemented by *nats.Conn.
+type Publisher interface {
+	Publish(subj string, data []byte) error
+}
+
+// Sink publishes every record encoded as JSON, it is a pprofrec.Sink.
+// The encoding is versioned by pprofrec.SchemaVersion.
+// Subscribers receive the records of all instances via the wildcard pprofrec.>.
+//
+//	nc, err := nats.Connect(nats.DefaultURL)
+//	rec, err := pprofrec.NewRecorder(pprofrec.WithSinks(pprofrecnats.Sink{Conn: nc}))
+type Sink struct {
+	// Conn publishes the records, e.g. a *nats.Conn.
+	Conn Publisher
+	// Subject is the subject the records are published on, defaults to <Prefix>.<hostname>.<pid>.
+	Subject string
+	// Prefix prefixes the default subject, defaults to pprofrec.
+	Prefix string
+}
+
+// Write publishes r on the subject of the instance.
+func (s Sink) Write(ctx context.Context, r pprofrec.Record, ms []pprofrec.Metric) (err error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	subject := s.getSubject()
+
+	err = s.Conn.Publish(subject, b)
+	if err != nil {
+		return fmt.Errorf("failed to publish record on %v: %w", subject, err)
+	}
+
+	return
+}
+
+// subjectTokenEscaper replaces the characters that separate or wildcard the tokens of a subject.
+var subjectTokenEscaper = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_")
+
+// getSubject returns the subject of s, <prefix>.<hostname>.<pid> if not set.
+func (s Sink) getSubject() string {
+	if s.Subject != "" {
+		return s.Subject
+	}
+
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "pprofrec"
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%v.%v.%v", prefix, subjectTokenEscaper.Replace(hostname), os.Getpid())
+}
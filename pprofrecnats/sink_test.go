@@ -0,0 +1,58 @@
+package pprofrecnats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/ppwfx/pprofrec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Publisher = (*nats.Conn)(nil)
+
+type testPublisher struct {
+	subjects []string
+	data     [][]byte
+	err      error
+}
+
+func (p *testPublisher) Publish(subj string, data []byte) error {
+	p.subjects = append(p.subjects, subj)
+	p.data = append(p.data, data)
+
+	return p.err
+}
+
+func TestSink(t *testing.T) {
+	p := &testPublisher{}
+
+	r := pprofrec.Record{Time: time.Unix(1, 0), Pprof: pprofrec.PprofStat{Goroutine: 12}}
+	require.NoError(t, Sink{Conn: p}.Write(context.Background(), r, nil))
+	require.NoError(t, Sink{Conn: p, Prefix: "runtime"}.Write(context.Background(), r, nil))
+	require.NoError(t, Sink{Conn: p, Subject: "api.1"}.Write(context.Background(), r, nil))
+
+	hostname, _ := os.Hostname()
+	hostname = strings.Replace(hostname, ".", "_", -1)
+	assert.Equal(t, []string{
+		fmt.Sprintf("pprofrec.%v.%v", hostname, os.Getpid()),
+		fmt.Sprintf("runtime.%v.%v", hostname, os.Getpid()),
+		"api.1",
+	}, p.subjects)
+
+	var decoded pprofrec.Record
+	require.NoError(t, json.Unmarshal(p.data[0], &decoded))
+	assert.Equal(t, 12, decoded.Pprof.Goroutine)
+}
+
+func TestSinkError(t *testing.T) {
+	s := Sink{Conn: &testPublisher{err: errors.New("connection closed")}, Subject: "api.1"}
+	assert.EqualError(t, s.Write(context.Background(), pprofrec.Record{}, nil), "failed to publish record on api.1: connection closed")
+}
@@ -0,0 +1,87 @@
+package pprofrec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pressureResources are the resources that pressure stall information is reported for.
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// PressureCollector collects the pressure stall information (PSI) of the cgroup of the process, or of the host
+// if the cgroup does not report it, as PSI is the earliest indicator of resource starvation in containers.
+// The metrics are recorded per resource as the share of the time in percent over the last 10 and 60 seconds
+// that some tasks, or all non-idle tasks for full, were stalled waiting on cpu, memory and io.
+// PSI requires cgroup v2 for containers and Linux 4.20, all metrics are zero if it is unavailable.
+type PressureCollector struct{}
+
+// Name returns pressure.
+func (c PressureCollector) Name() string {
+	return "pressure"
+}
+
+// Collect returns the current pressure stall information.
+func (c PressureCollector) Collect(ctx context.Context) []Sample {
+	ps := readPressure("/sys/fs/cgroup", "/proc/self/cgroup", "/proc/pressure")
+
+	ss := make([]Sample, 0, 4*len(pressureResources))
+	for _, r := range pressureResources {
+		for _, name := range []string{"some_avg10", "some_avg60", "full_avg10", "full_avg60"} {
+			ss = append(ss, Sample{Name: r + "_" + name, Value: ps[r+"_"+name], Unit: UnitPercent})
+		}
+	}
+
+	return ss
+}
+
+// readPressure reads the pressure stall information of the cgroup that procCgroup, e.g. /proc/self/cgroup,
+// refers to from the cgroup v2 filesystem mounted at root, or of the host from procPressure, e.g. /proc/pressure,
+// if the cgroup does not report it. The values are keyed by resource, line and average, e.g. memory_full_avg10.
+func readPressure(root string, procCgroup string, procPressure string) map[string]float64 {
+	dir := procPressure
+	suffix := ""
+
+	// cgroup v2 reports the pressure of the cgroup in cpu.pressure, memory.pressure and io.pressure
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	if err == nil {
+		cgroupDir := getCgroupDir(root, readCgroupPaths(procCgroup)[""])
+
+		_, err = os.Stat(filepath.Join(cgroupDir, "memory.pressure"))
+		if err == nil {
+			dir = cgroupDir
+			suffix = ".pressure"
+		}
+	}
+
+	ps := map[string]float64{}
+	for _, r := range pressureResources {
+		for k, v := range parsePressure(readCgroupFile(dir, r+suffix)) {
+			ps[r+"_"+k] = v
+		}
+	}
+
+	return ps
+}
+
+// parsePressure parses the lines of a PSI file, formatted as some avg10=0.00 avg60=0.00 avg300=0.00 total=0,
+// keyed by line and field, e.g. some_avg10.
+func parsePressure(s string) map[string]float64 {
+	p := map[string]float64{}
+	for _, l := range strings.Split(s, "\n") {
+		fs := strings.Fields(l)
+		if len(fs) == 0 {
+			continue
+		}
+
+		for _, f := range fs[1:] {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) == 2 {
+				p[fs[0]+"_"+kv[0]] = parseCgroupValue(kv[1])
+			}
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,61 @@
+package pprofrec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePressure(t *testing.T) {
+	assert.Equal(t, map[string]float64{
+		"some_avg10":  1.5,
+		"some_avg60":  0.75,
+		"some_avg300": 0.25,
+		"some_total":  1200,
+		"full_avg10":  0.5,
+		"full_avg60":  0,
+		"full_avg300": 0,
+		"full_total":  300,
+	}, parsePressure("some avg10=1.50 avg60=0.75 avg300=0.25 total=1200\nfull avg10=0.50 avg60=0.00 avg300=0.00 total=300\n"))
+
+	assert.Empty(t, parsePressure(""))
+}
+
+func TestReadPressure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeCgroupFiles(t, dir, map[string]string{
+		"proc":                   "0::/app\n",
+		"fs/cgroup.controllers":  "cpu memory io\n",
+		"fs/app/cpu.pressure":    "some avg10=10.00 avg60=5.00 avg300=1.00 total=100\nfull avg10=2.00 avg60=1.00 avg300=0.00 total=20\n",
+		"fs/app/memory.pressure": "some avg10=3.00 avg60=2.00 avg300=1.00 total=100\nfull avg10=1.00 avg60=0.50 avg300=0.00 total=20\n",
+		"host/cpu":               "some avg10=20.00 avg60=15.00 avg300=10.00 total=1000\n",
+		"host/memory":            "some avg10=4.00 avg60=3.00 avg300=2.00 total=1000\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		"host/io":                "some avg10=6.00 avg60=5.00 avg300=4.00 total=1000\nfull avg10=1.00 avg60=1.00 avg300=1.00 total=10\n",
+	})
+
+	ps := readPressure(filepath.Join(dir, "fs"), filepath.Join(dir, "proc"), filepath.Join(dir, "host"))
+	assert.Equal(t, 10.0, ps["cpu_some_avg10"])
+	assert.Equal(t, 0.5, ps["memory_full_avg60"])
+	assert.Zero(t, ps["io_some_avg10"])
+
+	// the host pressure is read outside of cgroup v2
+	ps = readPressure(filepath.Join(dir, "v1"), filepath.Join(dir, "proc"), filepath.Join(dir, "host"))
+	assert.Equal(t, 20.0, ps["cpu_some_avg10"])
+	assert.Zero(t, ps["cpu_full_avg10"])
+	assert.Equal(t, 1.0, ps["io_full_avg60"])
+}
+
+func TestPressureCollector(t *testing.T) {
+	ss := PressureCollector{}.Collect(context.Background())
+	require.Len(t, ss, 12)
+	assert.Equal(t, "cpu_some_avg10", ss[0].Name)
+	assert.Equal(t, UnitPercent, ss[0].Unit)
+}
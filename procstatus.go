@@ -0,0 +1,72 @@
+package pprofrec
+
+import (
+	"context"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ProcStatusCollector collects the metrics of /proc/self/status that the portable API of gopsutil leaves out,
+// the peak virtual memory size and peak RSS as reported by the kernel, the number of voluntary and
+// involuntary context switches and the number of threads. All metrics are zero outside of Linux.
+type ProcStatusCollector struct{}
+
+// Name returns procstatus.
+func (c ProcStatusCollector) Name() string {
+	return "procstatus"
+}
+
+// Collect returns the current values of /proc/self/status.
+func (c ProcStatusCollector) Collect(ctx context.Context) []Sample {
+	s := readProcStatus("/proc/self/status")
+
+	return []Sample{
+		{Name: "VmPeak", Value: s["VmPeak"], Unit: UnitBytes},
+		{Name: "VmHWM", Value: s["VmHWM"], Unit: UnitBytes},
+		{Name: "voluntary_ctxt_switches", Value: s["voluntary_ctxt_switches"]},
+		{Name: "nonvoluntary_ctxt_switches", Value: s["nonvoluntary_ctxt_switches"]},
+		{Name: "Threads", Value: s["Threads"]},
+	}
+}
+
+// readProcStatus parses the numeric fields of a status file, e.g. /proc/self/status or /proc/self/smaps_rollup,
+// sizes given in kB are converted to bytes. It returns an empty map if the file cannot be read.
+func readProcStatus(path string) map[string]float64 {
+	s := map[string]float64{}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return s
+	}
+
+	for _, l := range strings.Split(string(b), "\n") {
+		// lines are formatted as key:\tvalue with an optional kB unit
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		fs := strings.Fields(kv[1])
+		if len(fs) == 0 || len(fs) > 2 {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(fs[0], 64)
+		if err != nil {
+			continue
+		}
+
+		if len(fs) == 2 {
+			if fs[1] != "kB" {
+				continue
+			}
+
+			v *= 1024
+		}
+
+		s[kv[0]] = v
+	}
+
+	return s
+}
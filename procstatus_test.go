@@ -0,0 +1,47 @@
+package pprofrec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadProcStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeCgroupFiles(t, dir, map[string]string{
+		"status": "Name:\tapp\nState:\tS (sleeping)\nVmPeak:\t  2048 kB\nVmHWM:\t    1024 kB\nThreads:\t12\n" +
+			"Cpus_allowed_list:\t0-3\nvoluntary_ctxt_switches:\t150\nnonvoluntary_ctxt_switches:\t7\n",
+	})
+
+	assert.Equal(t, map[string]float64{
+		"VmPeak":                     2048 * 1024,
+		"VmHWM":                      1024 * 1024,
+		"Threads":                    12,
+		"voluntary_ctxt_switches":    150,
+		"nonvoluntary_ctxt_switches": 7,
+	}, readProcStatus(filepath.Join(dir, "status")))
+
+	assert.Empty(t, readProcStatus(filepath.Join(dir, "missing")))
+}
+
+func TestProcStatusCollector(t *testing.T) {
+	ss := ProcStatusCollector{}.Collect(context.Background())
+	require.Len(t, ss, 5)
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	assert.NotZero(t, ss[0].Value)
+	assert.NotZero(t, ss[1].Value)
+	assert.NotZero(t, ss[4].Value)
+}
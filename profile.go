@@ -0,0 +1,428 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProfileOpts configures capturing profiles when metrics spike.
+type ProfileOpts struct {
+	// Triggers capture a profile whenever their rule fires.
+	Triggers []ProfileTrigger
+	// Max bounds the number of stored profiles, the oldest profile is dropped, defaults to 10.
+	Max int
+	// Dir is a directory that captured profiles are written to in addition if set.
+	Dir string
+	// HeapInterval captures a heap profile every interval into a ring of its own, the profiles are served for download
+	// and diffing by HeapProfiles to turn a spike into the allocations behind it. It is disabled if zero.
+	HeapInterval time.Duration
+	// HeapMax bounds the number of periodic heap profiles, the oldest profile is dropped, defaults to 12.
+	HeapMax int
+	// CPUInterval captures a CPU profile of CPUDuration every interval, e.g. 10 seconds every 5 minutes, to tell what
+	// the CPU was doing at a given time. The profiles are kept for the window and linked from the rows they overlap,
+	// they are neither bounded by Max nor written to Dir. It is disabled if zero.
+	CPUInterval time.Duration
+	// CPUDuration is how long a periodic CPU profile is captured, it has to be shorter than CPUInterval, defaults to 10 seconds.
+	CPUDuration time.Duration
+	// MaxCaptureDuration bounds the ?duration= of on-demand captures via the Capture handler, longer captures are
+	// rejected so that a request can't keep the CPU profiler or the tracer busy indefinitely, defaults to 1 minute.
+	MaxCaptureDuration time.Duration
+	// Exporters receive every captured profile, including the periodic heap and CPU profiles,
+	// e.g. a PyroscopeExporter to push them to a continuous-profiling server.
+	Exporters []ProfileExporter
+}
+
+// ProfileTrigger captures a pprof profile when its rule fires,
+// e.g. a heap profile when HeapAlloc or RSS grows past a threshold or rate,
+// or a goroutine dump with Debug 2 when the goroutine count keeps increasing.
+type ProfileTrigger struct {
+	// Rule defines when to capture the profile.
+	Rule AlertRule
+	// Profile names the pprof profile to capture, defaults to heap.
+	// cpu captures a CPU profile for Duration, e.g. when the CPU column of the rates group stays above a threshold,
+	// and trace captures a runtime/trace execution trace for Duration.
+	Profile string
+	// Debug is passed to pprof.Profile.WriteTo, 0 captures the gzipped protobuf format.
+	// It is ignored by CPU profiles and traces, which are always captured in their binary format.
+	Debug int
+	// Duration is how long a CPU profile or trace is captured, defaults to 10 seconds.
+	Duration time.Duration
+	// MaxBytes bounds the size of a trace, the trace is stopped early once it exceeds MaxBytes, defaults to 64 MiB.
+	MaxBytes int
+}
+
+const (
+	// cpuProfile names the CPU profile, which is not a pprof.Profile as it is captured over a duration.
+	cpuProfile = "cpu"
+	// traceProfile names the execution trace, which is captured over a duration like the CPU profile.
+	traceProfile = "trace"
+	// goroutineProfile names the goroutine profile, which is broken down by label and top function.
+	goroutineProfile = "goroutine"
+)
+
+// Profile is a profile that has been captured by a trigger.
+type Profile struct {
+	ID int `json:"id"`
+	// Name names the pprof profile.
+	Name string `json:"name"`
+	// Rule names the rule that triggered the capture.
+	Rule string `json:"rule"`
+	// Time is the time of the record that triggered the capture.
+	Time time.Time `json:"time"`
+	// Duration is how long a CPU profile or trace has been captured for.
+	Duration time.Duration `json:"duration,omitempty"`
+	Debug    int           `json:"debug"`
+	Data     []byte        `json:"-"`
+	// heap holds the samples of periodic heap profiles, which they are diffed by.
+	heap []heapSample
+	// Breakdown counts the goroutines of goroutine profiles by label and top function.
+	Breakdown *GoroutineBreakdown `json:"breakdown,omitempty"`
+}
+
+// filename returns a filename for downloading the profile.
+func (p Profile) filename() string {
+	ext := "pb.gz"
+	switch {
+	case p.Name == traceProfile:
+		ext = "trace"
+	case p.Debug > 0:
+		ext = "txt"
+	}
+
+	return fmt.Sprintf("%v-%v-%v.%v", p.Name, p.Time.Format("20060102T150405"), p.ID, ext)
+}
+
+// profileStore stores a bounded number of profiles, it is safe for concurrent use.
+type profileStore struct {
+	mu  sync.RWMutex
+	max int
+	// ids is the last assigned id, it is shared by the stores of a recorder so that their ids are unique across them.
+	ids *int64
+	ps  []Profile
+}
+
+func newProfileStore(max int) *profileStore {
+	if max == 0 {
+		max = 10
+	}
+
+	return &profileStore{max: max, ids: new(int64)}
+}
+
+// share returns a store bounded by max that assigns ids from the same sequence as s.
+func (s *profileStore) share(max int) *profileStore {
+	return &profileStore{max: max, ids: s.ids}
+}
+
+// add stores p and drops the oldest profile if the store is full.
+func (s *profileStore) add(p Profile) Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p.ID = int(atomic.AddInt64(s.ids, 1))
+
+	s.ps = append(s.ps, p)
+	if len(s.ps) > s.max {
+		s.ps = append(s.ps[:0], s.ps[len(s.ps)-s.max:]...)
+	}
+
+	return p
+}
+
+// list returns the stored profiles without their data, ordered from oldest to latest.
+func (s *profileStore) list() []Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ps := make([]Profile, len(s.ps))
+	for i, p := range s.ps {
+		p.Data = nil
+		ps[i] = p
+	}
+
+	return ps
+}
+
+// get returns the profile with the given id.
+func (s *profileStore) get(id int) (Profile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.ps {
+		if p.ID == id {
+			return p, true
+		}
+	}
+
+	return Profile{}, false
+}
+
+// profileTrigger evaluates the rule of a trigger.
+type profileTrigger struct {
+	t ProfileTrigger
+	a *alerter
+}
+
+func newProfileTriggers(onError func(error), triggers []ProfileTrigger, cols []column) (pts []profileTrigger) {
+	for _, t := range triggers {
+		t, err := t.withDefaults()
+		if err != nil {
+			reportError(onError, fmt.Errorf("skipping profile trigger %v: %w", t.Rule.Name, err))
+
+			continue
+		}
+
+		pts = append(pts, profileTrigger{t: t, a: newAlerter(onError, []AlertRule{t.Rule}, cols)})
+	}
+
+	return
+}
+
+// withDefaults returns t with its defaults applied, it fails if t names an unknown profile.
+func (t ProfileTrigger) withDefaults() (ProfileTrigger, error) {
+	if t.Profile == "" {
+		t.Profile = "heap"
+	}
+
+	if t.Duration == time.Duration(0) {
+		t.Duration = 10 * time.Second
+	}
+
+	if t.MaxBytes == 0 {
+		t.MaxBytes = 64 << 20
+	}
+
+	if t.Profile != cpuProfile && t.Profile != traceProfile && pprof.Lookup(t.Profile) == nil {
+		return t, fmt.Errorf("unknown profile: %v", t.Profile)
+	}
+
+	return t, nil
+}
+
+// fired reports whether the rule of the trigger fired with the current record.
+func (pt profileTrigger) fired(previous Record, current Record) bool {
+	for _, a := range pt.a.evaluate(previous, current) {
+		if a.Status == AlertFiring {
+			return true
+		}
+	}
+
+	return false
+}
+
+// captureProfile captures the pprof profile of the trigger,
+// CPU profiles and traces are captured for the duration of the trigger or until ctx is done.
+func captureProfile(ctx context.Context, t ProfileTrigger, ts time.Time) (p Profile, err error) {
+	var buf bytes.Buffer
+	var d time.Duration
+	switch t.Profile {
+	case cpuProfile:
+		t.Debug = 0
+
+		// fails if a CPU profile is already being captured, e.g. via net/http/pprof
+		err = pprof.StartCPUProfile(&buf)
+		if err != nil {
+			return
+		}
+
+		start := time.Now()
+		waitCapture(ctx, t.Duration, nil)
+
+		pprof.StopCPUProfile()
+		d = time.Since(start)
+	case traceProfile:
+		t.Debug = 0
+
+		lw := &limitedWriter{w: &buf, max: t.MaxBytes, exceeded: make(chan struct{})}
+
+		// fails if a trace is already being captured, e.g. via net/http/pprof
+		err = trace.Start(lw)
+		if err != nil {
+			return
+		}
+
+		start := time.Now()
+		waitCapture(ctx, t.Duration, lw.exceeded)
+
+		trace.Stop()
+		d = time.Since(start)
+	default:
+		err = pprof.Lookup(t.Profile).WriteTo(&buf, t.Debug)
+		if err != nil {
+			return
+		}
+	}
+
+	p = Profile{
+		Name:     t.Profile,
+		Rule:     t.Rule.Name,
+		Time:     ts,
+		Duration: d,
+		Debug:    t.Debug,
+		Data:     buf.Bytes(),
+	}
+
+	if t.Profile == goroutineProfile {
+		p.Breakdown, err = captureGoroutineBreakdown()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// waitCapture blocks for d or until ctx is done or stop is closed.
+func waitCapture(ctx context.Context, d time.Duration, stop <-chan struct{}) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-stop:
+	case <-timer.C:
+	}
+}
+
+// limitedWriter writes to w and closes exceeded once more than max bytes have been written.
+// Writes are not truncated, so that a trace stays readable when it is stopped after exceeding max.
+type limitedWriter struct {
+	w        io.Writer
+	max      int
+	n        int
+	exceeded chan struct{}
+}
+
+func (lw *limitedWriter) Write(b []byte) (n int, err error) {
+	n, err = lw.w.Write(b)
+
+	exceeded := lw.n > lw.max
+	lw.n += n
+	if !exceeded && lw.n > lw.max {
+		close(lw.exceeded)
+	}
+
+	return
+}
+
+// getQueryProfile parses the profile query parameter, ok is false if it is not set.
+func getQueryProfile(q string) (id int, ok bool, err error) {
+	if q == "" {
+		return
+	}
+
+	id, err = strconv.Atoi(q)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid profile: %v", err.Error())
+	}
+
+	return id, true, nil
+}
+
+// getProfileLinks returns the links to the profiles that were triggered after after and until until,
+// or that have been captured over a duration that overlaps it, e.g. periodic CPU profiles.
+// Goroutine profiles are linked to their breakdown in addition.
+func getProfileLinks(ps []Profile, after time.Time, until time.Time) (links []link) {
+	for _, p := range ps {
+		if p.Time.Add(p.Duration).After(after) && !p.Time.After(until) {
+			links = append(links, link{href: "?profile=" + strconv.Itoa(p.ID), label: p.Name})
+
+			if p.Breakdown != nil {
+				links = append(links, link{href: "?profile=" + strconv.Itoa(p.ID) + "&amp;breakdown=true", label: "breakdown"})
+			}
+		}
+	}
+
+	return
+}
+
+// writeProfile responds with the profile with the given id as an attachment.
+func writeProfile(w http.ResponseWriter, r *http.Request, rec *Recorder, id int) {
+	p, ok := rec.Profile(id)
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	writeProfileData(w, p, rec.opts.OnError)
+}
+
+// writeProfileData responds with the data of p as an attachment.
+func writeProfileData(w http.ResponseWriter, p Profile, onError func(error)) {
+	if p.Debug > 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v"`, p.filename()))
+
+	_, err := w.Write(p.Data)
+	if err != nil {
+		reportError(onError, fmt.Errorf("failed to write to response writer: %w", err))
+	}
+}
+
+// Capture captures the profile given by ?profile=, e.g. trace or cpu, on demand for ?duration=, defaults to 10 seconds,
+// and responds with the stored Profile as JSON, which is downloaded from the Window handler via ?profile=<id>.
+// Captures are only accepted via POST and for at most ProfileOpts.MaxCaptureDuration.
+func Capture(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		d, err := getQueryDuration(r, "duration", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		t, err := ProfileTrigger{Rule: AlertRule{Name: "capture"}, Profile: r.FormValue("profile"), Duration: d}.withDefaults()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		max := rec.opts.Profiles.MaxCaptureDuration
+		if max > 0 && t.Duration > max {
+			http.Error(w, fmt.Sprintf("invalid duration: must not exceed %v", max), http.StatusBadRequest)
+
+			return
+		}
+
+		p, err := rec.Capture(r.Context(), t)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to capture %v profile: %v", t.Profile, err), http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		p.Data = nil
+
+		err = json.NewEncoder(w).Encode(p)
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileStore(t *testing.T) {
+	s := newProfileStore(2)
+
+	for i := 0; i < 3; i++ {
+		p := s.add(Profile{Name: "heap", Data: []byte{byte(i)}})
+		assert.Equal(t, i+1, p.ID)
+	}
+
+	ps := s.list()
+	require.Len(t, ps, 2)
+	assert.Equal(t, 2, ps[0].ID)
+	assert.Nil(t, ps[0].Data)
+
+	_, ok := s.get(1)
+	assert.False(t, ok)
+
+	p, ok := s.get(3)
+	require.True(t, ok)
+	assert.Equal(t, []byte{2}, p.Data)
+
+	shared := s.share(1)
+	assert.Equal(t, 4, shared.add(Profile{Name: "cpu"}).ID)
+	assert.Equal(t, 5, s.add(Profile{Name: "heap"}).ID)
+}
+
+func TestGetProfileLinks(t *testing.T) {
+	now := time.Now()
+	ps := []Profile{
+		{ID: 1, Name: "heap", Time: now},
+		{ID: 2, Name: "cpu", Time: now, Duration: 3 * time.Second},
+	}
+
+	assert.Equal(t, []link{{href: "?profile=1", label: "heap"}, {href: "?profile=2", label: "cpu"}},
+		getProfileLinks(ps, now.Add(-time.Second), now))
+	assert.Equal(t, []link{{href: "?profile=2", label: "cpu"}}, getProfileLinks(ps, now.Add(time.Second), now.Add(2*time.Second)))
+	assert.Empty(t, getProfileLinks(ps, now.Add(3*time.Second), now.Add(4*time.Second)))
+}
+
+func TestRecorderPeriodicCPUProfiles(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{
+		Window:    time.Second,
+		Frequency: 20 * time.Millisecond,
+		Profiles: ProfileOpts{
+			CPUInterval: 100 * time.Millisecond,
+			CPUDuration: 50 * time.Millisecond,
+		},
+	})
+	rec.Start(ctx)
+
+	time.Sleep(350 * time.Millisecond)
+	rec.Stop()
+
+	ps := rec.Profiles()
+	require.NotEmpty(t, ps)
+	assert.Equal(t, "cpu", ps[0].Name)
+	assert.Equal(t, "periodic", ps[0].Rule)
+	assert.True(t, ps[0].Duration >= 50*time.Millisecond)
+
+	p, ok := rec.Profile(ps[0].ID)
+	require.True(t, ok)
+	assert.NotEmpty(t, p.Data)
+
+	f := Window(ctx, WindowOpts{Recorder: rec})
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	w := httptest.NewRecorder()
+	f(w, r)
+	// the profile overlaps the rows recorded during its capture
+	assert.True(t, strings.Count(w.Body.String(), fmt.Sprintf(`<a href="?profile=%v">cpu</a>`, ps[0].ID)) > 1)
+}
+
+func TestWindowProfileTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := Window(ctx, WindowOpts{
+		Window:    time.Second,
+		Frequency: 50 * time.Millisecond,
+		Profiles: ProfileOpts{
+			Triggers: []ProfileTrigger{{Rule: AlertRule{Name: "heap", Column: "HeapAlloc", Op: ">", Threshold: 0}}},
+		},
+	})
+
+	time.Sleep(300 * time.Millisecond)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	w := httptest.NewRecorder()
+	f(w, r)
+	assert.Contains(t, w.Body.String(), `<a href="?profile=1">heap</a>`)
+
+	r = httptest.NewRequest(http.MethodGet, "http://localhost:8080?profile=1", nil)
+	w = httptest.NewRecorder()
+	f(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment; filename=\"heap-")
+	assert.NotEmpty(t, w.Body.Bytes())
+
+	r = httptest.NewRequest(http.MethodGet, "http://localhost:8080?profile=2", nil)
+	w = httptest.NewRecorder()
+	f(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRecorderGoroutineDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rec := newTestRecorder(t, RecorderOpts{
+		Frequency: 50 * time.Millisecond,
+		Profiles: ProfileOpts{
+			Triggers: []ProfileTrigger{{Rule: AlertRule{Name: "goroutines", Column: "goroutine", Op: ">", Threshold: 0}, Profile: "goroutine", Debug: 2}},
+			Dir:      dir,
+		},
+	})
+	rec.Start(context.Background())
+	time.Sleep(200 * time.Millisecond)
+	rec.Stop()
+
+	ps := rec.Profiles()
+	require.Len(t, ps, 1)
+	assert.Equal(t, "goroutine", ps[0].Name)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, ps[0].filename()))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "goroutine 1 [")
+}
+
+func TestCaptureCPUProfile(t *testing.T) {
+	p, err := captureProfile(context.Background(), ProfileTrigger{Profile: "cpu", Debug: 1, Duration: 50 * time.Millisecond, Rule: AlertRule{Name: "cpu"}}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "cpu", p.Name)
+	assert.Equal(t, 0, p.Debug)
+	require.NotEmpty(t, p.Data)
+	// gzipped protobuf
+	assert.Equal(t, []byte{0x1f, 0x8b}, p.Data[:2])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err = captureProfile(ctx, ProfileTrigger{Profile: "cpu", Duration: time.Hour}, time.Now())
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestNewProfileTriggers(t *testing.T) {
+	var errs []error
+	pts := newProfileTriggers(func(err error) { errs = append(errs, err) }, []ProfileTrigger{{Profile: "cpu"}, {Profile: "unknown"}, {}}, getColumns(capabilities{}))
+	require.Len(t, pts, 2)
+	assert.NotEmpty(t, errs)
+	assert.Equal(t, "cpu", pts[0].t.Profile)
+	assert.Equal(t, 10*time.Second, pts[0].t.Duration)
+	assert.Equal(t, "heap", pts[1].t.Profile)
+}
+
+func TestCaptureTrace(t *testing.T) {
+	p, err := captureProfile(context.Background(), ProfileTrigger{Profile: "trace", Duration: 50 * time.Millisecond, MaxBytes: 64 << 20}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "trace", p.Name)
+	assert.True(t, strings.HasPrefix(string(p.Data), "go 1."))
+	assert.True(t, strings.HasSuffix(p.filename(), ".trace"))
+
+	start := time.Now()
+	p, err = captureProfile(context.Background(), ProfileTrigger{Profile: "trace", Duration: time.Hour, MaxBytes: 1}, time.Now())
+	require.NoError(t, err)
+	assert.NotEmpty(t, p.Data)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestCapture(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{})
+
+	w := httptest.NewRecorder()
+	Capture(rec)(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/capture?profile=trace&duration=50ms", http.NoBody))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var p Profile
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, "trace", p.Name)
+
+	stored, ok := rec.Profile(p.ID)
+	require.True(t, ok)
+	assert.NotEmpty(t, stored.Data)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/debug/pprof/capture?profile=trace", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/debug/pprof/capture?profile=unknown", http.StatusBadRequest},
+		{http.MethodPost, "/debug/pprof/capture?profile=trace&duration=x", http.StatusBadRequest},
+		{http.MethodPost, "/debug/pprof/capture?profile=cpu&duration=2m", http.StatusBadRequest},
+		{http.MethodPost, "/debug/pprof/capture?profile=trace&duration=8760h", http.StatusBadRequest},
+	} {
+		w = httptest.NewRecorder()
+		Capture(rec)(w, httptest.NewRequest(tc.method, tc.path, http.NoBody))
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+}
+
+func TestCaptureMaxDuration(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{Profiles: ProfileOpts{MaxCaptureDuration: 100 * time.Millisecond}})
+
+	w := httptest.NewRecorder()
+	Capture(rec)(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/capture?profile=trace&duration=200ms", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "must not exceed 100ms")
+	assert.Empty(t, rec.Profiles())
+
+	// without ?duration= the capture defaults to 10 seconds, which exceeds the max as well
+	w = httptest.NewRecorder()
+	Capture(rec)(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/capture?profile=trace", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	Capture(rec)(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/capture?profile=trace&duration=50ms", http.NoBody))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
@@ -0,0 +1,103 @@
+package pprofrec
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProfileExporter receives every profile after it has been captured, e.g. to push it to a continuous-profiling server.
+// Export is called from the capturing goroutine with the data of the profile.
+type ProfileExporter interface {
+	// Export receives the profile along with the tags of the recorder at the time of the export.
+	Export(ctx context.Context, p Profile, tags map[string]string) error
+}
+
+// PyroscopeExporter pushes CPU, heap and other profiles in the gzipped protobuf format to the ingest API
+// of a Pyroscope server, labeled by the tags of the recorder, so that pprofrec acts as a lightweight
+// continuous-profiling agent. Traces and profiles captured with Debug > 0 are skipped.
+type PyroscopeExporter struct {
+	// URL is the address of the server, e.g. http://localhost:4040.
+	URL string
+	// Token authenticates the requests to the server if set.
+	Token string
+	// Client sends the requests, defaults to a client with a 10s timeout.
+	Client *http.Client
+	// Application names the application, defaults to pprofrec.
+	Application string
+	// Tags are added to the labels of every profile and override tags of the recorder with the same key.
+	Tags map[string]string
+}
+
+// Export pushes p to the ingest API of the server.
+func (e PyroscopeExporter) Export(ctx context.Context, p Profile, tags map[string]string) (err error) {
+	if p.Name == traceProfile || p.Debug > 0 {
+		return
+	}
+
+	labels := map[string]string{}
+	for k, v := range tags {
+		labels[k] = v
+	}
+	for k, v := range e.Tags {
+		labels[k] = v
+	}
+
+	q := url.Values{
+		"name":    {e.getName(p, labels)},
+		"from":    {strconv.FormatInt(p.Time.Unix(), 10)},
+		"until":   {strconv.FormatInt(p.Time.Add(p.Duration).Unix(), 10)},
+		"format":  {"pprof"},
+		"spyName": {"gospy"},
+	}
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if e.Token != "" {
+		header.Set("Authorization", "Bearer "+e.Token)
+	}
+
+	err = post(ctx, e.Client, strings.TrimSuffix(e.URL, "/")+"/ingest?"+q.Encode(), header, p.Data)
+	if err != nil {
+		return fmt.Errorf("failed to push %v profile to pyroscope: %w", p.Name, err)
+	}
+
+	return
+}
+
+// pyroscopeLabelReplacer replaces the characters that delimit the labels of an application name.
+var pyroscopeLabelReplacer = strings.NewReplacer("{", "_", "}", "_", ",", "_", "=", "_", " ", "_")
+
+// getName returns the application name of p with its labels, e.g. pprofrec.cpu{region=eu,sha=abc}.
+func (e PyroscopeExporter) getName(p Profile, labels map[string]string) string {
+	app := e.Application
+	if app == "" {
+		app = "pprofrec"
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(pyroscopeLabelReplacer.Replace(app))
+	b.WriteByte('.')
+	b.WriteString(p.Name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pyroscopeLabelReplacer.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(pyroscopeLabelReplacer.Replace(labels[k]))
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package pprofrec
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPyroscopeExporter(t *testing.T) {
+	var path string
+	var q url.Values
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		q = r.URL.Query()
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	e := PyroscopeExporter{URL: srv.URL + "/", Token: "secret", Application: "api", Tags: map[string]string{"region": "eu"}}
+	p := Profile{Name: "cpu", Time: time.Unix(100, 0), Duration: 10 * time.Second, Data: []byte{1, 2}}
+
+	err := e.Export(context.Background(), p, map[string]string{"region": "us", "sha": "a b"})
+	require.NoError(t, err)
+	assert.Equal(t, "/ingest", path)
+	assert.Equal(t, "api.cpu{region=eu,sha=a_b}", q.Get("name"))
+	assert.Equal(t, "100", q.Get("from"))
+	assert.Equal(t, "110", q.Get("until"))
+	assert.Equal(t, "pprof", q.Get("format"))
+	assert.Equal(t, "Bearer secret", header.Get("Authorization"))
+	assert.Equal(t, []byte{1, 2}, body)
+
+	path = ""
+	require.NoError(t, e.Export(context.Background(), Profile{Name: "trace", Data: []byte{1}}, nil))
+	require.NoError(t, e.Export(context.Background(), Profile{Name: "goroutine", Debug: 2, Data: []byte{1}}, nil))
+	assert.Empty(t, path)
+
+	assert.Equal(t, "pprofrec.heap{}", PyroscopeExporter{}.getName(Profile{Name: "heap"}, nil))
+}
+
+func TestPyroscopeExporterError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	err := PyroscopeExporter{URL: srv.URL}.Export(context.Background(), Profile{Name: "heap"}, nil)
+	assert.EqualError(t, err, "failed to push heap profile to pyroscope: unexpected status code: 401")
+}
+
+type testExporter struct {
+	mu   sync.Mutex
+	ps   []Profile
+	tags []map[string]string
+}
+
+func (e *testExporter) Export(ctx context.Context, p Profile, tags map[string]string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.ps = append(e.ps, p)
+	e.tags = append(e.tags, tags)
+
+	return nil
+}
+
+func TestRecorderExporters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := &testExporter{}
+	rec := newTestRecorder(t, RecorderOpts{
+		Frequency: 20 * time.Millisecond,
+		Tags:      map[string]string{"sha": "abc"},
+		Profiles: ProfileOpts{
+			HeapInterval: 30 * time.Millisecond,
+			Exporters:    []ProfileExporter{e},
+		},
+	})
+	rec.Start(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+	rec.Stop()
+
+	p, err := rec.Capture(ctx, ProfileTrigger{Rule: AlertRule{Name: "manual"}, Profile: "goroutine"})
+	require.NoError(t, err)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	require.True(t, len(e.ps) > 1)
+	assert.Equal(t, "heap", e.ps[0].Name)
+	assert.NotEmpty(t, e.ps[0].Data)
+	assert.Equal(t, map[string]string{"sha": "abc"}, e.tags[0])
+	assert.Equal(t, p.ID, e.ps[len(e.ps)-1].ID)
+}
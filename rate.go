@@ -0,0 +1,91 @@
+package pprofrec
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Rates holds the rates of cumulative counters since the previous record, normalized by the actual time
+// between the records, so that they remain comparable when the frequency changes or ticks are delayed.
+// The rates of the first record are zero.
+type Rates struct {
+	// Mallocs is the number of heap objects allocated per second.
+	Mallocs float64 `json:"mallocs"`
+	// TotalAlloc is the number of bytes allocated for heap objects per second.
+	TotalAlloc float64 `json:"totalAlloc"`
+	// NumGC is the number of completed GC cycles per minute.
+	NumGC float64 `json:"numGC"`
+	// ReadCount is the number of read operations per second.
+	ReadCount float64 `json:"readCount"`
+	// WriteCount is the number of write operations per second.
+	WriteCount float64 `json:"writeCount"`
+	// CPU is the user and system CPU time of the process in percent of the wall time times GOMAXPROCS.
+	CPU float64 `json:"cpu"`
+}
+
+// rateCounters holds the cumulative counters that rates are derived from.
+type rateCounters struct {
+	time       time.Time
+	mallocs    uint64
+	totalAlloc uint64
+	numGC      uint32
+	readCount  uint64
+	writeCount uint64
+	cpu        float64
+	gomaxprocs int
+}
+
+func getRateCounters(t time.Time, ms *runtime.MemStats, ioc *process.IOCountersStat, ts *cpu.TimesStat) (c rateCounters) {
+	c.time = t
+	c.mallocs = ms.Mallocs
+	c.totalAlloc = ms.TotalAlloc
+	c.numGC = ms.NumGC
+	c.gomaxprocs = runtime.GOMAXPROCS(0)
+
+	if ts != nil {
+		c.cpu = ts.User + ts.System
+	}
+
+	if ioc != nil {
+		c.readCount = ioc.ReadCount
+		c.writeCount = ioc.WriteCount
+	}
+
+	return
+}
+
+// getRates returns the rates of the counters between previous and current, they are zero if previous is unset.
+func getRates(previous rateCounters, current rateCounters) (r Rates) {
+	if previous.time.IsZero() {
+		return
+	}
+
+	elapsed := current.time.Sub(previous.time).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	r.Mallocs = getRate(float64(current.mallocs), float64(previous.mallocs), elapsed)
+	r.TotalAlloc = getRate(float64(current.totalAlloc), float64(previous.totalAlloc), elapsed)
+	r.NumGC = 60 * getRate(float64(current.numGC), float64(previous.numGC), elapsed)
+	r.ReadCount = getRate(float64(current.readCount), float64(previous.readCount), elapsed)
+	r.WriteCount = getRate(float64(current.writeCount), float64(previous.writeCount), elapsed)
+
+	if current.gomaxprocs > 0 {
+		r.CPU = 100 * getRate(current.cpu, previous.cpu, elapsed) / float64(current.gomaxprocs)
+	}
+
+	return
+}
+
+// getRate returns the per second rate of a counter, counters that were reset yield zero.
+func getRate(current float64, previous float64, elapsed float64) float64 {
+	if current < previous {
+		return 0
+	}
+
+	return (current - previous) / elapsed
+}
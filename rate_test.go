@@ -0,0 +1,42 @@
+package pprofrec
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRates(t *testing.T) {
+	now := time.Now()
+
+	previous := getRateCounters(now, &runtime.MemStats{Mallocs: 100, TotalAlloc: 1000, NumGC: 1}, &process.IOCountersStat{ReadCount: 10, WriteCount: 20}, &cpu.TimesStat{User: 1, System: 1})
+	current := getRateCounters(now.Add(2*time.Second), &runtime.MemStats{Mallocs: 300, TotalAlloc: 5000, NumGC: 3}, &process.IOCountersStat{ReadCount: 14, WriteCount: 20}, &cpu.TimesStat{User: 2, System: 1.5})
+	previous.gomaxprocs, current.gomaxprocs = 2, 2
+
+	assert.Equal(t, Rates{}, getRates(rateCounters{}, current))
+	assert.Equal(t, Rates{Mallocs: 100, TotalAlloc: 2000, NumGC: 60, ReadCount: 2, CPU: 37.5}, getRates(previous, current))
+	assert.Equal(t, Rates{}, getRates(current, previous))
+	assert.Equal(t, Rates{}, getRates(current, current))
+}
+
+func TestSamplerRates(t *testing.T) {
+	s := newSampler(context.Background(), nil, nil, Columns{Include: []string{"rates"}})
+
+	r := s.getRecord(context.Background())
+	require.NotNil(t, r.Rates)
+	assert.Equal(t, Rates{}, *r.Rates)
+
+	_ = make([]byte, 1<<20)
+	time.Sleep(10 * time.Millisecond)
+
+	r = s.getRecord(context.Background())
+	require.NotNil(t, r.Rates)
+	assert.NotZero(t, r.Rates.Mallocs)
+	assert.NotZero(t, r.Rates.TotalAlloc)
+}
@@ -0,0 +1,478 @@
+package pprofrec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/process"
+)
+
+// SchemaVersion is the version of the encoding of Record, it is sent as the Pprofrec-Schema-Version header
+// of JSON, NDJSON and SSE responses and embedded in store files and recordings. NDJSON written by Tee and
+// the records published by sinks that encode Record, e.g. pprofrecnats.Sink, follow the SchemaVersion of the
+// writing process. It is incremented whenever fields are removed or change their meaning,
+// fields may be added without incrementing it.
+const SchemaVersion = 1
+
+// schemaVersionHeader is the header that responses with encoded records carry SchemaVersion in.
+const schemaVersionHeader = "Pprofrec-Schema-Version"
+
+// Record is a snapshot of the runtime metrics at a point in time, its JSON encoding is versioned by SchemaVersion.
+// Groups that are not recorded, e.g. deselected by Columns or unavailable on the current OS, are omitted.
+type Record struct {
+	// Time is the time the record was taken.
+	Time time.Time `json:"time"`
+	// Pprof holds the counts of the pprof profiles.
+	Pprof PprofStat `json:"pprof"`
+	// MemStats holds the subset of runtime.MemStats that is recorded.
+	MemStats MemStats `json:"memStats"`
+	// RuntimeMetrics holds the runtime/metrics values keyed by metric name,
+	// histograms are recorded as their total number of observations.
+	RuntimeMetrics map[string]float64 `json:"runtimeMetrics,omitempty"`
+	// MemoryInfo, CPUTimes, IOCounters, NumCtxSwitches and PageFaults hold the gopsutil stats of the process.
+	MemoryInfo     *process.MemoryInfoStat     `json:"memoryInfo,omitempty"`
+	CPUTimes       *cpu.TimesStat              `json:"cpuTimes,omitempty"`
+	IOCounters     *process.IOCountersStat     `json:"ioCounters,omitempty"`
+	NumCtxSwitches *process.NumCtxSwitchesStat `json:"numCtxSwitches,omitempty"`
+	PageFaults     *process.PageFaultsStat     `json:"pageFaults,omitempty"`
+	// MemoryLimit holds the soft memory limit and its utilization as read at the time of the record,
+	// both are zero while no limit is in effect.
+	MemoryLimit *MemoryLimit `json:"memoryLimit,omitempty"`
+	// Win32 holds the handle count and priorities of the process, it is only recorded on windows.
+	Win32 *Win32Stat `json:"win32,omitempty"`
+	// SchedLatencies holds the percentiles of the scheduler latencies observed since the previous record.
+	SchedLatencies *SchedLatencies `json:"schedLatencies,omitempty"`
+	// GCPauses holds the count and maximum of the GC pauses since the previous record.
+	GCPauses *GCPauses `json:"gcPauses,omitempty"`
+	// Rates holds the rates of the allocation, GC, IO and CPU counters since the previous record.
+	Rates *Rates `json:"rates,omitempty"`
+	// Overhead holds the cost of taking the record itself.
+	Overhead *Overhead `json:"overhead,omitempty"`
+	// Samples holds the samples of the collectors keyed by collector name.
+	Samples map[string][]Sample `json:"samples,omitempty"`
+	// Annotations holds the annotations that were made since the previous record.
+	Annotations []Annotation `json:"annotations,omitempty"`
+	// Tags holds the tags of the recorder and of its open tag ranges at the time of the record.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// PprofStat holds the counts of the pprof profiles.
+type PprofStat struct {
+	Goroutine    int `json:"goroutine"`
+	Threadcreate int `json:"threadcreate"`
+	Heap         int `json:"heap"`
+	Allocs       int `json:"allocs"`
+	Block        int `json:"block"`
+	Mutex        int `json:"mutex"`
+}
+
+// SchedLatencies holds percentiles of the time goroutines spent runnable before running,
+// read from the /sched/latencies:seconds histogram of runtime/metrics in seconds.
+// The percentiles are given as the upper boundary of the histogram bucket they fall in.
+type SchedLatencies struct {
+	P50 float64 `json:"p50"`
+	P99 float64 `json:"p99"`
+}
+
+// MemoryLimit holds the soft memory limit of the runtime as set by debug.SetMemoryLimit or GOMEMLIMIT.
+type MemoryLimit struct {
+	// Limit is the soft memory limit in bytes.
+	Limit int64 `json:"limit"`
+	// Used is the memory that counts towards the limit in percent of the limit,
+	// which is runtime.MemStats.Sys minus HeapReleased.
+	Used float64 `json:"used"`
+}
+
+// Win32Stat holds the windows specific stats of the process. Handle leaks on windows are the equivalent
+// of file descriptor leaks elsewhere, as files, sockets, events and threads are all referred to by handles.
+type Win32Stat struct {
+	// HandleCount is the number of open handles.
+	HandleCount uint32 `json:"handleCount"`
+	// PriorityClass is the base priority of the priority class, e.g. 8 for NORMAL_PRIORITY_CLASS.
+	PriorityClass int32 `json:"priorityClass"`
+	// IOPriority is the io priority hint, from 0 for very low to 3 for high, 2 being normal.
+	IOPriority uint32 `json:"ioPriority"`
+}
+
+// MemStats holds the subset of runtime.MemStats that is recorded.
+type MemStats struct {
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	Sys          uint64 `json:"sys"`
+	Lookups      uint64 `json:"lookups"`
+	Mallocs      uint64 `json:"mallocs"`
+	Frees        uint64 `json:"frees"`
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapSys      uint64 `json:"heapSys"`
+	HeapIdle     uint64 `json:"heapIdle"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapReleased uint64 `json:"heapReleased"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	StackInuse   uint64 `json:"stackInuse"`
+	StackSys     uint64 `json:"stackSys"`
+	MSpanInuse   uint64 `json:"mSpanInuse"`
+	MSpanSys     uint64 `json:"mSpanSys"`
+	MCacheInuse  uint64 `json:"mCacheInuse"`
+	MCacheSys    uint64 `json:"mCacheSys"`
+	BuckHashSys  uint64 `json:"buckHashSys"`
+	GCSys        uint64 `json:"gcSys"`
+	OtherSys     uint64 `json:"otherSys"`
+	NextGC       uint64 `json:"nextGC"`
+	LastGC       uint64 `json:"lastGC"`
+	PauseTotalNs uint64 `json:"pauseTotalNs"`
+	NumGC        uint32 `json:"numGC"`
+	NumForcedGC  uint32 `json:"numForcedGC"`
+	// GCCPUFraction is the fraction of the available CPU time used by the GC since the program started.
+	GCCPUFraction float64 `json:"gcCPUFraction"`
+}
+
+type capabilities struct {
+	cpuTimeStat    bool
+	iOCounterStat  bool
+	memoryInfoStat bool
+	ctxSwitchStat  bool
+	pageFaultStat  bool
+	memoryLimit    bool
+	win32          bool
+	schedLatencies bool
+	gcPauses       bool
+	rates          bool
+	overhead       bool
+}
+
+// getCapabilities determines what metrics are available on the current OS
+func getCapabilities(ctx context.Context, p *process.Process) (c capabilities) {
+	_, err := p.TimesWithContext(ctx)
+	if err == nil || err.Error() != "not implemented yet" {
+		c.cpuTimeStat = true
+	}
+
+	_, err = p.IOCountersWithContext(ctx)
+	if err == nil || err.Error() != "not implemented yet" {
+		c.iOCounterStat = true
+	}
+
+	_, err = p.MemoryInfoWithContext(ctx)
+	if err == nil || err.Error() != "not implemented yet" {
+		c.memoryInfoStat = true
+	}
+
+	_, err = p.NumCtxSwitchesWithContext(ctx)
+	if err == nil || err.Error() != "not implemented yet" {
+		c.ctxSwitchStat = true
+	}
+
+	_, err = p.PageFaultsWithContext(ctx)
+	if err == nil || err.Error() != "not implemented yet" {
+		c.pageFaultStat = true
+	}
+
+	c.memoryLimit = memoryLimitSupported()
+	c.win32 = win32Supported()
+	c.schedLatencies = schedLatenciesSupported()
+	c.gcPauses = true
+	c.rates = true
+	c.overhead = true
+
+	return
+}
+
+// getGroups returns the groups of cols.
+func getGroups(cols []column) map[*group]bool {
+	groups := map[*group]bool{}
+	for _, col := range cols {
+		groups[col.group] = true
+	}
+
+	return groups
+}
+
+// fillGroups sets the groups of r that are missing but selected by groups to zero values,
+// so that the columns of records that were recorded with other columns, e.g. reloaded from a store file
+// or merged from other instances, can be rendered.
+func (r *Record) fillGroups(groups map[*group]bool) {
+	if groups[memoryInfoGroup] && r.MemoryInfo == nil {
+		r.MemoryInfo = &process.MemoryInfoStat{}
+	}
+	if groups[cpuTimesGroup] && r.CPUTimes == nil {
+		r.CPUTimes = &cpu.TimesStat{}
+	}
+	if groups[ioCountersGroup] && r.IOCounters == nil {
+		r.IOCounters = &process.IOCountersStat{}
+	}
+	if groups[ctxSwitchesGroup] && r.NumCtxSwitches == nil {
+		r.NumCtxSwitches = &process.NumCtxSwitchesStat{}
+	}
+	if groups[pageFaultsGroup] && r.PageFaults == nil {
+		r.PageFaults = &process.PageFaultsStat{}
+	}
+	if groups[memoryLimitGroup] && r.MemoryLimit == nil {
+		r.MemoryLimit = &MemoryLimit{}
+	}
+	if groups[win32Group] && r.Win32 == nil {
+		r.Win32 = &Win32Stat{}
+	}
+	if groups[schedLatenciesGroup] && r.SchedLatencies == nil {
+		r.SchedLatencies = &SchedLatencies{}
+	}
+	if groups[gcPausesGroup] && r.GCPauses == nil {
+		r.GCPauses = &GCPauses{}
+	}
+	if groups[ratesGroup] && r.Rates == nil {
+		r.Rates = &Rates{}
+	}
+	if groups[overheadGroup] && r.Overhead == nil {
+		r.Overhead = &Overhead{}
+	}
+}
+
+// sampler records snapshots of the selected metrics.
+type sampler struct {
+	p              *process.Process
+	collectors     []Collector
+	cols           []column
+	groups         map[*group]bool
+	runtimeMetrics []string
+	onError        func(error)
+	// schedLatencies holds the counts of the scheduler latency histogram of the previous record.
+	schedLatencies []uint64
+	// numGC holds the number of GC cycles of the previous record.
+	numGC uint32
+	// rates holds the counters of the previous record.
+	rates rateCounters
+	// frequency is the frequency getRecord is called at, ticks that are missed are counted as dropped.
+	frequency time.Duration
+	// last holds the time of the previous record.
+	last time.Time
+}
+
+// newSampler determines the available metrics and selects the columns to record,
+// a column is recorded if it is selected by all columns.
+func newSampler(ctx context.Context, onError func(error), collectors []Collector, columns ...Columns) *sampler {
+	var c capabilities
+	p, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		reportError(onError, fmt.Errorf("failed to create process instance: %w", err))
+	} else {
+		c = getCapabilities(ctx, p)
+	}
+
+	s := &sampler{
+		p:       p,
+		cols:    append(getColumns(c), getCollectorColumns(ctx, collectors)...),
+		groups:  map[*group]bool{},
+		onError: onError,
+	}
+
+	for _, cs := range columns {
+		s.cols = cs.filter(s.cols)
+	}
+
+	for _, col := range s.cols {
+		s.groups[col.group] = true
+
+		if col.group == runtimeMetricsGroup {
+			s.runtimeMetrics = append(s.runtimeMetrics, col.name)
+		}
+	}
+
+	for _, c := range collectors {
+		for g := range s.groups {
+			if g.name == c.Name() {
+				s.collectors = append(s.collectors, c)
+
+				break
+			}
+		}
+	}
+
+	return s
+}
+
+// getRecords records a snapshot of the selected metrics
+func (s *sampler) getRecord(ctx context.Context) (r Record) {
+	r.Time = time.Now()
+
+	var allocs, allocBytes uint64
+	if s.groups[overheadGroup] {
+		allocs, allocBytes = readAllocs()
+	}
+
+	var ms runtime.MemStats
+	if s.groups[memStatsGroup] || s.groups[gcPausesGroup] || s.groups[ratesGroup] || s.groups[memoryLimitGroup] {
+		runtime.ReadMemStats(&ms)
+	}
+
+	if s.groups[memStatsGroup] {
+		r.MemStats = MemStats{
+			Alloc:         ms.Alloc,
+			TotalAlloc:    ms.TotalAlloc,
+			Sys:           ms.Sys,
+			Lookups:       ms.Lookups,
+			Mallocs:       ms.Mallocs,
+			Frees:         ms.Frees,
+			HeapAlloc:     ms.HeapAlloc,
+			HeapSys:       ms.HeapSys,
+			HeapIdle:      ms.HeapIdle,
+			HeapInuse:     ms.HeapInuse,
+			HeapReleased:  ms.HeapReleased,
+			HeapObjects:   ms.HeapObjects,
+			StackInuse:    ms.StackInuse,
+			StackSys:      ms.StackSys,
+			MSpanInuse:    ms.MSpanInuse,
+			MSpanSys:      ms.MSpanSys,
+			MCacheInuse:   ms.MCacheInuse,
+			MCacheSys:     ms.MCacheSys,
+			BuckHashSys:   ms.BuckHashSys,
+			GCSys:         ms.GCSys,
+			OtherSys:      ms.OtherSys,
+			NextGC:        ms.NextGC,
+			LastGC:        ms.LastGC,
+			PauseTotalNs:  ms.PauseTotalNs,
+			NumGC:         ms.NumGC,
+			NumForcedGC:   ms.NumForcedGC,
+			GCCPUFraction: ms.GCCPUFraction,
+		}
+	}
+
+	if s.groups[runtimeMetricsGroup] {
+		r.RuntimeMetrics = readRuntimeMetrics(s.runtimeMetrics)
+	}
+
+	if s.groups[pprofGroup] {
+		r.Pprof = PprofStat{
+			Goroutine:    pprof.Lookup("goroutine").Count(),
+			Threadcreate: pprof.Lookup("threadcreate").Count(),
+			Heap:         pprof.Lookup("heap").Count(),
+			Allocs:       pprof.Lookup("allocs").Count(),
+			Block:        pprof.Lookup("block").Count(),
+			Mutex:        pprof.Lookup("mutex").Count(),
+		}
+	}
+
+	if s.groups[cpuTimesGroup] {
+		cpuTimeStat, err := s.p.TimesWithContext(ctx)
+		if err != nil {
+			reportError(s.onError, fmt.Errorf("failed to get cpu time stats: %w", err))
+		}
+		if cpuTimeStat != nil {
+			r.CPUTimes = cpuTimeStat
+		} else {
+			r.CPUTimes = &cpu.TimesStat{}
+		}
+	}
+
+	if s.groups[ioCountersGroup] {
+		iOCounterStat, err := s.p.IOCountersWithContext(ctx)
+		if err != nil {
+			reportError(s.onError, fmt.Errorf("failed to get io counter stats: %w", err))
+		}
+		if iOCounterStat != nil {
+			r.IOCounters = iOCounterStat
+		} else {
+			r.IOCounters = &process.IOCountersStat{}
+		}
+	}
+
+	if s.groups[memoryInfoGroup] {
+		memoryInfoStat, err := s.p.MemoryInfoWithContext(ctx)
+		if err != nil {
+			reportError(s.onError, fmt.Errorf("failed to get memory info stats: %w", err))
+		}
+		if memoryInfoStat != nil {
+			r.MemoryInfo = memoryInfoStat
+		} else {
+			r.MemoryInfo = &process.MemoryInfoStat{}
+		}
+	}
+
+	if s.groups[ctxSwitchesGroup] {
+		ctxSwitchStat, err := s.p.NumCtxSwitchesWithContext(ctx)
+		if err != nil {
+			reportError(s.onError, fmt.Errorf("failed to get context switch stats: %w", err))
+		}
+		if ctxSwitchStat != nil {
+			r.NumCtxSwitches = ctxSwitchStat
+		} else {
+			r.NumCtxSwitches = &process.NumCtxSwitchesStat{}
+		}
+	}
+
+	if s.groups[pageFaultsGroup] {
+		pageFaultStat, err := s.p.PageFaultsWithContext(ctx)
+		if err != nil {
+			reportError(s.onError, fmt.Errorf("failed to get page fault stats: %w", err))
+		}
+		if pageFaultStat != nil {
+			r.PageFaults = pageFaultStat
+		} else {
+			r.PageFaults = &process.PageFaultsStat{}
+		}
+	}
+
+	if s.groups[memoryLimitGroup] {
+		r.MemoryLimit = getMemoryLimit(&ms, readMemoryLimit())
+	}
+
+	if s.groups[win32Group] {
+		win32Stat, err := readWin32Stat(ctx, s.p)
+		if err != nil {
+			reportError(s.onError, fmt.Errorf("failed to get win32 stats: %w", err))
+		}
+		r.Win32 = &win32Stat
+	}
+
+	if s.groups[schedLatenciesGroup] {
+		var sl SchedLatencies
+		sl, s.schedLatencies = readSchedLatencies(s.schedLatencies)
+		r.SchedLatencies = &sl
+	}
+
+	if s.groups[gcPausesGroup] {
+		p := getGCPauses(&ms, s.numGC)
+		s.numGC = ms.NumGC
+		r.GCPauses = &p
+	}
+
+	if s.groups[ratesGroup] {
+		ioc := r.IOCounters
+		if ioc == nil && s.p != nil {
+			ioc, _ = s.p.IOCountersWithContext(ctx)
+		}
+
+		ts := r.CPUTimes
+		if ts == nil && s.p != nil {
+			ts, _ = s.p.TimesWithContext(ctx)
+		}
+
+		counters := getRateCounters(r.Time, &ms, ioc, ts)
+		rates := getRates(s.rates, counters)
+		s.rates = counters
+		r.Rates = &rates
+	}
+
+	if len(s.collectors) > 0 {
+		r.Samples = make(map[string][]Sample, len(s.collectors))
+		for _, c := range s.collectors {
+			r.Samples[c.Name()] = c.Collect(ctx)
+		}
+	}
+
+	if s.groups[overheadGroup] {
+		objects, bytes := readAllocs()
+		r.Overhead = &Overhead{
+			Duration:     time.Since(r.Time),
+			Allocs:       objects - allocs,
+			AllocBytes:   bytes - allocBytes,
+			DroppedTicks: getDroppedTicks(s.last, r.Time, s.frequency),
+		}
+		s.last = r.Time
+	}
+
+	return
+}
@@ -0,0 +1,555 @@
+package pprofrec
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RecorderOpts configures a Recorder.
+type RecorderOpts struct {
+	// Window defines a window within metrics are stored, defaults to 30s if zero.
+	Window time.Duration
+	// Frequency defines at what frequency metrics are recorded, defaults to 1s or to Window if it is shorter.
+	Frequency time.Duration
+	// Columns selects the metrics that are recorded.
+	Columns Columns
+	// Collectors collect application-defined metrics that are recorded alongside the runtime metrics.
+	Collectors []Collector
+	// OnRecord is called with every record after it has been recorded,
+	// it is called from the recording goroutine and delays the next record until it returns.
+	OnRecord func(r Record)
+	// Alerts configures alerting on the recorded metrics.
+	Alerts AlertOpts
+	// Profiles configures capturing profiles when metrics spike.
+	Profiles ProfileOpts
+	// Leak configures the goroutine leak detection.
+	Leak LeakOpts
+	// Store configures checkpointing the recorded metrics to disk across restarts.
+	Store StoreOpts
+	// Sinks receive every record after it has been recorded.
+	Sinks []Sink
+	// OnError is called with failures to sample, render, store or forward metrics,
+	// they are logged if nil.
+	OnError func(error)
+	// Expvar publishes the latest recorded values under the pprofrec expvar map keyed by the qualified column names,
+	// bytes are published in bytes and durations in nanoseconds.
+	Expvar bool
+	// Resolutions defines additional windows that are recorded at a lower frequency
+	// from the same samples, their frequency has to be greater than Frequency.
+	Resolutions []Resolution
+	// MaxBytes bounds the estimated memory used by the recorded metrics, the oldest records are dropped
+	// once it is exceeded. It applies to the window and each of the Resolutions separately and is unbounded if zero.
+	MaxBytes int
+	// BlockProfileRate is passed to runtime.SetBlockProfileRate while recording, so that the block profile
+	// is populated, e.g. 1 records every blocking event. It is reset to zero once recording stops.
+	BlockProfileRate int
+	// MutexProfileFraction is passed to runtime.SetMutexProfileFraction while recording, so that the mutex
+	// profile is populated, e.g. 1 records every contention event. The previous fraction is restored once recording stops.
+	MutexProfileFraction int
+	// Tags are attached to every record and listed in the metadata, e.g. the build SHA or region.
+	Tags map[string]string
+}
+
+// Resolution defines a window within metrics are stored at a given frequency.
+type Resolution struct {
+	// Window defines a window within metrics are stored.
+	Window time.Duration
+	// Frequency defines at what frequency metrics are stored.
+	Frequency time.Duration
+}
+
+// resolution downsamples the recorded metrics into its own ring.
+type resolution struct {
+	Resolution
+	rs   *ring
+	last time.Time
+}
+
+// Recorder records runtime metrics at a given frequency within a given window.
+type Recorder struct {
+	opts RecorderOpts
+	s    *sampler
+	rs   *ring
+	a    *alerter
+
+	resolutions []*resolution
+
+	annotations *annotationStore
+	tags        *tagStore
+	expvar      *expvarPublisher
+	tees        *teeSet
+
+	triggers []profileTrigger
+	profiles *profileStore
+	captures sync.WaitGroup
+
+	// heapProfiles holds the periodic heap profiles.
+	heapProfiles *profileStore
+	// cpuProfiles holds the periodic CPU profiles of the window, their ids are shared with profiles.
+	cpuProfiles *profileStore
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRecorder returns a Recorder that records metrics once started, configured by opts.
+// Zero values are replaced by their documented defaults, the error of RecorderOpts.Validate is returned
+// if the options are invalid nonetheless.
+//
+//	rec, err := pprofrec.NewRecorder(pprofrec.WithWindow(time.Hour), pprofrec.WithFrequency(500*time.Millisecond))
+func NewRecorder(opts ...Option) (rec *Recorder, err error) {
+	var o RecorderOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o = o.withDefaults()
+
+	err = o.Validate()
+	if err != nil {
+		return
+	}
+
+	return newRecorder(o), nil
+}
+
+// newRecorder returns a Recorder of valid opts.
+func newRecorder(opts RecorderOpts) *Recorder {
+	s := newSampler(context.Background(), opts.OnError, opts.Collectors, opts.Columns)
+	s.frequency = opts.Frequency
+
+	rec := &Recorder{
+		opts: opts,
+		s:    s,
+		rs:   newRing(int((opts.Window/opts.Frequency)+1), opts.MaxBytes),
+		a:    newAlerter(opts.OnError, opts.Alerts.Rules, s.cols),
+
+		annotations: newAnnotationStore(opts.Window),
+		tags:        newTagStore(opts.Tags),
+		tees:        newTeeSet(),
+
+		triggers: newProfileTriggers(opts.OnError, opts.Profiles.Triggers, s.cols),
+		profiles: newProfileStore(opts.Profiles.Max),
+	}
+
+	heapMax := opts.Profiles.HeapMax
+	if heapMax == 0 {
+		heapMax = 12
+	}
+	rec.heapProfiles = newProfileStore(heapMax)
+
+	cpuMax := 1
+	if opts.Profiles.CPUInterval > 0 {
+		cpuMax = int(opts.Window/opts.Profiles.CPUInterval) + 1
+	}
+	rec.cpuProfiles = rec.profiles.share(cpuMax)
+
+	for _, res := range opts.Resolutions {
+		rec.resolutions = append(rec.resolutions, &resolution{
+			Resolution: res,
+			rs:         newRing(int((res.Window/res.Frequency)+1), opts.MaxBytes),
+		})
+	}
+
+	if opts.Expvar {
+		rec.expvar = newExpvarPublisher(s.cols)
+	}
+
+	if opts.Store.Path != "" {
+		rs, err := loadRecords(opts.Store.Path)
+		if err != nil {
+			reportError(opts.OnError, fmt.Errorf("failed to load records from %v: %w", opts.Store.Path, err))
+		}
+
+		// the records may have been recorded with other columns
+		for _, r := range rs {
+			r.fillGroups(s.groups)
+			rec.rs.push(r)
+		}
+	}
+
+	return rec
+}
+
+// Start starts recording metrics in the background until ctx is done or Stop is called.
+// Calling Start on a running Recorder has no effect.
+func (rec *Recorder) Start(ctx context.Context) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.cancel != nil {
+		return
+	}
+
+	ctx, rec.cancel = context.WithCancel(ctx)
+	rec.done = make(chan struct{})
+
+	restore := setProfileRates(rec.opts.BlockProfileRate, rec.opts.MutexProfileFraction)
+
+	go rec.run(ctx, rec.done, restore)
+}
+
+// setProfileRates enables the block and mutex profiles with the given rates if they are greater than zero
+// and returns a func that disables them again.
+// The block profile rate can't be read, so it is reset to zero instead of being restored.
+func setProfileRates(blockProfileRate int, mutexProfileFraction int) (restore func()) {
+	if blockProfileRate > 0 {
+		runtime.SetBlockProfileRate(blockProfileRate)
+	}
+
+	previousMutexProfileFraction := -1
+	if mutexProfileFraction > 0 {
+		previousMutexProfileFraction = runtime.SetMutexProfileFraction(mutexProfileFraction)
+	}
+
+	return func() {
+		if blockProfileRate > 0 {
+			runtime.SetBlockProfileRate(0)
+		}
+
+		if previousMutexProfileFraction >= 0 {
+			runtime.SetMutexProfileFraction(previousMutexProfileFraction)
+		}
+	}
+}
+
+// Stop stops recording metrics and waits until the background recording and captures returned and the sinks are flushed.
+// The recorded metrics remain available and are checkpointed if a store is configured.
+func (rec *Recorder) Stop() {
+	rec.mu.Lock()
+	cancel, done := rec.cancel, rec.done
+	rec.cancel, rec.done = nil, nil
+	rec.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+// Records returns a copy of the recorded metrics, ordered from oldest to latest.
+func (rec *Recorder) Records() []Record {
+	return rec.rs.snapshot()
+}
+
+// Snapshot is an alias of Records for tooling that depends on the shape of Record as versioned by SchemaVersion.
+func (rec *Recorder) Snapshot() []Record {
+	return rec.Records()
+}
+
+// RecordsAt returns a copy of the metrics recorded at the given frequency, ordered from oldest to latest,
+// ok is false if neither Frequency nor any of the Resolutions match it.
+func (rec *Recorder) RecordsAt(frequency time.Duration) (rs []Record, ok bool) {
+	if frequency == rec.opts.Frequency {
+		return rec.rs.snapshot(), true
+	}
+
+	for _, res := range rec.resolutions {
+		if res.Frequency == frequency {
+			return res.rs.snapshot(), true
+		}
+	}
+
+	return nil, false
+}
+
+// Latest returns the latest recorded metrics, ok is false if nothing has been recorded yet.
+func (rec *Recorder) Latest() (r Record, ok bool) {
+	return rec.rs.latest()
+}
+
+// Annotate records an annotation with the given label at the current time,
+// it is included in the next record and kept for the duration of the window.
+func (rec *Recorder) Annotate(label string) Annotation {
+	a := Annotation{Time: time.Now(), Label: label}
+	rec.annotations.add(a)
+
+	return a
+}
+
+// Tag attaches the tag key with value to the records from now on, e.g. the build SHA or region,
+// it is listed in the metadata.
+func (rec *Recorder) Tag(key string, value string) {
+	rec.tags.set(key, value)
+}
+
+// TagRange attaches the tag key with value to the records until end is called, e.g. to mark a load test,
+// it overrides a tag of the same key. The html table marks the records at which the tags change.
+func (rec *Recorder) TagRange(key string, value string) (end func()) {
+	return rec.tags.startRange(key, value)
+}
+
+// metadata returns the metadata of the process with the tags of rec.
+func (rec *Recorder) metadata() Metadata {
+	m := getMetadata()
+	m.Tags = rec.tags.getTags()
+
+	return m
+}
+
+// Annotations returns the annotations within the window, ordered from oldest to latest.
+func (rec *Recorder) Annotations() []Annotation {
+	return rec.annotations.list()
+}
+
+// Profiles returns the captured profiles without their data, ordered from oldest to latest.
+func (rec *Recorder) Profiles() []Profile {
+	ps := append(rec.profiles.list(), rec.cpuProfiles.list()...)
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].ID < ps[j].ID
+	})
+
+	return ps
+}
+
+// Profile returns the captured profile with the given id, ok is false if it has been dropped.
+func (rec *Recorder) Profile(id int) (p Profile, ok bool) {
+	p, ok = rec.profiles.get(id)
+	if ok {
+		return
+	}
+
+	return rec.cpuProfiles.get(id)
+}
+
+// getAlertValues returns the values of the column of a over its records, nil if a was fired by an expression.
+func (rec *Recorder) getAlertValues(a Alert) (vs []float64) {
+	for _, col := range rec.s.cols {
+		if col.qualifiedName() != a.Column {
+			continue
+		}
+
+		for _, r := range a.Records {
+			vs = append(vs, col.value(r))
+		}
+
+		return
+	}
+
+	return
+}
+
+func (rec *Recorder) run(ctx context.Context, done chan struct{}, restore func()) {
+	defer close(done)
+	defer restore()
+	defer rec.captures.Wait()
+
+	var alerts chan Alert
+	if len(rec.a.states) > 0 {
+		alerts = make(chan Alert, 64)
+		defer close(alerts)
+
+		go notify(rec.opts.Alerts.Notifiers, alerts, rec.opts.OnError)
+	}
+
+	// the sinks are closed before waiting for them to drain and flush their pending records
+	var drains sync.WaitGroup
+	defer drains.Wait()
+
+	sinks := make([]chan Record, len(rec.opts.Sinks))
+	for i, s := range rec.opts.Sinks {
+		sinks[i] = make(chan Record, 64)
+		defer close(sinks[i])
+
+		drains.Add(1)
+		go func(s Sink, rs <-chan Record) {
+			defer drains.Done()
+
+			drain(s, rec.s.cols, rs, rec.opts.OnError)
+		}(s, sinks[i])
+	}
+
+	ticker := time.NewTicker(rec.opts.Frequency)
+	defer ticker.Stop()
+
+	var checkpoints <-chan time.Time
+	if rec.opts.Store.Path != "" {
+		checkpointTicker := time.NewTicker(rec.opts.Store.Interval)
+		defer checkpointTicker.Stop()
+		defer rec.checkpoint()
+
+		checkpoints = checkpointTicker.C
+	}
+
+	var heapCaptures <-chan time.Time
+	if rec.opts.Profiles.HeapInterval > 0 {
+		heapTicker := time.NewTicker(rec.opts.Profiles.HeapInterval)
+		defer heapTicker.Stop()
+
+		heapCaptures = heapTicker.C
+	}
+
+	var cpuCaptures <-chan time.Time
+	if rec.opts.Profiles.CPUInterval > 0 {
+		cpuTicker := time.NewTicker(rec.opts.Profiles.CPUInterval)
+		defer cpuTicker.Stop()
+
+		cpuCaptures = cpuTicker.C
+	}
+
+	var previous Record
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-checkpoints:
+			rec.checkpoint()
+		case t := <-heapCaptures:
+			rec.captures.Add(1)
+			go rec.captureHeap(t)
+		case t := <-cpuCaptures:
+			rec.captures.Add(1)
+			go rec.captureCPU(ctx, t)
+		case <-ticker.C:
+			r := rec.s.getRecord(ctx)
+			r.Annotations = rec.annotations.between(previous.Time, r.Time)
+			r.Tags = rec.tags.get()
+			rec.rs.push(r)
+
+			if rec.expvar != nil {
+				rec.expvar.publish(r)
+			}
+
+			// tolerate ticks that are late by less than half the frequency
+			for _, res := range rec.resolutions {
+				if r.Time.Sub(res.last) >= res.Frequency-rec.opts.Frequency/2 {
+					res.rs.push(r)
+					res.last = r.Time
+				}
+			}
+
+			for _, s := range sinks {
+				select {
+				case s <- r:
+				default:
+					reportError(rec.opts.OnError, fmt.Errorf("dropping record of %v: sink falls behind", r.Time))
+				}
+			}
+
+			rec.tees.push(r, rec.opts.OnError)
+
+			if rec.opts.OnRecord != nil {
+				rec.opts.OnRecord(r)
+			}
+
+			for _, a := range rec.a.evaluate(previous, r) {
+				a.Records = limitLast(rec.rs.snapshot(), alertValues)
+				a.Values = rec.getAlertValues(a)
+
+				select {
+				case alerts <- a:
+				default:
+					reportError(rec.opts.OnError, fmt.Errorf("dropping alert %v: too many pending alerts", a.Rule))
+				}
+			}
+
+			for _, pt := range rec.triggers {
+				if pt.fired(previous, r) {
+					rec.captures.Add(1)
+					go rec.capture(ctx, pt.t, r.Time)
+				}
+			}
+
+			previous = r
+		}
+	}
+}
+
+func (rec *Recorder) checkpoint() {
+	err := saveRecords(rec.opts.Store.Path, rec.rs.snapshot())
+	if err != nil {
+		reportError(rec.opts.OnError, fmt.Errorf("failed to save records to %v: %w", rec.opts.Store.Path, err))
+	}
+}
+
+func (rec *Recorder) capture(ctx context.Context, t ProfileTrigger, ts time.Time) {
+	defer rec.captures.Done()
+
+	_, err := rec.store(captureProfile(ctx, t, ts))
+	if err != nil {
+		reportError(rec.opts.OnError, fmt.Errorf("failed to capture %v profile: %w", t.Profile, err))
+	}
+}
+
+func (rec *Recorder) captureHeap(t time.Time) {
+	defer rec.captures.Done()
+
+	p, err := captureHeapProfile(t)
+	if err != nil {
+		reportError(rec.opts.OnError, fmt.Errorf("failed to capture periodic heap profile: %w", err))
+
+		return
+	}
+
+	p = rec.heapProfiles.add(p)
+	rec.export(p)
+}
+
+func (rec *Recorder) captureCPU(ctx context.Context, t time.Time) {
+	defer rec.captures.Done()
+
+	p, err := captureProfile(ctx, ProfileTrigger{Rule: AlertRule{Name: "periodic"}, Profile: cpuProfile, Duration: rec.opts.Profiles.CPUDuration}, t)
+	if err != nil {
+		reportError(rec.opts.OnError, fmt.Errorf("failed to capture periodic cpu profile: %w", err))
+
+		return
+	}
+
+	p = rec.cpuProfiles.add(p)
+	rec.export(p)
+}
+
+// export passes p to the exporters of the recorder along with the current tags.
+func (rec *Recorder) export(p Profile) {
+	tags := rec.tags.get()
+	for _, e := range rec.opts.Profiles.Exporters {
+		err := e.Export(context.Background(), p, tags)
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to export %v profile: %w", p.Name, err))
+		}
+	}
+}
+
+// HeapProfiles returns the periodic heap profiles without their data, ordered from oldest to latest.
+func (rec *Recorder) HeapProfiles() []Profile {
+	return rec.heapProfiles.list()
+}
+
+// Capture captures the profile named by t on demand, e.g. a trace when an operator notices an anomaly,
+// and stores it alongside the profiles captured by triggers. The Rule of t only names the capture.
+// CPU profiles and traces are captured for the Duration of t or until ctx is done.
+func (rec *Recorder) Capture(ctx context.Context, t ProfileTrigger) (p Profile, err error) {
+	t, err = t.withDefaults()
+	if err != nil {
+		return
+	}
+
+	return rec.store(captureProfile(ctx, t, time.Now()))
+}
+
+// store stores the captured profile p unless err is set and writes it to the profile directory if configured.
+func (rec *Recorder) store(p Profile, err error) (Profile, error) {
+	if err != nil {
+		return p, err
+	}
+
+	p = rec.profiles.add(p)
+	rec.export(p)
+
+	if rec.opts.Profiles.Dir != "" {
+		err = ioutil.WriteFile(filepath.Join(rec.opts.Profiles.Dir, p.filename()), p.Data, 0644)
+		if err != nil {
+			return p, fmt.Errorf("failed to write profile: %w", err)
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,147 @@
+package pprofrec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{Window: 200 * time.Millisecond, Frequency: 50 * time.Millisecond})
+
+	_, ok := rec.Latest()
+	assert.False(t, ok)
+
+	rec.Start(context.Background())
+	time.Sleep(400 * time.Millisecond)
+	rec.Stop()
+
+	rs := rec.Records()
+	require.Len(t, rs, 5)
+	for i := 1; i < len(rs); i++ {
+		assert.True(t, rs[i].Time.After(rs[i-1].Time))
+	}
+
+	latest, ok := rec.Latest()
+	require.True(t, ok)
+	assert.Equal(t, rs[len(rs)-1].Time, latest.Time)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, rs, rec.Records())
+	assert.Equal(t, rs, rec.Snapshot())
+
+	snapshot := rec.Snapshot()
+	snapshot[0] = Record{}
+	assert.Equal(t, rs, rec.Snapshot())
+}
+
+func TestRecorderOnRecord(t *testing.T) {
+	rs := make(chan Record, 10)
+	rec := newTestRecorder(t, RecorderOpts{Frequency: 50 * time.Millisecond, OnRecord: func(r Record) {
+		rs <- r
+	}})
+
+	rec.Start(context.Background())
+	defer rec.Stop()
+
+	select {
+	case r := <-rs:
+		assert.NotZero(t, r.Pprof.Goroutine)
+
+		_, ok := rec.Latest()
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("OnRecord was not called")
+	}
+}
+
+func TestRecorderResolutions(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{
+		Window:    time.Second,
+		Frequency: 20 * time.Millisecond,
+		Resolutions: []Resolution{
+			{Window: time.Second, Frequency: 100 * time.Millisecond},
+		},
+	})
+
+	rec.Start(context.Background())
+	time.Sleep(450 * time.Millisecond)
+	rec.Stop()
+
+	rs, ok := rec.RecordsAt(20 * time.Millisecond)
+	require.True(t, ok)
+	assert.Equal(t, rec.Records(), rs)
+
+	coarse, ok := rec.RecordsAt(100 * time.Millisecond)
+	require.True(t, ok)
+	require.NotEmpty(t, coarse)
+	assert.Less(t, len(coarse), len(rs))
+	for i := 1; i < len(coarse); i++ {
+		assert.True(t, coarse[i].Time.Sub(coarse[i-1].Time) >= 90*time.Millisecond)
+	}
+
+	_, ok = rec.RecordsAt(10 * time.Millisecond)
+	assert.False(t, ok)
+}
+
+func TestNewRecorderInvalidOpts(t *testing.T) {
+	_, err := NewRecorder(WithFrequency(-time.Second))
+	assert.EqualError(t, err, "frequency -1s must be positive")
+
+	_, err = NewRecorder(WithFrequency(20*time.Millisecond), WithResolutions(Resolution{Window: time.Second, Frequency: 10 * time.Millisecond}))
+	assert.EqualError(t, err, "invalid resolution: frequency 10ms must be greater than 20ms")
+
+	rec, err := NewRecorder()
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, rec.opts.Window)
+	assert.Equal(t, time.Second, rec.opts.Frequency)
+	assert.Equal(t, 10*time.Second, rec.opts.Store.Interval)
+
+	// the default frequency does not exceed the window
+	rec, err = NewRecorder(WithWindow(500 * time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, rec.opts.Frequency)
+}
+
+func TestWindowInvalidOpts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var errs []error
+	h := Window(ctx, WindowOpts{Window: -time.Minute, OnError: func(err error) { errs = append(errs, err) }})
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "invalid window options, recording with the defaults: window -1m0s must be positive")
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	wh := NewWindowHandler(ctx, WindowOpts{Window: 500 * time.Millisecond})
+	assert.Equal(t, 500*time.Millisecond, wh.Recorder().opts.Frequency)
+}
+
+func TestRecorderProfileRates(t *testing.T) {
+	previous := runtime.SetMutexProfileFraction(-1)
+
+	rec := newTestRecorder(t, RecorderOpts{Frequency: 50 * time.Millisecond, BlockProfileRate: 1, MutexProfileFraction: 5})
+
+	rec.Start(context.Background())
+	assert.Equal(t, 5, runtime.SetMutexProfileFraction(-1))
+
+	rec.Stop()
+	assert.Equal(t, previous, runtime.SetMutexProfileFraction(-1))
+}
+
+// newTestRecorder returns a Recorder of opts and fails the test if they are invalid.
+func newTestRecorder(t *testing.T, opts RecorderOpts) *Recorder {
+	rec, err := NewRecorder(WithOpts(opts))
+	require.NoError(t, err)
+
+	return rec
+}
@@ -0,0 +1,324 @@
+package pprofrec
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// recordingVersion is incremented whenever the recording format changes in an incompatible way.
+const recordingVersion = 1
+
+// recordingHeader is the first line of a recording, it describes the recorded columns
+// so that a recording can be rendered by a process that recorded different metrics.
+type recordingHeader struct {
+	Version int `json:"version"`
+	// SchemaVersion is the SchemaVersion of the records, recordings written before it was embedded omit it.
+	SchemaVersion int               `json:"schemaVersion,omitempty"`
+	Frequency     time.Duration     `json:"frequency"`
+	Columns       []recordingColumn `json:"columns"`
+	Metadata      *Metadata         `json:"metadata,omitempty"`
+}
+
+type recordingColumn struct {
+	Group string `json:"group"`
+	Name  string `json:"name"`
+	Unit  Unit   `json:"unit,omitempty"`
+}
+
+// WriteTo writes the recorded metrics as a recording that is served by the Replay handler,
+// the recording is newline-delimited JSON that starts with a header followed by a record per line.
+func (rec *Recorder) WriteTo(w io.Writer) (n int64, err error) {
+	cw := &countingWriter{w: w}
+	e := json.NewEncoder(cw)
+
+	m := rec.metadata()
+	h := recordingHeader{
+		Version:       recordingVersion,
+		SchemaVersion: SchemaVersion,
+		Frequency:     rec.opts.Frequency,
+		Metadata:      &m,
+	}
+	for _, col := range rec.s.cols {
+		h.Columns = append(h.Columns, recordingColumn{
+			Group: col.group.name,
+			Name:  col.name,
+			Unit:  getSampleUnit(col.unit),
+		})
+	}
+
+	err = e.Encode(h)
+	if err != nil {
+		return cw.n, err
+	}
+
+	for _, r := range rec.Records() {
+		err = e.Encode(r)
+		if err != nil {
+			return cw.n, err
+		}
+	}
+
+	return cw.n, nil
+}
+
+// Replay responds with the html table of the recording read from r, e.g. a file written by Recorder.WriteTo,
+// so that the metrics leading up to an incident can be inspected after the fact. Gzipped recordings are
+// decompressed. The recording is served as JSON and CSV, trimmed by ?cols=, ?window= and ?freq=
+// and rendered according to ?tz= and ?units= like the Window handler.
+func Replay(r io.Reader) http.HandlerFunc {
+	h, cols, rs, readErr := readRecording(r)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(nil, r)
+
+		if readErr != nil {
+			http.Error(w, fmt.Sprintf("failed to read recording: %v", readErr), http.StatusInternalServerError)
+
+			return
+		}
+
+		window, err := getQueryDuration(r, "window", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		frequency, err := getQueryDuration(r, "freq", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		d, err := getQueryDisplay(r, display{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		rs := limitWindow(rs, window)
+		rs = limitFrequency(rs, frequency, h.Frequency/2)
+
+		cols := getQueryColumns(r).filter(cols)
+
+		switch getFormat(r) {
+		case formatHTML:
+		case formatJSON:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set(schemaVersionHeader, strconv.Itoa(SchemaVersion))
+
+			err = writeJSON(w, rs)
+			if err != nil {
+				reportError(nil, fmt.Errorf("failed to write to response writer: %w", err))
+			}
+
+			return
+		case formatCSV:
+			w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+
+			err = writeCSV(w, cols, rs, d.raw)
+			if err != nil {
+				reportError(nil, fmt.Errorf("failed to write to response writer: %w", err))
+			}
+
+			return
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		err = writeHead(w, cols, rs, Theme{}, d)
+		if err != nil {
+			reportError(nil, fmt.Errorf("failed to write to response writer: %w", err))
+
+			return
+		}
+
+		if h.Metadata != nil {
+			err = writeMetadata(w, cols, *h.Metadata, d)
+			if err != nil {
+				reportError(nil, fmt.Errorf("failed to write to response writer: %w", err))
+
+				return
+			}
+		}
+
+		err = writeRows(w, cols, rs, d.newestFirst, func(i int, from time.Time, to time.Time) rowMeta {
+			return rowMeta{annotations: getRecordAnnotations(rs, i, from), display: d}
+		})
+		if err != nil {
+			reportError(nil, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
+
+// getRecordAnnotations returns the annotations of the row of the record at i that covers the time after from,
+// the first row covers the annotations of the records before it.
+func getRecordAnnotations(rs []Record, i int, from time.Time) []Annotation {
+	if !from.IsZero() {
+		return rs[i].Annotations
+	}
+
+	var as []Annotation
+	for _, r := range rs[:i+1] {
+		as = append(as, r.Annotations...)
+	}
+
+	return as
+}
+
+// readRecording reads a recording written by Recorder.WriteTo and restores its columns.
+func readRecording(r io.Reader) (h recordingHeader, cols []column, rs []Record, err error) {
+	br := bufio.NewReader(r)
+
+	// gzip streams start with the magic number 0x1f 0x8b
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return h, nil, nil, err
+		}
+		defer zr.Close()
+
+		br = bufio.NewReader(zr)
+	}
+
+	d := json.NewDecoder(br)
+
+	err = d.Decode(&h)
+	if err != nil {
+		return
+	}
+
+	if h.Version != recordingVersion {
+		return h, nil, nil, fmt.Errorf("unsupported version: %v", h.Version)
+	}
+
+	if h.SchemaVersion > SchemaVersion {
+		return h, nil, nil, fmt.Errorf("unsupported schema version: %v", h.SchemaVersion)
+	}
+
+	cols = getRecordingColumns(h.Columns)
+	groups := getGroups(cols)
+
+	for {
+		var r Record
+		err = d.Decode(&r)
+		if err == io.EOF {
+			return h, cols, rs, nil
+		}
+		if err != nil {
+			return
+		}
+
+		// the records of partial or hand-written recordings may omit groups listed in the header
+		r.fillGroups(groups)
+		rs = append(rs, r)
+	}
+}
+
+// getRecordingColumns restores the columns of a recording, runtime/metrics and collector columns
+// are restored from their unit as they may not be known to this process.
+func getRecordingColumns(rcs []recordingColumn) (cols []column) {
+	known := getColumns(capabilities{cpuTimeStat: true, iOCounterStat: true, memoryInfoStat: true, ctxSwitchStat: true, pageFaultStat: true, memoryLimit: true, win32: true, schedLatencies: true, gcPauses: true, rates: true, overhead: true})
+	groups := map[string]*group{}
+
+rcs:
+	for _, rc := range rcs {
+		if rc.Group == runtimeMetricsGroup.name {
+			name := rc.Name
+			cols = append(cols, column{
+				group: runtimeMetricsGroup,
+				name:  name,
+				unit:  getColumnUnit(rc.Unit),
+				value: getUnitValue(rc.Unit, func(r Record) float64 { return r.RuntimeMetrics[name] }),
+			})
+
+			continue
+		}
+
+		for _, col := range known {
+			if col.group.name == rc.Group && col.name == rc.Name {
+				cols = append(cols, col)
+
+				continue rcs
+			}
+		}
+
+		g, ok := groups[rc.Group]
+		if !ok {
+			g = &group{name: rc.Group, title: rc.Group}
+			groups[rc.Group] = g
+		}
+
+		cols = append(cols, getSampleColumn(g, Sample{Name: rc.Name, Unit: rc.Unit}))
+	}
+
+	return
+}
+
+// getUnitValue converts the values returned by value to nanoseconds if they are given in seconds.
+func getUnitValue(u Unit, value func(r Record) float64) func(r Record) float64 {
+	switch u {
+	case UnitSeconds, UnitTime:
+		return func(r Record) float64 { return seconds(value(r)) }
+	default:
+		return value
+	}
+}
+
+// getColumnUnit returns the unit of a column that is restored with u.
+func getColumnUnit(u Unit) unit {
+	switch u {
+	case UnitBytes:
+		return unitBytes
+	case UnitSeconds:
+		return unitDuration
+	case UnitTime:
+		return unitTime
+	case UnitPercent:
+		return unitPercent
+	default:
+		return unitCount
+	}
+}
+
+// getSampleUnit returns the Unit that a column of unit u is restored with.
+func getSampleUnit(u unit) Unit {
+	switch u {
+	case unitBytes:
+		return UnitBytes
+	case unitDuration:
+		return UnitSeconds
+	case unitTime:
+		return UnitTime
+	case unitPercent:
+		return UnitPercent
+	default:
+		return UnitCount
+	}
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(b []byte) (n int, err error) {
+	n, err = cw.w.Write(b)
+	cw.n += int64(n)
+
+	return
+}
@@ -0,0 +1,121 @@
+package pprofrec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderWriteTo(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{
+		Window:     time.Second,
+		Frequency:  20 * time.Millisecond,
+		Columns:    Columns{Include: []string{"goroutine", "HeapAlloc", "queue"}},
+		Collectors: []Collector{&queueCollector{}},
+	})
+	rec.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	rec.Annotate("deploy")
+	time.Sleep(50 * time.Millisecond)
+	rec.Stop()
+
+	var b bytes.Buffer
+	n, err := rec.WriteTo(&b)
+	require.NoError(t, err)
+	assert.Equal(t, int64(b.Len()), n)
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(t, lines, len(rec.Records())+1)
+
+	h, cols, rs, err := readRecording(bytes.NewReader(b.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Millisecond, h.Frequency)
+	assert.Equal(t, SchemaVersion, h.SchemaVersion)
+	require.NotNil(t, h.Metadata)
+	assert.Equal(t, getMetadata(), *h.Metadata)
+	assert.Len(t, rs, len(rec.Records()))
+
+	var names []string
+	for _, col := range cols {
+		names = append(names, col.qualifiedName())
+	}
+	assert.Equal(t, []string{"pprof.Lookup.goroutine", "runtime.MemStats.HeapAlloc", "queue.depth", "queue.size"}, names)
+	assert.Equal(t, rec.s.cols[3].value(rs[0]), cols[3].value(rs[0]))
+
+	var zb bytes.Buffer
+	zw := gzip.NewWriter(&zb)
+	_, err = rec.WriteTo(zw)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	_, _, zrs, err := readRecording(&zb)
+	require.NoError(t, err)
+	assert.Len(t, zrs, len(rs))
+
+	_, _, _, err = readRecording(strings.NewReader(`{"version":1,"schemaVersion":2}`))
+	assert.EqualError(t, err, "unsupported schema version: 2")
+}
+
+func TestReplay(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Second, Frequency: 20 * time.Millisecond})
+	rec.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	rec.Annotate("deploy")
+	time.Sleep(50 * time.Millisecond)
+	rec.Stop()
+
+	var b bytes.Buffer
+	_, err := rec.WriteTo(&b)
+	require.NoError(t, err)
+
+	f := Replay(&b)
+
+	w := httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?cols=goroutine", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+	assert.NotContains(t, w.Body.String(), "HeapAlloc")
+	assert.Contains(t, w.Body.String(), "deploy")
+	assert.Contains(t, w.Body.String(), "GOMAXPROCS=")
+
+	w = httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=json", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var rs []Record
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rs))
+	assert.Len(t, rs, len(rec.Records()))
+
+	// the record omits the memoryinfo group of the header
+	partial := `{"version":1,"columns":[{"group":"pprof","name":"goroutine"},{"group":"memoryinfo","name":"RSS","unit":"bytes"}]}
+{"time":"2021-10-01T12:00:00Z","pprof":{"goroutine":12}}`
+
+	w = httptest.NewRecorder()
+	Replay(strings.NewReader(partial))(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), ".RSS")
+
+	hostile := `{"version":1,"columns":[{"group":"<svg onload=alert(1)>","name":"<img src=x onerror=alert(1)>"}]}
+{"time":"2021-10-01T12:00:00Z","samples":{"<svg onload=alert(1)>":[{"name":"<img src=x onerror=alert(1)>","value":1}]}}`
+
+	w = httptest.NewRecorder()
+	Replay(strings.NewReader(hostile))(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "<img")
+	assert.NotContains(t, w.Body.String(), "<svg onload")
+	assert.Contains(t, w.Body.String(), "&lt;img src=x onerror=alert(1)&gt;")
+
+	w = httptest.NewRecorder()
+	Replay(strings.NewReader(`{"version":0}`))(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080", http.NoBody))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "unsupported version")
+}
@@ -0,0 +1,148 @@
+package pprofrec
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// ring is a fixed-size buffer of records that overwrites the oldest record once it is full.
+// The oldest records are dropped as well if the estimated size of the records exceeds maxBytes.
+// It is safe for concurrent use.
+type ring struct {
+	mu    sync.RWMutex
+	rs    []Record
+	start int
+	n     int
+
+	maxBytes int
+	bytes    int
+	sizes    []int
+}
+
+// newRing returns a ring of size records whose estimated size is bounded by maxBytes, it is unbounded if zero.
+func newRing(size int, maxBytes int) *ring {
+	return &ring{rs: make([]Record, size), maxBytes: maxBytes, sizes: make([]int, size)}
+}
+
+// push appends r and drops the oldest record if the ring is full,
+// or the oldest records until r fits into maxBytes.
+func (b *ring) push(r Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.rs) == 0 {
+		return
+	}
+
+	size := getRecordSize(r)
+	for b.maxBytes > 0 && b.n > 0 && b.bytes+size > b.maxBytes {
+		b.bytes -= b.sizes[b.start]
+		b.rs[b.start] = Record{}
+		b.start = (b.start + 1) % len(b.rs)
+		b.n--
+	}
+
+	if b.n < len(b.rs) {
+		i := (b.start + b.n) % len(b.rs)
+		b.rs[i] = r
+		b.sizes[i] = size
+		b.bytes += size
+		b.n++
+
+		return
+	}
+
+	b.bytes += size - b.sizes[b.start]
+	b.rs[b.start] = r
+	b.sizes[b.start] = size
+	b.start = (b.start + 1) % len(b.rs)
+}
+
+// snapshot returns a copy of the records, ordered from oldest to latest.
+func (b *ring) snapshot() []Record {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	rs := make([]Record, b.n)
+	end := b.start + b.n
+	if end > len(b.rs) {
+		end = len(b.rs)
+	}
+	n := copy(rs, b.rs[b.start:end])
+	copy(rs[n:], b.rs[:b.n-n])
+
+	return rs
+}
+
+// latest returns the latest record, ok is false if the ring is empty.
+func (b *ring) latest() (r Record, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.n == 0 {
+		return
+	}
+
+	return b.rs[(b.start+b.n-1)%len(b.rs)], true
+}
+
+// getRecordSize estimates the memory used by r in bytes, strings and maps are accounted for by their contents
+// and an approximate overhead per entry.
+func getRecordSize(r Record) (n int) {
+	const entry = int(unsafe.Sizeof("")) + 8
+
+	n = int(unsafe.Sizeof(r))
+
+	if r.MemoryInfo != nil {
+		n += int(unsafe.Sizeof(*r.MemoryInfo))
+	}
+	if r.CPUTimes != nil {
+		n += int(unsafe.Sizeof(*r.CPUTimes)) + len(r.CPUTimes.CPU)
+	}
+	if r.IOCounters != nil {
+		n += int(unsafe.Sizeof(*r.IOCounters))
+	}
+	if r.NumCtxSwitches != nil {
+		n += int(unsafe.Sizeof(*r.NumCtxSwitches))
+	}
+	if r.PageFaults != nil {
+		n += int(unsafe.Sizeof(*r.PageFaults))
+	}
+	if r.MemoryLimit != nil {
+		n += int(unsafe.Sizeof(*r.MemoryLimit))
+	}
+	if r.Win32 != nil {
+		n += int(unsafe.Sizeof(*r.Win32))
+	}
+	if r.SchedLatencies != nil {
+		n += int(unsafe.Sizeof(*r.SchedLatencies))
+	}
+	if r.GCPauses != nil {
+		n += int(unsafe.Sizeof(*r.GCPauses))
+	}
+	if r.Rates != nil {
+		n += int(unsafe.Sizeof(*r.Rates))
+	}
+	if r.Overhead != nil {
+		n += int(unsafe.Sizeof(*r.Overhead))
+	}
+
+	for name := range r.RuntimeMetrics {
+		n += entry + len(name)
+	}
+
+	for name, ss := range r.Samples {
+		n += entry + len(name) + int(unsafe.Sizeof(ss))
+		for _, s := range ss {
+			n += int(unsafe.Sizeof(s)) + len(s.Name) + len(s.Unit)
+		}
+	}
+
+	for _, a := range r.Annotations {
+		n += int(unsafe.Sizeof(a)) + len(a.Label)
+	}
+
+	// tags are shared between records and thus not accounted
+
+	return
+}
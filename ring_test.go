@@ -0,0 +1,66 @@
+package pprofrec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	b := newRing(3, 0)
+
+	_, ok := b.latest()
+	assert.False(t, ok)
+	assert.Empty(t, b.snapshot())
+
+	ts := time.Now()
+	for i := 0; i < 5; i++ {
+		b.push(Record{Time: ts.Add(time.Duration(i) * time.Second)})
+
+		latest, ok := b.latest()
+		assert.True(t, ok)
+		assert.Equal(t, ts.Add(time.Duration(i)*time.Second), latest.Time)
+	}
+
+	rs := b.snapshot()
+	assert.Len(t, rs, 3)
+	for i, r := range rs {
+		assert.Equal(t, ts.Add(time.Duration(i+2)*time.Second), r.Time)
+	}
+}
+
+func TestRingMaxBytes(t *testing.T) {
+	size := getRecordSize(Record{})
+	b := newRing(10, 3*size)
+
+	ts := time.Now()
+	for i := 0; i < 5; i++ {
+		b.push(Record{Time: ts.Add(time.Duration(i) * time.Second)})
+	}
+
+	rs := b.snapshot()
+	assert.Len(t, rs, 3)
+	assert.Equal(t, ts.Add(2*time.Second), rs[0].Time)
+	assert.Equal(t, 3*size, b.bytes)
+
+	// a larger record drops as many of the oldest records as needed to fit
+	b.push(Record{Time: ts.Add(5 * time.Second), Annotations: []Annotation{{Label: strings.Repeat("x", size)}}})
+
+	rs = b.snapshot()
+	assert.Len(t, rs, 1)
+	assert.Equal(t, ts.Add(5*time.Second), rs[0].Time)
+}
+
+func TestGetRecordSize(t *testing.T) {
+	empty := getRecordSize(Record{})
+	assert.NotZero(t, empty)
+
+	r := Record{
+		RuntimeMetrics: map[string]float64{"/gc/cycles/total:gc-cycles": 1},
+		Samples:        map[string][]Sample{"queue": {{Name: "depth", Value: 1}}},
+		SchedLatencies: &SchedLatencies{},
+	}
+	assert.True(t, getRecordSize(r) > empty+len("/gc/cycles/total:gc-cycles")+len("queue")+len("depth"))
+}
@@ -0,0 +1,218 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteStats aggregates the resource usage of requests per route, so that runtime symptoms can be
+// connected to endpoints. The zero value is ready to use.
+// Allocations are read from the process wide counters, so that they include the allocations of
+// concurrent requests, and require go1.16.
+type RouteStats struct {
+	// Route returns the route a request is aggregated under, defaults to its method and path.
+	Route func(r *http.Request) string
+	// MaxRoutes bounds the number of aggregated routes, further routes are aggregated under other, defaults to 1000.
+	MaxRoutes int
+	// OnError is called with failures to render the routes, they are logged if nil.
+	OnError func(error)
+
+	mu     sync.Mutex
+	routes map[string]*RouteStat
+}
+
+// RouteStat is the aggregated resource usage of the requests of a route.
+type RouteStat struct {
+	Route    string `json:"route"`
+	Requests int64  `json:"requests"`
+	// Duration is the total duration of the requests.
+	Duration    time.Duration `json:"duration"`
+	MaxDuration time.Duration `json:"maxDuration"`
+	// Allocs is the total number of heap objects allocated while serving the requests.
+	Allocs uint64 `json:"allocs"`
+	// AllocBytes is the total number of bytes allocated while serving the requests.
+	AllocBytes uint64 `json:"allocBytes"`
+	// Goroutines is the sum of how many goroutines each request left behind,
+	// a growing sum points at a route that leaks goroutines.
+	Goroutines int64 `json:"goroutines"`
+}
+
+// otherRoute aggregates the requests of routes beyond MaxRoutes.
+const otherRoute = "other"
+
+// DefaultRouteStats aggregates the requests served via Middleware.
+var DefaultRouteStats = &RouteStats{}
+
+// Middleware records the resource usage of the requests served by next in DefaultRouteStats.
+func Middleware(next http.Handler) http.Handler {
+	return DefaultRouteStats.Middleware(next)
+}
+
+// Middleware records the resource usage of the requests served by next.
+func (s *RouteStats) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := s.Observe(s.getRoute(r))
+		defer done()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Observe starts measuring a request of route and returns a function that records its resource usage
+// once it has been served, e.g. to record requests of servers other than net/http.
+func (s *RouteStats) Observe(route string) (done func()) {
+	goroutines := runtime.NumGoroutine()
+	objects, bytes := readAllocs()
+	start := time.Now()
+
+	return func() {
+		d := time.Since(start)
+		currentObjects, currentBytes := readAllocs()
+
+		s.add(route, d, currentObjects-objects, currentBytes-bytes, runtime.NumGoroutine()-goroutines)
+	}
+}
+
+func (s *RouteStats) getRoute(r *http.Request) string {
+	if s.Route != nil {
+		return s.Route(r)
+	}
+
+	return r.Method + " " + r.URL.Path
+}
+
+func (s *RouteStats) add(route string, d time.Duration, allocs uint64, allocBytes uint64, goroutines int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.routes == nil {
+		s.routes = map[string]*RouteStat{}
+	}
+
+	maxRoutes := s.MaxRoutes
+	if maxRoutes == 0 {
+		maxRoutes = 1000
+	}
+
+	rs, ok := s.routes[route]
+	if !ok && len(s.routes) >= maxRoutes {
+		route = otherRoute
+		rs, ok = s.routes[route]
+	}
+	if !ok {
+		rs = &RouteStat{Route: route}
+		s.routes[route] = rs
+	}
+
+	rs.Requests++
+	rs.Duration += d
+	if d > rs.MaxDuration {
+		rs.MaxDuration = d
+	}
+	rs.Allocs += allocs
+	rs.AllocBytes += allocBytes
+	rs.Goroutines += int64(goroutines)
+}
+
+// Stats returns a copy of the aggregates ordered by their total duration, the longest first.
+func (s *RouteStats) Stats() []RouteStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]RouteStat, 0, len(s.routes))
+	for _, rs := range s.routes {
+		stats = append(stats, *rs)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Duration != stats[j].Duration {
+			return stats[i].Duration > stats[j].Duration
+		}
+
+		return stats[i].Route < stats[j].Route
+	})
+
+	return stats
+}
+
+// Routes responds with the aggregates of s as a html table of the means per request,
+// or with the totals as JSON if the request specifies ?format=json or accepts application/json.
+func Routes(s *RouteStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(s.OnError, r)
+
+		switch getFormat(r) {
+		case formatHTML:
+		case formatJSON:
+			w.Header().Set("Content-Type", "application/json")
+
+			err := json.NewEncoder(w).Encode(s.Stats())
+			if err != nil {
+				reportError(s.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+			}
+
+			return
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		_, err := w.Write([]byte(getRoutesHTML(s.Stats())))
+		if err != nil {
+			reportError(s.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
+
+func getRoutesHTML(stats []RouteStat) string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+	<style>
+		body {
+			font-family: Courier, monospace;
+			font-size: 13px;
+			margin: 10px;
+		}
+
+		th, td {
+			padding-right: 20px;
+			text-align: left;
+		}
+	</style>
+	<title>pprofrec routes</title>
+</head>
+<body>
+	<table>
+		<tr><th>route</th><th>requests</th><th>duration</th><th>max duration</th><th>allocs</th><th>alloc bytes</th><th>goroutines</th></tr>
+`)
+
+	for _, rs := range stats {
+		n := rs.Requests
+		if n == 0 {
+			n = 1
+		}
+
+		fmt.Fprintf(&b, "\t\t<tr><td>%s</td><td>%d</td><td>%v</td><td>%v</td><td>%d</td><td>", html.EscapeString(rs.Route), rs.Requests, rs.Duration/time.Duration(n), rs.MaxDuration, rs.Allocs/uint64(n))
+		_, _ = writeHumanBytes(&b, int64(rs.AllocBytes/uint64(n)))
+		fmt.Fprintf(&b, "</td><td>%+d</td></tr>\n", rs.Goroutines)
+	}
+
+	b.WriteString(`	</table>
+</body>
+</html>
+`)
+
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteStatsMiddleware(t *testing.T) {
+	s := &RouteStats{MaxRoutes: 2}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/leak" {
+			go func() { <-done }()
+		}
+
+		_, _ = w.Write(make([]byte, 1024))
+	}))
+
+	for _, path := range []string{"/a", "/a", "/leak", "/b", "/c"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, 1024, w.Body.Len())
+	}
+
+	stats := s.Stats()
+	require.Len(t, stats, 3)
+
+	byRoute := map[string]RouteStat{}
+	for _, rs := range stats {
+		byRoute[rs.Route] = rs
+		assert.True(t, rs.MaxDuration > 0)
+		assert.True(t, rs.Duration >= rs.MaxDuration)
+	}
+	assert.Equal(t, int64(2), byRoute["GET /a"].Requests)
+	assert.Equal(t, int64(1), byRoute["GET /leak"].Requests)
+	assert.Equal(t, int64(1), byRoute["GET /leak"].Goroutines)
+	assert.Equal(t, int64(2), byRoute[otherRoute].Requests)
+}
+
+func TestRouteStatsRoute(t *testing.T) {
+	s := &RouteStats{Route: func(r *http.Request) string { return "users" }}
+	s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	stats := s.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, "users", stats[0].Route)
+}
+
+func TestRoutes(t *testing.T) {
+	s := &RouteStats{}
+	s.add("GET /a", 2*time.Millisecond, 10, 2048, 1)
+	s.add("GET /a", 4*time.Millisecond, 30, 4096, 0)
+	s.add("GET /<b>", time.Second, 0, 0, -1)
+
+	w := httptest.NewRecorder()
+	Routes(s)(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "<tr><td>GET /a</td><td>2</td><td>3ms</td><td>4ms</td><td>20</td><td>"+humanBytes(3072)+"</td><td>+1</td></tr>")
+	assert.Contains(t, w.Body.String(), "<tr><td>GET /&lt;b&gt;</td><td>1</td><td>1s</td><td>1s</td><td>0</td><td>"+humanBytes(0)+"</td><td>-1</td></tr>")
+
+	w = httptest.NewRecorder()
+	Routes(s)(w, httptest.NewRequest("GET", "/?format=json", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var stats []RouteStat
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, []RouteStat{
+		{Route: "GET /<b>", Requests: 1, Duration: time.Second, MaxDuration: time.Second, Goroutines: -1},
+		{Route: "GET /a", Requests: 2, Duration: 6 * time.Millisecond, MaxDuration: 4 * time.Millisecond, Allocs: 40, AllocBytes: 6144, Goroutines: 1},
+	}, stats)
+
+	w = httptest.NewRecorder()
+	Routes(s)(w, httptest.NewRequest("GET", "/?format=csv", nil))
+	assert.Equal(t, 400, w.Code)
+}
+
+func humanBytes(n int64) string {
+	return string(appendHumanBytes(nil, n))
+}
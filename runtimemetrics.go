@@ -0,0 +1,102 @@
+//go:build go1.16
+// +build go1.16
+
+package pprofrec
+
+import (
+	"runtime/metrics"
+	"strings"
+	"sync"
+)
+
+var (
+	runtimeMetricsOnce sync.Once
+	runtimeMetricsDesc []metrics.Description
+)
+
+// getRuntimeMetricsDesc discovers the runtime/metrics that can be recorded.
+func getRuntimeMetricsDesc() []metrics.Description {
+	runtimeMetricsOnce.Do(func() {
+		for _, d := range metrics.All() {
+			switch d.Kind {
+			case metrics.KindUint64, metrics.KindFloat64, metrics.KindFloat64Histogram:
+				runtimeMetricsDesc = append(runtimeMetricsDesc, d)
+			}
+		}
+	})
+
+	return runtimeMetricsDesc
+}
+
+// readRuntimeMetrics reads the values of the given runtime/metrics.
+// Histograms are read as their total number of observations.
+func readRuntimeMetrics(names []string) map[string]float64 {
+	if len(names) == 0 {
+		return nil
+	}
+
+	samples := make([]metrics.Sample, len(names))
+	for i := range names {
+		samples[i].Name = names[i]
+	}
+	metrics.Read(samples)
+
+	values := make(map[string]float64, len(samples))
+	for _, s := range samples {
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			values[s.Name] = float64(s.Value.Uint64())
+		case metrics.KindFloat64:
+			values[s.Name] = s.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			var n uint64
+			for _, c := range s.Value.Float64Histogram().Counts {
+				n += c
+			}
+			values[s.Name] = float64(n)
+		}
+	}
+
+	return values
+}
+
+// getRuntimeMetricsColumns returns a column per available runtime/metrics metric.
+func getRuntimeMetricsColumns() (cols []column) {
+	for _, d := range getRuntimeMetricsDesc() {
+		name := d.Name
+
+		u := unitCount
+		if d.Kind != metrics.KindFloat64Histogram {
+			switch name[strings.LastIndex(name, ":")+1:] {
+			case "bytes":
+				u = unitBytes
+			case "seconds", "cpu-seconds":
+				u = unitDuration
+			}
+		}
+
+		value := func(r Record) float64 { return r.RuntimeMetrics[name] }
+		if u == unitDuration {
+			value = func(r Record) float64 { return seconds(r.RuntimeMetrics[name]) }
+		}
+
+		cols = append(cols, column{group: runtimeMetricsGroup, name: name, unit: u, value: value})
+	}
+
+	return
+}
+
+// readAllocs reads the cumulative number and size of heap allocations.
+func readAllocs() (objects uint64, bytes uint64) {
+	samples := []metrics.Sample{{Name: "/gc/heap/allocs:objects"}, {Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(samples)
+
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		objects = samples[0].Value.Uint64()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		bytes = samples[1].Value.Uint64()
+	}
+
+	return
+}
@@ -0,0 +1,19 @@
+//go:build !go1.16
+// +build !go1.16
+
+package pprofrec
+
+// readRuntimeMetrics is a no-op as runtime/metrics requires go1.16.
+func readRuntimeMetrics(names []string) map[string]float64 {
+	return nil
+}
+
+// getRuntimeMetricsColumns is a no-op as runtime/metrics requires go1.16.
+func getRuntimeMetricsColumns() []column {
+	return nil
+}
+
+// readAllocs is a no-op as runtime/metrics requires go1.16.
+func readAllocs() (objects uint64, bytes uint64) {
+	return 0, 0
+}
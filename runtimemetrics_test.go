@@ -0,0 +1,26 @@
+//go:build go1.16
+// +build go1.16
+
+package pprofrec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRuntimeMetrics(t *testing.T) {
+	values := readRuntimeMetrics([]string{"/memory/classes/heap/objects:bytes", "/sched/goroutines:goroutines"})
+	assert.NotZero(t, values["/memory/classes/heap/objects:bytes"])
+	assert.NotZero(t, values["/sched/goroutines:goroutines"])
+
+	units := map[string]unit{}
+	for _, col := range getRuntimeMetricsColumns() {
+		units[col.name] = col.unit
+	}
+	require.Contains(t, units, "/memory/classes/heap/objects:bytes")
+	assert.Equal(t, unitBytes, units["/memory/classes/heap/objects:bytes"])
+	require.Contains(t, units, "/sched/latencies:seconds")
+	assert.Equal(t, unitCount, units["/sched/latencies:seconds"])
+}
@@ -0,0 +1,86 @@
+//go:build go1.16
+// +build go1.16
+
+package pprofrec
+
+import (
+	"math"
+	"runtime/metrics"
+)
+
+const schedLatenciesMetric = "/sched/latencies:seconds"
+
+// schedLatenciesSupported reports whether the runtime provides the scheduler latency histogram, which requires go1.17.
+func schedLatenciesSupported() bool {
+	for _, d := range getRuntimeMetricsDesc() {
+		if d.Name == schedLatenciesMetric {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readSchedLatencies reads the scheduler latency histogram and returns its percentiles
+// over the observations since previous, the counts of the previous read.
+func readSchedLatencies(previous []uint64) (s SchedLatencies, counts []uint64) {
+	samples := []metrics.Sample{{Name: schedLatenciesMetric}}
+	metrics.Read(samples)
+
+	if samples[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return
+	}
+
+	h := samples[0].Value.Float64Histogram()
+
+	counts = make([]uint64, len(h.Counts))
+	copy(counts, h.Counts)
+
+	delta := make([]uint64, len(h.Counts))
+	for i, c := range h.Counts {
+		delta[i] = c
+		if i < len(previous) {
+			delta[i] -= previous[i]
+		}
+	}
+
+	s.P50 = getHistogramQuantile(delta, h.Buckets, 0.5)
+	s.P99 = getHistogramQuantile(delta, h.Buckets, 0.99)
+
+	return
+}
+
+// getHistogramQuantile returns the upper boundary of the bucket that contains the q-quantile
+// of the observations in counts, the lower boundary if the bucket is unbounded. It returns zero without observations.
+// The boundaries of counts[i] are buckets[i] and buckets[i+1].
+func getHistogramQuantile(counts []uint64, buckets []float64, q float64) float64 {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var n uint64
+	for i, c := range counts {
+		n += c
+		if n < rank {
+			continue
+		}
+
+		if math.IsInf(buckets[i+1], 1) {
+			return buckets[i]
+		}
+
+		return buckets[i+1]
+	}
+
+	return 0
+}
@@ -0,0 +1,14 @@
+//go:build !go1.16
+// +build !go1.16
+
+package pprofrec
+
+// schedLatenciesSupported returns false as runtime/metrics requires go1.16.
+func schedLatenciesSupported() bool {
+	return false
+}
+
+// readSchedLatencies is a no-op as runtime/metrics requires go1.16.
+func readSchedLatencies(previous []uint64) (SchedLatencies, []uint64) {
+	return SchedLatencies{}, nil
+}
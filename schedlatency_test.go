@@ -0,0 +1,54 @@
+//go:build go1.16
+// +build go1.16
+
+package pprofrec
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHistogramQuantile(t *testing.T) {
+	buckets := []float64{math.Inf(-1), 1, 2, 4, math.Inf(1)}
+
+	assert.Equal(t, float64(0), getHistogramQuantile([]uint64{0, 0, 0, 0}, buckets, 0.5))
+	assert.Equal(t, float64(2), getHistogramQuantile([]uint64{0, 50, 49, 1}, buckets, 0.5))
+	assert.Equal(t, float64(4), getHistogramQuantile([]uint64{0, 50, 49, 1}, buckets, 0.99))
+	assert.Equal(t, float64(4), getHistogramQuantile([]uint64{0, 50, 40, 10}, buckets, 0.99))
+	assert.Equal(t, float64(1), getHistogramQuantile([]uint64{1, 0, 0, 0}, buckets, 0.5))
+}
+
+func TestReadSchedLatencies(t *testing.T) {
+	if !schedLatenciesSupported() {
+		t.Skip("the scheduler latency histogram requires go1.17")
+	}
+
+	_, counts := readSchedLatencies(nil)
+	require.NotEmpty(t, counts)
+
+	for i := 0; i < 100; i++ {
+		runtime.Gosched()
+	}
+
+	s, _ := readSchedLatencies(counts)
+	assert.True(t, s.P50 <= s.P99)
+}
+
+func TestSamplerSchedLatencies(t *testing.T) {
+	if !schedLatenciesSupported() {
+		t.Skip("the scheduler latency histogram requires go1.17")
+	}
+
+	s := newSampler(context.Background(), nil, nil, Columns{Include: []string{"schedlatencies"}})
+	require.Len(t, s.cols, 2)
+
+	r := s.getRecord(context.Background())
+	require.NotNil(t, r.SchedLatencies)
+	assert.NotEmpty(t, s.schedLatencies)
+	assert.Equal(t, seconds(r.SchedLatencies.P99), s.cols[1].value(r))
+}
@@ -0,0 +1,67 @@
+package pprofrec
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sink receives every record after it has been recorded, e.g. to forward it to a time series database.
+// Write is called from a goroutine per sink, records are dropped while a sink falls behind.
+// Sinks that buffer records, e.g. PostgresSink, are flushed once the recorder stops if they implement Flush.
+type Sink interface {
+	// Write receives the record along with the values of the recorded columns.
+	Write(ctx context.Context, r Record, ms []Metric) error
+}
+
+// flusher is implemented by sinks that buffer records.
+type flusher interface {
+	// Flush writes the buffered records.
+	Flush(ctx context.Context) error
+}
+
+// Metric is the value of a recorded column as passed to sinks. Sinks that write metrics instead of records,
+// e.g. InfluxSink, StatsDSink and PostgresSink, are keyed by the qualified column names, which are not versioned by SchemaVersion.
+type Metric struct {
+	// Group names the group of the column, e.g. pprof, memstats or the name of a collector.
+	Group string
+	// Sample holds the name of the column and its value,
+	// durations are given in seconds and times in unix seconds.
+	Sample
+}
+
+// getMetrics returns the values of cols in r.
+func getMetrics(cols []column, r Record) []Metric {
+	ms := make([]Metric, len(cols))
+	for i, col := range cols {
+		m := Metric{Group: col.group.name, Sample: Sample{Name: col.name, Value: col.value(r), Unit: getSampleUnit(col.unit)}}
+
+		// durations and times are recorded in nanoseconds
+		if m.Unit == UnitSeconds || m.Unit == UnitTime {
+			m.Value /= 1e9
+		}
+
+		ms[i] = m
+	}
+
+	return ms
+}
+
+// drain writes the records received from rs to s until rs is closed, then it flushes s if s is a flusher.
+func drain(s Sink, cols []column, rs <-chan Record, onError func(error)) {
+	for r := range rs {
+		err := s.Write(context.Background(), r, getMetrics(cols, r))
+		if err != nil {
+			reportError(onError, fmt.Errorf("failed to write record to sink: %w", err))
+		}
+	}
+
+	f, ok := s.(flusher)
+	if !ok {
+		return
+	}
+
+	err := f.Flush(context.Background())
+	if err != nil {
+		reportError(onError, fmt.Errorf("failed to flush sink: %w", err))
+	}
+}
@@ -0,0 +1,51 @@
+package pprofrec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chanSink chan []Metric
+
+func (s chanSink) Write(ctx context.Context, r Record, ms []Metric) error {
+	s <- ms
+
+	return nil
+}
+
+func TestGetMetrics(t *testing.T) {
+	cols := Columns{Include: []string{"goroutine", "HeapAlloc", "PauseTotalNs", "LastGC"}}.filter(getColumns(capabilities{}))
+
+	r := goroutineRecords(time.Now(), 5)[0]
+	r.MemStats.HeapAlloc = 1024
+	r.MemStats.PauseTotalNs = 1500000000
+	r.MemStats.LastGC = 2000000000
+
+	assert.Equal(t, []Metric{
+		{Group: "pprof", Sample: Sample{Name: "goroutine", Value: 5}},
+		{Group: "memstats", Sample: Sample{Name: "HeapAlloc", Value: 1024, Unit: UnitBytes}},
+		{Group: "memstats", Sample: Sample{Name: "LastGC", Value: 2, Unit: UnitTime}},
+		{Group: "memstats", Sample: Sample{Name: "PauseTotalNs", Value: 1.5, Unit: UnitSeconds}},
+	}, getMetrics(cols, r))
+}
+
+func TestRecorderSinks(t *testing.T) {
+	s := make(chanSink, 10)
+	rec := newTestRecorder(t, RecorderOpts{Frequency: 10 * time.Millisecond, Columns: Columns{Include: []string{"goroutine"}}, Sinks: []Sink{s}})
+
+	rec.Start(context.Background())
+	defer rec.Stop()
+
+	select {
+	case ms := <-s:
+		require.Len(t, ms, 1)
+		assert.Equal(t, "goroutine", ms[0].Name)
+		assert.NotZero(t, ms[0].Value)
+	case <-time.After(time.Second):
+		t.Fatal("sink did not receive a record")
+	}
+}
@@ -0,0 +1,92 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// SlackNotifier posts alerts as messages to a Slack incoming webhook.
+type SlackNotifier struct {
+	// URL is the URL of the incoming webhook.
+	URL string
+	// WindowURL links the messages back to the Window view if set, e.g. https://example.com/debug/pprof/window.
+	WindowURL string
+	// Template renders the text of the messages with a SlackMessage, defaults to DefaultSlackTemplate.
+	Template *template.Template
+	// Client sends the requests, defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// SlackMessage is the data the text of a Slack message is rendered with,
+// the alert and its strings are escaped for Slack's markup.
+type SlackMessage struct {
+	Alert
+	// Value and Threshold are formatted like the cells of the column, they are empty if the rule is an expression.
+	Value     string
+	Threshold string
+	// Sparkline charts the recent values of the column as block characters.
+	Sparkline string
+	WindowURL string
+}
+
+// DefaultSlackTemplate renders the rule, status and column of an alert with its value, a sparkline
+// of the recent values and a link to the Window view.
+var DefaultSlackTemplate = template.Must(template.New("slack").Parse(
+	"{{if eq .Status \"firing\"}}:rotating_light:{{else}}:white_check_mark:{{end}} *{{.Rule}}* {{.Status}}: `{{.Column}}`" +
+		"{{if .Value}} is {{.Value}}, threshold {{.Threshold}}{{end}}, since {{.Since.Format \"15:04:05\"}}" +
+		"{{if .Sparkline}}\n`{{.Sparkline}}`{{end}}" +
+		"{{if .WindowURL}}\n<{{.WindowURL}}|Open window>{{end}}",
+))
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Notify posts the alert rendered by the template to the URL.
+func (n SlackNotifier) Notify(ctx context.Context, a Alert) (err error) {
+	tmpl := n.Template
+	if tmpl == nil {
+		tmpl = DefaultSlackTemplate
+	}
+
+	var text strings.Builder
+	err = tmpl.Execute(&text, getSlackMessage(a, n.WindowURL))
+	if err != nil {
+		return
+	}
+
+	b, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: text.String()})
+	if err != nil {
+		return
+	}
+
+	return post(ctx, n.Client, n.URL, http.Header{"Content-Type": {"application/json"}}, b)
+}
+
+func getSlackMessage(a Alert, windowURL string) (m SlackMessage) {
+	m.Alert = a
+	m.Rule = slackEscaper.Replace(a.Rule)
+	m.Column = slackEscaper.Replace(a.Column)
+	m.WindowURL = windowURL
+
+	if len(a.Values) == 0 {
+		return
+	}
+
+	m.Value = formatAlertValue(a.unit, a.Value)
+	m.Threshold = formatAlertValue(a.unit, a.Threshold)
+	m.Sparkline = getTextSparkline(a.Values)
+
+	return
+}
+
+// formatAlertValue formats v like the summaries of the column.
+func formatAlertValue(u unit, v float64) string {
+	var b strings.Builder
+	_ = writeSummaryValue(&b, u, v)
+
+	return b.String()
+}
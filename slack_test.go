@@ -0,0 +1,58 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackNotifier(t *testing.T) {
+	texts := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var m struct {
+			Text string `json:"text"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&m)
+		assert.NoError(t, err)
+
+		texts <- m.Text
+	}))
+	defer srv.Close()
+
+	since := time.Date(2020, 1, 1, 15, 4, 5, 0, time.UTC)
+	n := SlackNotifier{URL: srv.URL, WindowURL: "https://example.com/debug/pprof/window"}
+
+	err := n.Notify(context.Background(), Alert{
+		Rule:      "heap",
+		Status:    AlertFiring,
+		Column:    "memstats.HeapAlloc",
+		Value:     2 << 30,
+		Threshold: 1 << 30,
+		Values:    []float64{0, 1 << 30, 2 << 30},
+		Since:     since,
+		unit:      unitBytes,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ":rotating_light: *heap* firing: `memstats.HeapAlloc` is "+formatAlertValue(unitBytes, 2<<30)+", threshold "+formatAlertValue(unitBytes, 1<<30)+", since 15:04:05\n"+
+		"`▁▄█`\n"+
+		"<https://example.com/debug/pprof/window|Open window>", <-texts)
+
+	err = n.Notify(context.Background(), Alert{Rule: "heap", Status: AlertResolved, Column: "HeapAlloc > 1GiB && goroutine > 10", Since: since})
+	require.NoError(t, err)
+	assert.Equal(t, ":white_check_mark: *heap* resolved: `HeapAlloc &gt; 1GiB &amp;&amp; goroutine &gt; 10`, since 15:04:05\n"+
+		"<https://example.com/debug/pprof/window|Open window>", <-texts)
+
+	n.Template = template.Must(template.New("").Parse("{{.Rule}} {{.Value}}"))
+	err = n.Notify(context.Background(), Alert{Rule: "goroutines", Value: 10, Values: []float64{10}})
+	require.NoError(t, err)
+	assert.Equal(t, "goroutines 10", <-texts)
+}
@@ -0,0 +1,59 @@
+package pprofrec
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// SlowRequestOpts configures SlowRequests.
+type SlowRequestOpts struct {
+	// Threshold is the duration above which a request is slow, defaults to 1 second.
+	Threshold time.Duration
+	// Log logs slow requests alongside the goroutines, heap and RSS of the latest record if set.
+	Log bool
+}
+
+// SlowRequests returns a middleware that annotates the recording of rec whenever a request exceeds
+// opts.Threshold, so that slow requests are marked next to the records of the runtime context they executed under.
+// The annotation is labeled with the method, path and duration of the request.
+func SlowRequests(rec *Recorder, opts SlowRequestOpts) func(next http.Handler) http.Handler {
+	if opts.Threshold == time.Duration(0) {
+		opts.Threshold = 1 * time.Second
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			next.ServeHTTP(w, r)
+
+			d := time.Since(start)
+			if d <= opts.Threshold {
+				return
+			}
+
+			a := rec.Annotate(fmt.Sprintf("slow request %v %v took %v", r.Method, r.URL.Path, d.Round(time.Millisecond)))
+
+			if !opts.Log {
+				return
+			}
+
+			latest, ok := rec.Latest()
+			if !ok {
+				log.Printf("pprofrec: %v", a.Label)
+
+				return
+			}
+
+			var rss uint64
+			if latest.MemoryInfo != nil {
+				rss = latest.MemoryInfo.RSS
+			}
+
+			log.Printf("pprofrec: %v: goroutines %v, heap %s, rss %s", a.Label, latest.Pprof.Goroutine,
+				appendHumanBytes(nil, int64(latest.MemStats.HeapAlloc)), appendHumanBytes(nil, int64(rss)))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowRequests(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	rec := newTestRecorder(t, RecorderOpts{Frequency: 10 * time.Millisecond})
+	rec.Start(context.Background())
+	defer rec.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	h := SlowRequests(rec, SlowRequestOpts{Threshold: 20 * time.Millisecond, Log: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	assert.Empty(t, rec.Annotations())
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	as := rec.Annotations()
+	require.Len(t, as, 1)
+	assert.Regexp(t, `^slow request GET /slow took \d+ms$`, as[0].Label)
+	assert.Regexp(t, `pprofrec: slow request GET /slow took \d+ms: goroutines \d+, heap .+, rss .+`, buf.String())
+}
@@ -0,0 +1,46 @@
+package pprofrec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSmapsRollup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeCgroupFiles(t, dir, map[string]string{
+		"smaps_rollup": "564b244f3000-7fff754b5000 ---p 00000000 00:00 0                          [rollup]\n" +
+			"Rss:                1324 kB\nPss:                 450 kB\nShared_Clean:       1172 kB\n" +
+			"Private_Clean:        48 kB\nPrivate_Dirty:       104 kB\nSwapPss:               0 kB\n",
+	})
+
+	s := readProcStatus(filepath.Join(dir, "smaps_rollup"))
+	assert.Equal(t, float64(1324*1024), s["Rss"])
+	assert.Equal(t, float64(450*1024), s["Pss"])
+	assert.Equal(t, float64(152*1024), s["Private_Clean"]+s["Private_Dirty"])
+	assert.Len(t, s, 6)
+}
+
+func TestSmapsCollector(t *testing.T) {
+	ss := SmapsCollector{}.Collect(context.Background())
+	require.Len(t, ss, 4)
+
+	_, err := os.Stat("/proc/self/smaps_rollup")
+	if runtime.GOOS != "linux" || err != nil {
+		return
+	}
+
+	assert.NotZero(t, ss[0].Value)
+	assert.NotZero(t, ss[1].Value)
+	assert.True(t, ss[1].Value <= ss[0].Value)
+	assert.True(t, ss[2].Value <= ss[1].Value)
+}
@@ -0,0 +1,104 @@
+package pprofrec
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	sparklineWidth  = 60
+	sparklineHeight = 12
+)
+
+// getSparklinePoints summarizes the values of col over rs into at most sparklineWidth points,
+// each point is the maximum of the records it covers so that spikes remain visible.
+func getSparklinePoints(col column, rs []Record) (ps []float64) {
+	buckets := len(rs)
+	if buckets > sparklineWidth {
+		buckets = sparklineWidth
+	}
+
+	ps = make([]float64, buckets)
+	for i := range ps {
+		ps[i] = math.Inf(-1)
+	}
+
+	for i, r := range rs {
+		b := i * buckets / len(rs)
+
+		v := col.value(r)
+		if v > ps[b] {
+			ps[b] = v
+		}
+	}
+
+	return
+}
+
+// writeSparkline writes an inline svg that plots the values of col over rs,
+// nothing is written if there are less than two records.
+func writeSparkline(w io.Writer, col column, rs []Record) (err error) {
+	if len(rs) < 2 {
+		return
+	}
+
+	ps := getSparklinePoints(col, rs)
+	if len(ps) < 2 {
+		return
+	}
+
+	lo, hi := ps[0], ps[0]
+	for _, p := range ps {
+		lo = math.Min(lo, p)
+		hi = math.Max(hi, p)
+	}
+
+	var b strings.Builder
+	for i, p := range ps {
+		y := float64(sparklineHeight) / 2
+		if hi > lo {
+			y = float64(sparklineHeight-1) - (p-lo)/(hi-lo)*float64(sparklineHeight-2)
+		}
+
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.Itoa(i * (sparklineWidth - 1) / (len(ps) - 1)))
+		b.WriteByte(',')
+		b.WriteString(strconv.FormatFloat(y, 'f', 1, 64))
+	}
+
+	_, err = fmt.Fprintf(w, `<svg class="tbl__sparkline" width="%d" height="%d"><polyline points="%s"/></svg>`, sparklineWidth, sparklineHeight, b.String())
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// getTextSparkline renders vs as a line of block characters scaled between their minimum and maximum,
+// for plain text such as chat messages.
+func getTextSparkline(vs []float64) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range vs {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	var b strings.Builder
+	for _, v := range vs {
+		i := 0
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(sparks)-1))
+		}
+
+		b.WriteRune(sparks[i])
+	}
+
+	return b.String()
+}
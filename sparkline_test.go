@@ -0,0 +1,63 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSparklinePoints(t *testing.T) {
+	col, ok := findColumn(getColumns(capabilities{}), "goroutine")
+	require.True(t, ok)
+
+	rs := goroutineRecords(time.Now(), 1, 5, 2)
+	assert.Equal(t, []float64{1, 5, 2}, getSparklinePoints(col, rs))
+
+	counts := make([]int, 3*sparklineWidth)
+	counts[7] = 100
+	ps := getSparklinePoints(col, goroutineRecords(time.Now(), counts...))
+	require.Len(t, ps, sparklineWidth)
+	assert.Equal(t, float64(100), ps[2])
+}
+
+func TestWriteSparkline(t *testing.T) {
+	col, ok := findColumn(getColumns(capabilities{}), "goroutine")
+	require.True(t, ok)
+
+	var b bytes.Buffer
+	require.NoError(t, writeSparkline(&b, col, goroutineRecords(time.Now(), 1)))
+	assert.Empty(t, b.String())
+
+	require.NoError(t, writeSparkline(&b, col, goroutineRecords(time.Now(), 1, 3, 2)))
+	assert.Equal(t, `<svg class="tbl__sparkline" width="60" height="12"><polyline points="0,11.0 29,1.0 59,6.0"/></svg>`, b.String())
+
+	b.Reset()
+	require.NoError(t, writeSparkline(&b, col, goroutineRecords(time.Now(), 2, 2)))
+	assert.Contains(t, b.String(), `points="0,6.0 59,6.0"`)
+}
+
+func TestWindowSparkline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := Window(ctx, WindowOpts{Window: time.Second, Frequency: 10 * time.Millisecond})
+
+	time.Sleep(50 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<svg class="tbl__sparkline"`)
+}
+
+func TestGetTextSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", getTextSparkline([]float64{1, 5, 10}))
+	assert.Equal(t, "▁▁", getTextSparkline([]float64{3, 3}))
+	assert.Equal(t, "", getTextSparkline(nil))
+}
@@ -0,0 +1,151 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// MetricStats holds statistics of a metric over a time range, bytes are given in bytes and durations in nanoseconds.
+type MetricStats struct {
+	// Metric is the qualified name of the metric, e.g. runtime.MemStats.HeapAlloc.
+	Metric string    `json:"metric"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Count is the number of records within the time range.
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Stddev float64 `json:"stddev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	// Slope is the change of the metric per second fitted by least squares, e.g. to detect leaks.
+	Slope float64 `json:"slope"`
+}
+
+// getStats returns the statistics of col over rs, the percentiles are the nearest rank.
+func getStats(col column, rs []Record) (s MetricStats) {
+	s.Metric = col.qualifiedName()
+	s.Count = len(rs)
+
+	if len(rs) == 0 {
+		return
+	}
+
+	s.From = rs[0].Time
+	s.To = rs[len(rs)-1].Time
+
+	vs := make([]float64, len(rs))
+	var sum float64
+	for i, r := range rs {
+		vs[i] = col.value(r)
+		sum += vs[i]
+	}
+	s.Mean = sum / float64(len(vs))
+
+	var variance float64
+	for _, v := range vs {
+		variance += (v - s.Mean) * (v - s.Mean)
+	}
+	s.Stddev = math.Sqrt(variance / float64(len(vs)))
+
+	// the slope is fitted on the seconds since the first record
+	var mx float64
+	for _, r := range rs {
+		mx += r.Time.Sub(s.From).Seconds()
+	}
+	mx /= float64(len(rs))
+
+	var sxx, sxy float64
+	for i, r := range rs {
+		x := r.Time.Sub(s.From).Seconds() - mx
+		sxx += x * x
+		sxy += x * (vs[i] - s.Mean)
+	}
+	if sxx > 0 {
+		s.Slope = sxy / sxx
+	}
+
+	sort.Float64s(vs)
+	s.Min = vs[0]
+	s.Max = vs[len(vs)-1]
+	s.P50 = getPercentile(vs, 0.5)
+	s.P90 = getPercentile(vs, 0.9)
+	s.P95 = getPercentile(vs, 0.95)
+	s.P99 = getPercentile(vs, 0.99)
+
+	return
+}
+
+// limitTimeRange returns the records of rs recorded between from and to inclusively, zero times are unbounded.
+func limitTimeRange(rs []Record, from time.Time, to time.Time) (limited []Record) {
+	for _, r := range rs {
+		if !from.IsZero() && r.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && r.Time.After(to) {
+			continue
+		}
+
+		limited = append(limited, r)
+	}
+
+	return
+}
+
+// Stats responds with the MetricStats of the metric selected by ?metric=HeapAlloc over the records of rec as JSON,
+// for automated regression checks in load tests. The metric can be qualified by its group, e.g. ?metric=memstats.HeapAlloc.
+// The records can be limited to a time range by ?from=15:04:05&to=15:34:05, times can also be given in RFC 3339.
+func Stats(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		metric := r.URL.Query().Get("metric")
+		if metric == "" {
+			http.Error(w, "metric is required", http.StatusBadRequest)
+
+			return
+		}
+
+		col, ok := findColumn(rec.s.cols, metric)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown metric: %v", metric), http.StatusBadRequest)
+
+			return
+		}
+
+		rs := rec.Records()
+
+		var ref time.Time
+		if len(rs) > 0 {
+			ref = rs[len(rs)-1].Time
+		}
+
+		from, _, err := getQueryTime(r, "from", ref)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		to, _, err := getQueryTime(r, "to", ref)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(getStats(col, limitTimeRange(rs, from, to)))
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
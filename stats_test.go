@@ -0,0 +1,71 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStats(t *testing.T) {
+	col, ok := findColumn(getColumns(capabilities{}), "HeapAlloc")
+	require.True(t, ok)
+
+	var rs []Record
+	for i := 0; i < 5; i++ {
+		rs = append(rs, Record{Time: time.Unix(int64(i), 0), MemStats: MemStats{HeapAlloc: uint64(100 + 10*i)}})
+	}
+
+	assert.Equal(t, MetricStats{Metric: "runtime.MemStats.HeapAlloc"}, getStats(col, nil))
+
+	s := getStats(col, rs)
+	assert.Equal(t, "runtime.MemStats.HeapAlloc", s.Metric)
+	assert.Equal(t, 5, s.Count)
+	assert.Equal(t, time.Unix(0, 0), s.From)
+	assert.Equal(t, time.Unix(4, 0), s.To)
+	assert.Equal(t, 100.0, s.Min)
+	assert.Equal(t, 140.0, s.Max)
+	assert.Equal(t, 120.0, s.Mean)
+	assert.InDelta(t, 14.142, s.Stddev, 0.001)
+	assert.Equal(t, 120.0, s.P50)
+	assert.Equal(t, 140.0, s.P99)
+	assert.InDelta(t, 10, s.Slope, 0.001)
+}
+
+func TestLimitTimeRange(t *testing.T) {
+	rs := []Record{{Time: time.Unix(1, 0)}, {Time: time.Unix(2, 0)}, {Time: time.Unix(3, 0)}}
+
+	assert.Equal(t, rs, limitTimeRange(rs, time.Time{}, time.Time{}))
+	assert.Equal(t, rs[1:], limitTimeRange(rs, time.Unix(2, 0), time.Time{}))
+	assert.Equal(t, rs[:2], limitTimeRange(rs, time.Time{}, time.Unix(2, 0)))
+}
+
+func TestStats(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{Frequency: 10 * time.Millisecond})
+	rec.Start(context.Background())
+	defer rec.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Stats(rec)(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window/stats?metric=goroutine", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var s MetricStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&s))
+	assert.Equal(t, "pprof.Lookup.goroutine", s.Metric)
+	assert.NotZero(t, s.Count)
+	assert.NotZero(t, s.Max)
+
+	for _, path := range []string{"/debug/pprof/window/stats", "/debug/pprof/window/stats?metric=unknown", "/debug/pprof/window/stats?metric=goroutine&from=x"} {
+		w = httptest.NewRecorder()
+		Stats(rec)(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
@@ -0,0 +1,104 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// statsdPacketSize bounds the size of a packet so that it is not fragmented on common networks.
+const statsdPacketSize = 1432
+
+// StatsDSink emits the values of the recorded columns as StatsD gauges over UDP, once per record.
+// Negative values are emitted by resetting the gauge to 0 first, as StatsD treats signed values as deltas.
+type StatsDSink struct {
+	// Addr is the address of the StatsD or DogStatsD agent, defaults to 127.0.0.1:8125.
+	Addr string
+	// Prefix is prepended to the gauge names, e.g. myapp results in myapp.memstats.HeapAlloc.
+	Prefix string
+	// Tags are appended to every gauge in the DogStatsD format, they are not supported by plain StatsD.
+	Tags map[string]string
+	// Columns selects the columns that are emitted, all recorded columns are emitted if empty.
+	Columns Columns
+}
+
+// Write emits the selected metrics as gauges.
+func (s StatsDSink) Write(ctx context.Context, r Record, ms []Metric) (err error) {
+	addr := s.Addr
+	if addr == "" {
+		addr = "127.0.0.1:8125"
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for _, p := range s.packets(ms) {
+		_, err = conn.Write(p)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+var statsdNameEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", " ", "_", "\n", "_")
+
+// packets returns the gauges of the selected metrics, one per line, split into packets of at most statsdPacketSize.
+func (s StatsDSink) packets(ms []Metric) (ps [][]byte) {
+	var tags string
+	if len(s.Tags) > 0 {
+		keys := make([]string, 0, len(s.Tags))
+		for k := range s.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for i, k := range keys {
+			keys[i] = k + ":" + s.Tags[k]
+		}
+		tags = "|#" + strings.Join(keys, ",")
+	}
+
+	var b bytes.Buffer
+	for _, m := range ms {
+		if !s.Columns.selects(m.Group, m.Name) {
+			continue
+		}
+
+		name := m.Group + "." + m.Name
+		if s.Prefix != "" {
+			name = s.Prefix + "." + name
+		}
+		name = statsdNameEscaper.Replace(name)
+		line := name + ":" + strconv.FormatFloat(m.Value, 'f', -1, 64) + "|g" + tags
+
+		// a signed value modifies the gauge instead of setting it, so negative values are set by resetting the gauge first,
+		// the reset is kept in the same packet so that it arrives along with the value
+		if m.Value < 0 {
+			line = name + ":0|g" + tags + "\n" + line
+		}
+
+		if b.Len() > 0 && b.Len()+1+len(line) > statsdPacketSize {
+			ps = append(ps, append([]byte(nil), b.Bytes()...))
+			b.Reset()
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+	}
+
+	if b.Len() > 0 {
+		ps = append(ps, b.Bytes())
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package pprofrec
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsDSinkPackets(t *testing.T) {
+	s := StatsDSink{Prefix: "myapp", Tags: map[string]string{"env": "prod", "az": "b"}, Columns: Columns{Exclude: []string{"threadcreate"}}}
+	ms := []Metric{
+		{Group: "pprof", Sample: Sample{Name: "goroutine", Value: 12}},
+		{Group: "pprof", Sample: Sample{Name: "threadcreate", Value: 8}},
+		{Group: "my queue", Sample: Sample{Name: "depth", Value: 0.5}},
+	}
+
+	ps := s.packets(ms)
+	require.Len(t, ps, 1)
+	assert.Equal(t, "myapp.pprof.goroutine:12|g|#az:b,env:prod\nmyapp.my_queue.depth:0.5|g|#az:b,env:prod", string(ps[0]))
+
+	ms = nil
+	for i := 0; i < 200; i++ {
+		ms = append(ms, Metric{Group: "memstats", Sample: Sample{Name: "HeapAlloc", Value: 1 << 30}})
+	}
+
+	ps = StatsDSink{}.packets(ms)
+	require.True(t, len(ps) > 1)
+	n := 0
+	for _, p := range ps {
+		assert.True(t, len(p) <= statsdPacketSize)
+		n += len(strings.Split(string(p), "\n"))
+	}
+	assert.Equal(t, 200, n)
+
+	ps = StatsDSink{Tags: map[string]string{"env": "prod"}}.packets([]Metric{{Group: "clock", Sample: Sample{Name: "skew", Value: -1.5}}})
+	require.Len(t, ps, 1)
+	assert.Equal(t, "clock.skew:0|g|#env:prod\nclock.skew:-1.5|g|#env:prod", string(ps[0]))
+}
+
+func TestStatsDSinkWrite(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := StatsDSink{Addr: conn.LocalAddr().String()}
+	require.NoError(t, s.Write(context.Background(), Record{}, []Metric{{Group: "pprof", Sample: Sample{Name: "goroutine", Value: 3}}}))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	b := make([]byte, statsdPacketSize)
+	n, _, err := conn.ReadFrom(b)
+	require.NoError(t, err)
+	assert.Equal(t, "pprof.goroutine:3|g", string(b[:n]))
+}
@@ -0,0 +1,102 @@
+package pprofrec
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StoreOpts configures checkpointing the recorded metrics to a file,
+// so they survive restarts of the process.
+type StoreOpts struct {
+	// Path defines the file the recorded metrics are checkpointed to and reloaded from on startup,
+	// checkpointing is disabled if empty.
+	Path string
+	// Interval defines at what interval the recorded metrics are checkpointed, defaults to 10s if zero.
+	Interval time.Duration
+}
+
+// storeVersion is incremented whenever the file format changes in an incompatible way.
+const storeVersion = 1
+
+type storeFile struct {
+	Version int
+	// SchemaVersion is the SchemaVersion of the records, files written before it was stored hold 0.
+	SchemaVersion int
+	Records       []Record
+}
+
+// saveRecords writes rs as gzipped gob to path, replacing the file atomically.
+func saveRecords(path string, rs []Record) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	zw := gzip.NewWriter(f)
+
+	err = gob.NewEncoder(zw).Encode(storeFile{Version: storeVersion, SchemaVersion: SchemaVersion, Records: rs})
+	if err != nil {
+		return
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return
+	}
+
+	err = f.Close()
+	if err != nil {
+		return
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// loadRecords reads the records written by saveRecords from path, a missing file yields no records.
+func loadRecords(path string) (rs []Record, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return
+	}
+
+	var sf storeFile
+	err = gob.NewDecoder(zr).Decode(&sf)
+	if err != nil {
+		return
+	}
+
+	if sf.Version != storeVersion {
+		return nil, fmt.Errorf("unsupported version: %v", sf.Version)
+	}
+
+	if sf.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version: %v", sf.SchemaVersion)
+	}
+
+	return sf.Records, nil
+}
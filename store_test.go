@@ -0,0 +1,116 @@
+package pprofrec
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoadRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "records.bin")
+
+	rs, err := loadRecords(path)
+	require.NoError(t, err)
+	assert.Empty(t, rs)
+
+	s := newSampler(context.Background(), nil, nil, Columns{})
+	want := []Record{s.getRecord(context.Background()), s.getRecord(context.Background())}
+
+	require.NoError(t, saveRecords(path, want))
+
+	rs, err = loadRecords(path)
+	require.NoError(t, err)
+	require.Len(t, rs, 2)
+	assert.True(t, want[1].Time.Equal(rs[1].Time))
+	assert.Equal(t, want[1].Pprof, rs[1].Pprof)
+	assert.Equal(t, want[1].MemStats, rs[1].MemStats)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	zw := gzip.NewWriter(f)
+	require.NoError(t, gob.NewEncoder(zw).Encode(storeFile{Version: storeVersion, SchemaVersion: SchemaVersion + 1}))
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+	_, err = loadRecords(path)
+	assert.EqualError(t, err, fmt.Sprintf("unsupported schema version: %v", SchemaVersion+1))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("garbage"), 0644))
+	_, err = loadRecords(path)
+	assert.Error(t, err)
+}
+
+func TestRecorderStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	opts := RecorderOpts{
+		Window:    time.Second,
+		Frequency: 10 * time.Millisecond,
+		Store:     StoreOpts{Path: filepath.Join(dir, "records.bin"), Interval: time.Hour},
+	}
+
+	rec := newTestRecorder(t, opts)
+	rec.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	rec.Stop()
+
+	rs := rec.Records()
+	require.NotEmpty(t, rs)
+
+	restored := newTestRecorder(t, opts).Records()
+	require.Len(t, restored, len(rs))
+	assert.True(t, rs[len(rs)-1].Time.Equal(restored[len(restored)-1].Time))
+}
+
+func TestRecorderStoreColumns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pprofrec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := StoreOpts{Path: filepath.Join(dir, "records.bin"), Interval: time.Hour}
+
+	rec := newTestRecorder(t, RecorderOpts{
+		Window:    time.Second,
+		Frequency: 10 * time.Millisecond,
+		Columns:   Columns{Exclude: []string{"memoryinfo", "gcpauses", "rates"}},
+		Store:     store,
+	})
+	rec.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	rec.Stop()
+	require.Nil(t, rec.Records()[0].MemoryInfo)
+
+	// restarted with the default columns
+	restored := newTestRecorder(t, RecorderOpts{Window: time.Second, Frequency: 10 * time.Millisecond, Store: store})
+	rs := restored.Records()
+	require.NotEmpty(t, rs)
+	assert.Equal(t, &process.MemoryInfoStat{}, rs[0].MemoryInfo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	Window(ctx, WindowOpts{Recorder: restored})(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
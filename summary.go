@@ -0,0 +1,156 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Summary holds statistics of a column over a window, bytes are given in bytes and durations in nanoseconds.
+type Summary struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+	P95 float64 `json:"p95"`
+}
+
+// getSummaries returns the summary of every column over rs keyed by the qualified column name,
+// times are skipped as their statistics are meaningless.
+func getSummaries(cols []column, rs []Record) map[string]Summary {
+	ss := make(map[string]Summary, len(cols))
+	if len(rs) == 0 {
+		return ss
+	}
+
+	vs := make([]float64, len(rs))
+	for _, col := range cols {
+		if col.unit == unitTime {
+			continue
+		}
+
+		for i, r := range rs {
+			vs[i] = col.value(r)
+		}
+
+		ss[col.qualifiedName()] = getSummary(vs)
+	}
+
+	return ss
+}
+
+// getSummary returns the summary of vs, the p95 is the nearest rank. vs is sorted in place.
+func getSummary(vs []float64) (s Summary) {
+	if len(vs) == 0 {
+		return
+	}
+
+	sort.Float64s(vs)
+
+	var sum float64
+	for _, v := range vs {
+		sum += v
+	}
+
+	s.Min = vs[0]
+	s.Max = vs[len(vs)-1]
+	s.Avg = sum / float64(len(vs))
+	s.P95 = getPercentile(vs, 0.95)
+
+	return
+}
+
+// getPercentile returns the nearest rank q-quantile of the sorted values vs, which must not be empty.
+func getPercentile(vs []float64, q float64) float64 {
+	rank := int(math.Ceil(q * float64(len(vs))))
+	if rank == 0 {
+		rank = 1
+	}
+
+	return vs[rank-1]
+}
+
+func writeJSONSummaries(w io.Writer, ss map[string]Summary) (err error) {
+	err = json.NewEncoder(w).Encode(ss)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// writeSummaries writes a footer row per statistic with the summary of every column,
+// cells of times are left empty.
+func writeSummaries(w io.Writer, cols []column, ss map[string]Summary, d display) (err error) {
+	stats := []struct {
+		name  string
+		value func(s Summary) float64
+	}{
+		{"min", func(s Summary) float64 { return s.Min }},
+		{"max", func(s Summary) float64 { return s.Max }},
+		{"avg", func(s Summary) float64 { return s.Avg }},
+		{"p95", func(s Summary) float64 { return s.P95 }},
+	}
+
+	for _, stat := range stats {
+		_, err = w.Write([]byte(`<tr class="tbl__row--summary"><td class="tbl__col1">` + stat.name))
+		if err != nil {
+			return
+		}
+
+		for _, col := range cols {
+			_, err = w.Write([]byte(`</td><td style="padding-left: 10px;">`))
+			if err != nil {
+				return
+			}
+
+			s, ok := ss[col.qualifiedName()]
+			if ok {
+				err = writeSummaryValue(w, d.getUnit(col.unit), stat.value(s))
+				if err != nil {
+					return
+				}
+			}
+
+			if d.cells() == 1 {
+				continue
+			}
+
+			_, err = w.Write([]byte(`</td><td>`))
+			if err != nil {
+				return
+			}
+		}
+
+		_, err = w.Write([]byte("</td></tr>"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// writeSummaryValue writes v formatted like the cells of the records.
+func writeSummaryValue(w io.Writer, u unit, v float64) (err error) {
+	switch u {
+	case unitBytes:
+		_, err = writeHumanBytes(w, int64(v))
+	case unitDuration:
+		_, err = w.Write([]byte(time.Duration(v).String()))
+	case unitPercent:
+		_, err = w.Write([]byte(strconv.FormatFloat(v, 'f', 2, 64) + " %"))
+	default:
+		if v != math.Trunc(v) {
+			_, err = w.Write([]byte(strconv.FormatFloat(v, 'f', 2, 64)))
+
+			break
+		}
+
+		_, err = w.Write([]byte(strconv.FormatFloat(v, 'f', -1, 64)))
+	}
+
+	return
+}
@@ -0,0 +1,68 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSummary(t *testing.T) {
+	assert.Equal(t, Summary{}, getSummary(nil))
+	assert.Equal(t, Summary{Min: 5, Max: 5, Avg: 5, P95: 5}, getSummary([]float64{5}))
+
+	vs := make([]float64, 100)
+	for i := range vs {
+		vs[i] = float64(100 - i)
+	}
+	assert.Equal(t, Summary{Min: 1, Max: 100, Avg: 50.5, P95: 95}, getSummary(vs))
+}
+
+func TestGetSummaries(t *testing.T) {
+	rs := []Record{
+		{Time: time.Unix(1, 0), Pprof: PprofStat{Goroutine: 10}, MemStats: MemStats{HeapAlloc: 2048}},
+		{Time: time.Unix(2, 0), Pprof: PprofStat{Goroutine: 30}, MemStats: MemStats{HeapAlloc: 1024}},
+	}
+
+	cols := Columns{Include: []string{"goroutine", "HeapAlloc", "LastGC"}}.filter(getColumns(capabilities{}))
+	ss := getSummaries(cols, rs)
+
+	assert.Equal(t, map[string]Summary{
+		"pprof.Lookup.goroutine":     {Min: 10, Max: 30, Avg: 20, P95: 30},
+		"runtime.MemStats.HeapAlloc": {Min: 1024, Max: 2048, Avg: 1536, P95: 2048},
+	}, ss)
+
+	var b bytes.Buffer
+	require.NoError(t, writeSummaries(&b, cols, ss, display{}))
+	assert.Equal(t, 4, strings.Count(b.String(), `<tr class="tbl__row--summary">`))
+	assert.Contains(t, b.String(), `<td class="tbl__col1">max</td><td style="padding-left: 10px;">30</td><td></td><td style="padding-left: 10px;">2.000 KiB</td><td></td><td style="padding-left: 10px;"></td><td></td></tr>`)
+}
+
+func TestWindowSummary(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := Window(ctx, WindowOpts{Window: time.Second, Frequency: 50 * time.Millisecond})
+
+	time.Sleep(200 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=json&summary=true", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var ss map[string]Summary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ss))
+	require.Contains(t, ss, "pprof.Lookup.goroutine")
+	assert.NotZero(t, ss["pprof.Lookup.goroutine"].Max)
+
+	w = httptest.NewRecorder()
+	f(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080", http.NoBody))
+	assert.Contains(t, w.Body.String(), `<tr class="tbl__row--summary"><td class="tbl__col1">p95`)
+}
@@ -0,0 +1,166 @@
+package pprofrec
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tagStore holds the tags of a recorder and the tags of its open ranges. It is safe for concurrent use.
+type tagStore struct {
+	mu     sync.Mutex
+	tags   map[string]string
+	ranges []tagRange
+	nextID int
+
+	// merged holds the tags that are attached to records, it is replaced rather than modified
+	// so that records can share it.
+	merged map[string]string
+}
+
+// tagRange is a tag that is attached to records until its range ends.
+type tagRange struct {
+	id    int
+	key   string
+	value string
+}
+
+func newTagStore(tags map[string]string) *tagStore {
+	s := &tagStore{tags: map[string]string{}}
+	for k, v := range tags {
+		s.tags[k] = v
+	}
+	s.merge()
+
+	return s
+}
+
+// set sets the tag key to value.
+func (s *tagStore) set(key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tags[key] = value
+	s.merge()
+}
+
+// startRange sets the tag key to value until end is called, ranges override the tags and earlier ranges.
+func (s *tagStore) startRange(key string, value string) (end func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+
+	s.ranges = append(s.ranges, tagRange{id: id, key: key, value: value})
+	s.merge()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			for i, r := range s.ranges {
+				if r.id == id {
+					s.ranges = append(s.ranges[:i], s.ranges[i+1:]...)
+
+					break
+				}
+			}
+			s.merge()
+		})
+	}
+}
+
+// merge rebuilds the merged tags, it has to be called with the lock held.
+func (s *tagStore) merge() {
+	if len(s.tags) == 0 && len(s.ranges) == 0 {
+		s.merged = nil
+
+		return
+	}
+
+	merged := make(map[string]string, len(s.tags)+len(s.ranges))
+	for k, v := range s.tags {
+		merged[k] = v
+	}
+	for _, r := range s.ranges {
+		merged[r.key] = r.value
+	}
+
+	s.merged = merged
+}
+
+// get returns the tags and the tags of the open ranges, the map must not be modified.
+func (s *tagStore) get() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.merged
+}
+
+// getTags returns a copy of the tags without the tags of the open ranges.
+func (s *tagStore) getTags() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.tags) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+
+	return tags
+}
+
+// formatTags formats tags as space separated key=value pairs ordered by key.
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + tags[k]
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// equalTags reports whether a and b hold the same tags.
+func equalTags(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		w, ok := b[k]
+		if !ok || v != w {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getTagAnnotations returns an annotation that lists the tags of current if they differ from previous,
+// so that the ranges of tags are marked in the html table.
+func getTagAnnotations(previous map[string]string, current Record) []Annotation {
+	if equalTags(previous, current.Tags) {
+		return nil
+	}
+
+	label := "tags " + formatTags(current.Tags)
+	if len(current.Tags) == 0 {
+		label = "tags removed"
+	}
+
+	return []Annotation{{Time: current.Time, Label: label}}
+}
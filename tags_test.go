@@ -0,0 +1,101 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagStore(t *testing.T) {
+	tags := map[string]string{"region": "eu"}
+	s := newTagStore(tags)
+	tags["region"] = "us"
+	assert.Equal(t, map[string]string{"region": "eu"}, s.get())
+
+	s.set("sha", "abc")
+	before := s.get()
+
+	end := s.startRange("region", "load-test")
+	assert.Equal(t, map[string]string{"region": "load-test", "sha": "abc"}, s.get())
+	assert.Equal(t, map[string]string{"region": "eu", "sha": "abc"}, s.getTags())
+	assert.Equal(t, map[string]string{"region": "eu", "sha": "abc"}, before)
+
+	endPhase := s.startRange("phase", "ramp-up")
+	end()
+	end()
+	assert.Equal(t, map[string]string{"region": "eu", "sha": "abc", "phase": "ramp-up"}, s.get())
+
+	endPhase()
+	assert.Equal(t, map[string]string{"region": "eu", "sha": "abc"}, s.get())
+
+	assert.Nil(t, newTagStore(nil).get())
+}
+
+func TestFormatTags(t *testing.T) {
+	assert.Equal(t, "", formatTags(nil))
+	assert.Equal(t, "region=eu sha=abc", formatTags(map[string]string{"sha": "abc", "region": "eu"}))
+}
+
+func TestGetTagAnnotations(t *testing.T) {
+	now := time.Now()
+
+	assert.Empty(t, getTagAnnotations(nil, Record{Time: now}))
+	assert.Empty(t, getTagAnnotations(map[string]string{"a": "1"}, Record{Time: now, Tags: map[string]string{"a": "1"}}))
+	assert.Equal(t, []Annotation{{Time: now, Label: "tags a=2"}},
+		getTagAnnotations(map[string]string{"a": "1"}, Record{Time: now, Tags: map[string]string{"a": "2"}}))
+	assert.Equal(t, []Annotation{{Time: now, Label: "tags removed"}},
+		getTagAnnotations(map[string]string{"a": "1"}, Record{Time: now}))
+}
+
+func TestRecorderTags(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Second, Frequency: 20 * time.Millisecond, Tags: map[string]string{"sha": "abc"}})
+	rec.Start(ctx)
+	defer rec.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	end := rec.TagRange("test", "load")
+	time.Sleep(50 * time.Millisecond)
+	end()
+	time.Sleep(50 * time.Millisecond)
+
+	f := Window(ctx, WindowOpts{Recorder: rec})
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=json", nil)
+	w := httptest.NewRecorder()
+	f(w, r)
+
+	var rs []Record
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rs))
+	require.NotEmpty(t, rs)
+	assert.Equal(t, map[string]string{"sha": "abc"}, rs[0].Tags)
+	assert.Equal(t, map[string]string{"sha": "abc"}, rs[len(rs)-1].Tags)
+
+	tagged := false
+	for _, r := range rs {
+		if r.Tags["test"] == "load" {
+			tagged = true
+		}
+	}
+	assert.True(t, tagged)
+
+	r = httptest.NewRequest(http.MethodGet, "http://localhost:8080?format=csv&cols=goroutine", nil)
+	w = httptest.NewRecorder()
+	f(w, r)
+	assert.Contains(t, w.Body.String(), ",sha=abc test=load\n")
+
+	r = httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	w = httptest.NewRecorder()
+	f(w, r)
+	assert.True(t, strings.Contains(w.Body.String(), "tags sha=abc test=load"))
+	assert.Contains(t, w.Body.String(), "sha=abc")
+}
@@ -0,0 +1,122 @@
+package pprofrec
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Format is the encoding of the records written by Tee.
+type Format string
+
+const (
+	// FormatNDJSON writes a record per line encoded as JSON, versioned by SchemaVersion.
+	FormatNDJSON Format = formatNDJSON
+	// FormatCSV writes a header row with the qualified column names followed by a row per record,
+	// durations are written in seconds and times in RFC 3339.
+	FormatCSV Format = formatCSV
+)
+
+// tee writes the records it receives to a writer in its format.
+type tee struct {
+	w      io.Writer
+	format Format
+	rs     chan Record
+	// done is closed once the records of rs have been written.
+	done chan struct{}
+}
+
+// teeSet holds the tees of a recorder, it is safe for concurrent use.
+type teeSet struct {
+	mu   sync.RWMutex
+	tees map[*tee]bool
+}
+
+func newTeeSet() *teeSet {
+	return &teeSet{tees: map[*tee]bool{}}
+}
+
+// add adds t and returns a function that removes it, closes its channel and waits until its records have been written.
+func (s *teeSet) add(t *tee) (remove func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tees[t] = true
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.tees, t)
+			close(t.rs)
+			s.mu.Unlock()
+
+			<-t.done
+		})
+	}
+}
+
+// push passes r to the tees, records are dropped while a tee falls behind.
+func (s *teeSet) push(r Record, onError func(error)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for t := range s.tees {
+		select {
+		case t.rs <- r:
+		default:
+			reportError(onError, fmt.Errorf("dropping record of %v: tee falls behind", r.Time))
+		}
+	}
+}
+
+// drain writes the records received by t to its writer until its channel is closed.
+func (t *tee) drain(cols []column, onError func(error)) {
+	defer close(t.done)
+
+	var cw *csv.Writer
+	if t.format == FormatCSV {
+		cw = csv.NewWriter(t.w)
+
+		err := writeCSVHeader(cw, cols)
+		if err != nil {
+			reportError(onError, fmt.Errorf("failed to write to tee: %w", err))
+		}
+	}
+
+	for r := range t.rs {
+		var err error
+		switch t.format {
+		case FormatNDJSON:
+			err = writeNDJSON(t.w, r)
+		case FormatCSV:
+			err = writeCSVRecord(cw, cols, r, false)
+			if err == nil {
+				cw.Flush()
+				err = cw.Error()
+			}
+		}
+		if err != nil {
+			reportError(onError, fmt.Errorf("failed to write to tee: %w", err))
+		}
+	}
+}
+
+// Tee encodes every record in format to w in addition, e.g. to os.Stdout, a pipe or a socket,
+// so that log shippers collect the metrics without further infrastructure. Records are written
+// from a goroutine per tee and dropped while w falls behind. Untee stops writing to w once the pending records
+// have been written.
+func (rec *Recorder) Tee(w io.Writer, format Format) (untee func(), err error) {
+	if format != FormatNDJSON && format != FormatCSV {
+		return nil, fmt.Errorf("unsupported format: %v", format)
+	}
+
+	t := &tee{w: w, format: format, rs: make(chan Record, 64), done: make(chan struct{})}
+	untee = rec.tees.add(t)
+
+	go t.drain(rec.s.cols, rec.opts.OnError)
+
+	return untee, nil
+}
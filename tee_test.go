@@ -0,0 +1,73 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// teeBuffer is a bytes.Buffer that is safe for concurrent use.
+type teeBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *teeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.b.Write(p)
+}
+
+func (b *teeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.b.String()
+}
+
+func TestRecorderTee(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Frequency: 20 * time.Millisecond, Columns: Columns{Include: []string{"goroutine"}}})
+
+	_, err := rec.Tee(&teeBuffer{}, Format("xml"))
+	assert.EqualError(t, err, "unsupported format: xml")
+
+	var ndjson, csv teeBuffer
+	untee, err := rec.Tee(&ndjson, FormatNDJSON)
+	require.NoError(t, err)
+	unteeCSV, err := rec.Tee(&csv, FormatCSV)
+	require.NoError(t, err)
+	defer unteeCSV()
+
+	rec.Start(ctx)
+	defer rec.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	untee()
+	untee()
+	n := strings.Count(ndjson.String(), "\n")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, n, strings.Count(ndjson.String(), "\n"))
+
+	lines := strings.Split(strings.TrimSpace(ndjson.String()), "\n")
+	require.NotEmpty(t, lines)
+
+	var r Record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &r))
+	assert.NotZero(t, r.Pprof.Goroutine)
+
+	lines = strings.Split(strings.TrimSpace(csv.String()), "\n")
+	require.True(t, len(lines) > 1)
+	assert.Equal(t, "time,pprof.Lookup.goroutine,annotations,tags", lines[0])
+}
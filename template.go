@@ -0,0 +1,56 @@
+package pprofrec
+
+import (
+	"html/template"
+	"io"
+)
+
+// TemplateData is what a WindowOpts.Template is executed with.
+//
+//	{{range .Rows}}<tr><td>{{.Record.Time.Format "15:04:05"}}</td><td>{{.Record.Pprof.Goroutine}}</td></tr>{{end}}
+type TemplateData struct {
+	// Metadata describes the runtime configuration and the host of the process.
+	Metadata Metadata
+	// Columns lists the group, name and unit of the selected columns in the order of the builtin table, their values are zero.
+	Columns []Metric
+	// Rows holds a row per record, ordered from oldest to latest.
+	Rows []TemplateRow
+}
+
+// TemplateRow is a row of TemplateData.
+type TemplateRow struct {
+	// Record is the typed record of the row.
+	Record Record
+	// Metrics holds the values of the Columns in the record, durations are given in seconds and times in unix seconds.
+	Metrics []Metric
+	// Leak is true if goroutines leaked during the row.
+	Leak bool
+}
+
+// getTemplateData returns the data that rs are rendered with by a custom template.
+func getTemplateData(cols []column, rs []Record, leaks []bool, m Metadata) TemplateData {
+	d := TemplateData{
+		Metadata: m,
+		Columns:  make([]Metric, len(cols)),
+		Rows:     make([]TemplateRow, len(rs)),
+	}
+
+	for i, col := range cols {
+		d.Columns[i] = Metric{Group: col.group.name, Sample: Sample{Name: col.name, Unit: getSampleUnit(col.unit)}}
+	}
+
+	for i, r := range rs {
+		d.Rows[i] = TemplateRow{Record: r, Metrics: getMetrics(cols, r), Leak: i < len(leaks) && leaks[i]}
+	}
+
+	return d
+}
+
+func writeTemplate(w io.Writer, t *template.Template, d TemplateData) (err error) {
+	err = t.Execute(w, d)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package pprofrec
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTemplateData(t *testing.T) {
+	cols := Columns{Include: []string{"goroutine", "gcpauses"}}.filter(getColumns(capabilities{gcPauses: true}))
+	rs := goroutineRecords(time.Now(), 10, 20)
+	rs[1].GCPauses = &GCPauses{Count: 2, MaxNs: 1e6}
+	rs[0].GCPauses = &GCPauses{}
+
+	d := getTemplateData(cols, rs, []bool{false, true}, Metadata{GoVersion: "go1.14"})
+	assert.Equal(t, "go1.14", d.Metadata.GoVersion)
+	assert.Equal(t, []Metric{
+		{Group: "pprof", Sample: Sample{Name: "goroutine"}},
+		{Group: "gcpauses", Sample: Sample{Name: "count"}},
+		{Group: "gcpauses", Sample: Sample{Name: "max", Unit: UnitSeconds}},
+	}, d.Columns)
+
+	require.Len(t, d.Rows, 2)
+	assert.False(t, d.Rows[0].Leak)
+	assert.True(t, d.Rows[1].Leak)
+	assert.Equal(t, 20, d.Rows[1].Record.Pprof.Goroutine)
+	assert.Equal(t, 0.001, d.Rows[1].Metrics[2].Value)
+}
+
+func TestWindowTemplate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: time.Second, Columns: Columns{Include: []string{"pprof"}}})
+	for _, r := range goroutineRecords(time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC), 10, 20) {
+		rec.rs.push(r)
+	}
+
+	tmpl := template.Must(template.New("window").Parse(`<h1>acme</h1>{{range .Rows}}<p>{{.Record.Time.Format "15:04:05"}} {{.Record.Pprof.Goroutine}}</p>{{end}}`))
+	h := Window(ctx, WindowOpts{Recorder: rec, Template: tmpl})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=UTF-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `<h1>acme</h1><p>12:00:00 10</p><p>12:00:01 20</p>`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?format=json", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "acme")
+}
@@ -0,0 +1,71 @@
+package pprofrec
+
+import (
+	"fmt"
+	"io"
+)
+
+// Theme configures the look of the html output.
+type Theme struct {
+	// CSS is appended to the default stylesheet, e.g. to override colors.
+	CSS string
+}
+
+var (
+	// LightTheme renders dark text on a light background, it is the default.
+	LightTheme = Theme{}
+	// DarkTheme renders light text on a dark background,
+	// custom CSS can be appended to DarkTheme.CSS to adjust it further.
+	DarkTheme = Theme{CSS: `
+		body, table thead th, .tbl__head1 th, .tbl__col1 {
+			background-color: #1e1e1e;
+			color: #d4d4d4;
+		}
+
+		table thead th {
+			border-color: #1e1e1e;
+		}
+
+		a {
+			color: #6ca0dc;
+		}
+
+		.tbl__row--leak td {
+			background-color: #5a1e1e;
+		}
+
+		.tbl__row--annotation td {
+			background-color: #1e3a5f;
+		}
+
+		.tbl__row--summary td {
+			background-color: #2a2a2a;
+		}
+
+		td.tbl__cell--warning {
+			background-color: #5c4b12;
+		}
+
+		td.tbl__cell--critical {
+			background-color: #7a1f1f;
+		}
+
+		.tbl__sparkline polyline {
+			stroke: #6ca0dc;
+		}
+`}
+)
+
+// writeTheme writes the stylesheet of t, nothing is written for the default theme.
+func writeTheme(w io.Writer, t Theme) (err error) {
+	if t.CSS == "" {
+		return
+	}
+
+	_, err = fmt.Fprintf(w, "\t<style>%s\t</style>\n", t.CSS)
+	if err != nil {
+		return
+	}
+
+	return
+}
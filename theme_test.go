@@ -0,0 +1,35 @@
+package pprofrec
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTheme(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, writeTheme(&b, LightTheme))
+	assert.Empty(t, b.String())
+
+	require.NoError(t, writeTheme(&b, Theme{CSS: "a { color: red; }"}))
+	assert.Equal(t, "\t<style>a { color: red; }\t</style>\n", b.String())
+}
+
+func TestWindowTheme(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := Window(ctx, WindowOpts{Window: time.Second, Frequency: 10 * time.Millisecond, Theme: DarkTheme})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), DarkTheme.CSS)
+	assert.Contains(t, w.Body.String(), "<table>")
+}
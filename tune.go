@@ -0,0 +1,243 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// tunedGOGC holds the GOGC set by Tune, as the runtime does not expose the current GOGC.
+var tunedGOGC atomic.Value
+
+// readGOGC returns the GOGC set by Tune, or the value of the GOGC environment variable, 100 if unset.
+func readGOGC() string {
+	gogc, ok := tunedGOGC.Load().(string)
+	if ok {
+		return gogc
+	}
+
+	gogc = os.Getenv("GOGC")
+	if gogc == "" {
+		return "100"
+	}
+
+	return gogc
+}
+
+// tune applies the action, one of gogc, gomemlimit, gc or freeosmemory, and returns the label of the annotation
+// that records it. GOGC and GOMEMLIMIT are set to value, off disables the GC or lifts the memory limit.
+func tune(action string, value string) (label string, err error) {
+	switch action {
+	case "gogc":
+		percent := -1
+		if value != "off" {
+			percent, err = strconv.Atoi(value)
+			if err != nil || percent < 0 {
+				return "", fmt.Errorf("invalid GOGC: %v", value)
+			}
+		}
+
+		previous := readGOGC()
+		debug.SetGCPercent(percent)
+
+		gogc := "off"
+		if percent >= 0 {
+			gogc = strconv.Itoa(percent)
+		}
+		tunedGOGC.Store(gogc)
+
+		return fmt.Sprintf("GOGC=%v (was %v)", gogc, previous), nil
+	case "gomemlimit":
+		var limit int64 = math.MaxInt64
+		if value != "off" {
+			limit, err = parseBytes(value)
+			if err != nil || limit <= 0 {
+				return "", fmt.Errorf("invalid GOMEMLIMIT: %v", value)
+			}
+		}
+
+		previous, err := setMemoryLimit(limit)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("GOMEMLIMIT=%v (was %v)", formatMemoryLimit(limit), formatMemoryLimit(previous)), nil
+	case "gc":
+		start := time.Now()
+		runtime.GC()
+
+		return fmt.Sprintf("runtime.GC() took %v", time.Since(start).Round(time.Microsecond)), nil
+	case "freeosmemory":
+		start := time.Now()
+		debug.FreeOSMemory()
+
+		return fmt.Sprintf("debug.FreeOSMemory() took %v", time.Since(start).Round(time.Microsecond)), nil
+	default:
+		return "", fmt.Errorf("unknown action: %v", action)
+	}
+}
+
+// isSameOrigin reports whether r has been sent by a page of the same origin, as declared by the Sec-Fetch-Site,
+// Origin or Referer headers that browsers send. Requests without these headers, e.g. of curl, are not sent by browsers.
+func isSameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+
+	source := r.Header.Get("Origin")
+	if source == "" || source == "null" {
+		source = r.Header.Get("Referer")
+	}
+
+	if source == "" {
+		return r.Header.Get("Origin") != "null"
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == r.Host
+}
+
+// parseBytes parses a number of bytes with an optional suffix of KiB, MiB, GiB or TiB, e.g. 512MiB.
+func parseBytes(s string) (int64, error) {
+	n := 0
+	for n < len(s) && (s[n] == '.' || (s[n] >= '0' && s[n] <= '9')) {
+		n++
+	}
+
+	v, err := strconv.ParseFloat(s[:n], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bytes: %v", s)
+	}
+
+	if n < len(s) {
+		m, ok := alertByteSuffixes[s[n:]]
+		if !ok {
+			return 0, fmt.Errorf("invalid bytes: %v", s)
+		}
+
+		v *= m
+	}
+
+	return int64(v), nil
+}
+
+// formatMemoryLimit formats a soft memory limit in binary units, off if there is none.
+func formatMemoryLimit(limit int64) string {
+	if limit <= 0 || limit == math.MaxInt64 {
+		return "off"
+	}
+
+	return string(appendHumanBytes(nil, limit))
+}
+
+// Tune changes GOGC and GOMEMLIMIT at runtime and triggers runtime.GC or debug.FreeOSMemory, to observe the effect
+// of the GC configuration without a restart. Actions are accepted via POST with the form values action, one of gogc,
+// gomemlimit, gc or freeosmemory, and value, e.g. 50 for GOGC, 512MiB for GOMEMLIMIT or off for either.
+// Each action is recorded as an annotation, which is responded as JSON, so that its effect on the subsequent
+// records is traceable. A GET request responds with a html page with buttons for the actions.
+// Cross-origin POSTs are rejected, so that a page open in a browser that holds the credentials cannot trigger actions.
+// GOMEMLIMIT requires go1.19. Tune should be restricted by Auth as it affects the whole process.
+func Tune(rec *Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer closeBody(rec.opts.OnError, r)
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+			_, err := fmt.Fprintf(w, tunePage, html.EscapeString(readGOGC()), html.EscapeString(formatMemoryLimit(readMemoryLimit())))
+			if err != nil {
+				reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+			}
+
+			return
+		case http.MethodPost:
+			if !isSameOrigin(r) {
+				http.Error(w, "cross-origin request", http.StatusForbidden)
+
+				return
+			}
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		label, err := tune(r.FormValue("action"), strings.TrimSpace(r.FormValue("value")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		a := rec.Annotate(label)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		err = json.NewEncoder(w).Encode(a)
+		if err != nil {
+			reportError(rec.opts.OnError, fmt.Errorf("failed to write to response writer: %w", err))
+		}
+	}
+}
+
+// tunePage is formatted with the current GOGC and GOMEMLIMIT, the forms are posted to the page itself
+// and the responded annotation or error is shown below them.
+const tunePage = `<!DOCTYPE html>
+<html>
+<head>
+	<style>
+		body {
+			font-family: Courier, monospace;
+			font-size: 13px;
+			margin: 10px;
+		}
+
+		form {
+			margin-bottom: 10px;
+		}
+	</style>
+	<title>pprofrec tune</title>
+</head>
+<body>
+	<h3>tune</h3>
+	<form data-action="gogc">GOGC <input name="value" placeholder="%[1]s"> <button>set</button></form>
+	<form data-action="gomemlimit">GOMEMLIMIT <input name="value" placeholder="%[2]s"> <button>set</button></form>
+	<form data-action="gc"><button>runtime.GC()</button></form>
+	<form data-action="freeosmemory"><button>debug.FreeOSMemory()</button></form>
+	<pre id="result"></pre>
+	<script>
+	document.querySelectorAll("form").forEach(function (f) {
+		f.onsubmit = function (e) {
+			e.preventDefault();
+			var body = new URLSearchParams(new FormData(f));
+			body.set("action", f.dataset.action);
+			fetch(location.href, {method: "POST", body: body}).then(function (r) {
+				return r.text();
+			}).then(function (t) {
+				document.getElementById("result").textContent = t;
+			});
+		};
+	});
+	</script>
+</body>
+</html>
+`
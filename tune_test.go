@@ -0,0 +1,164 @@
+package pprofrec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postTune(h http.HandlerFunc, action string, value string) *httptest.ResponseRecorder {
+	form := url.Values{"action": {action}, "value": {value}}
+	r := httptest.NewRequest(http.MethodPost, "/debug/pprof/tune", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	h(w, r)
+
+	return w
+}
+
+func TestTune(t *testing.T) {
+	previous := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(previous)
+	defer tunedGOGC.Store("100")
+
+	rec := newTestRecorder(t, RecorderOpts{})
+	h := Tune(rec)
+
+	tunedGOGC.Store("100")
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/tune", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<form data-action="gogc">GOGC <input name="value" placeholder="100">`)
+
+	w = postTune(h, "gogc", "50")
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var a Annotation
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&a))
+	assert.Equal(t, "GOGC=50 (was 100)", a.Label)
+	assert.Equal(t, 50, debug.SetGCPercent(50))
+	assert.Equal(t, "50", getMetadata().GOGC)
+
+	w = postTune(h, "gogc", "off")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "GOGC=off (was 50)")
+	assert.Equal(t, -1, debug.SetGCPercent(100))
+
+	w = postTune(h, "gc", "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "runtime.GC() took")
+
+	w = postTune(h, "freeosmemory", "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "debug.FreeOSMemory() took")
+
+	as := rec.Annotations()
+	require.Len(t, as, 4)
+
+	for _, tc := range [][2]string{{"gogc", "-1"}, {"gogc", "fast"}, {"gomemlimit", "10ms"}, {"gomemlimit", "0"}, {"unknown", ""}} {
+		w = postTune(h, tc[0], tc[1])
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc)
+	}
+	assert.Len(t, rec.Annotations(), 4)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodDelete, "/debug/pprof/tune", http.NoBody))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestTuneCrossOrigin(t *testing.T) {
+	rec := newTestRecorder(t, RecorderOpts{})
+	h := Tune(rec)
+
+	for _, header := range []map[string]string{
+		{"Origin": "http://evil.example"},
+		{"Origin": "null"},
+		{"Referer": "http://evil.example/page"},
+		{"Sec-Fetch-Site": "cross-site"},
+		{"Sec-Fetch-Site": "same-site", "Origin": "http://example.com"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/debug/pprof/tune", strings.NewReader("action=gc"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		h(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Code, header)
+	}
+	assert.Empty(t, rec.Annotations())
+
+	for _, header := range []map[string]string{
+		{"Origin": "http://example.com", "Sec-Fetch-Site": "same-origin"},
+		{"Referer": "http://example.com/debug/pprof/tune"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/debug/pprof/tune", strings.NewReader("action=gc"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		h(w, r)
+		assert.Equal(t, http.StatusCreated, w.Code, header)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for s, expected := range map[string]int64{"1024": 1024, "512MiB": 512 << 20, "1.5GiB": 3 << 29} {
+		v, err := parseBytes(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, v, s)
+	}
+
+	for _, s := range []string{"", "MiB", "1MB", "-1"} {
+		_, err := parseBytes(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRegisterHandlersTune(t *testing.T) {
+	mux := http.NewServeMux()
+	rec := RegisterHandlers(mux, "/debug/pprof", HandlersOpts{Auth: Auth{Token: "token"}})
+	defer rec.Stop()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/tune", http.NoBody))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mux = http.NewServeMux()
+	rec = RegisterHandlers(mux, "/debug/pprof", HandlersOpts{Tune: true})
+	defer rec.Stop()
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/tune", http.NoBody))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/index", http.NoBody))
+	assert.NotContains(t, w.Body.String(), `href="/debug/pprof/tune"`)
+
+	mux = http.NewServeMux()
+	rec = RegisterHandlers(mux, "/debug/pprof", HandlersOpts{Auth: Auth{Token: "token"}, Tune: true})
+	defer rec.Stop()
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/tune", http.NoBody))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/pprof/index", http.NoBody)
+	r.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), `href="/debug/pprof/tune"`)
+}
@@ -0,0 +1,127 @@
+package pprofrec
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// getQueryDuration parses the duration query parameter name, it returns fallback if the parameter is not set.
+func getQueryDuration(r *http.Request, name string, fallback time.Duration) (d time.Duration, err error) {
+	q := r.URL.Query().Get(name)
+	if q == "" {
+		return fallback, nil
+	}
+
+	d, err = time.ParseDuration(q)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v: %v", name, err.Error())
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %v: must be positive", name)
+	}
+
+	return
+}
+
+// getQueryCount parses the positive count query parameter name, it returns zero if the parameter is not set.
+func getQueryCount(r *http.Request, name string) (n int, err error) {
+	q := r.URL.Query().Get(name)
+	if q == "" {
+		return 0, nil
+	}
+
+	n, err = strconv.Atoi(q)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %v: must be a positive integer", name)
+	}
+
+	return
+}
+
+// limitLast returns the last n records, all records if n is zero.
+func limitLast(rs []Record, n int) []Record {
+	if n <= 0 || n >= len(rs) {
+		return rs
+	}
+
+	return rs[len(rs)-n:]
+}
+
+// limitWindow returns the records within window of the latest record, all records if window is zero.
+func limitWindow(rs []Record, window time.Duration) []Record {
+	if window <= 0 || len(rs) == 0 {
+		return rs
+	}
+
+	from := rs[len(rs)-1].Time.Add(-window)
+	for i, r := range rs {
+		if !r.Time.Before(from) {
+			return rs[i:]
+		}
+	}
+
+	return nil
+}
+
+// limitFrequency thins out the records so that consecutive records are at least frequency apart,
+// tolerating ticks that are late by up to tolerance. All records are returned if frequency is zero.
+func limitFrequency(rs []Record, frequency time.Duration, tolerance time.Duration) []Record {
+	if frequency <= 0 || len(rs) == 0 {
+		return rs
+	}
+
+	limited := []Record{rs[0]}
+	for _, r := range rs[1:] {
+		if r.Time.Sub(limited[len(limited)-1].Time) >= frequency-tolerance {
+			limited = append(limited, r)
+		}
+	}
+
+	return limited
+}
+
+// getQueryTime parses the time query parameter name as RFC 3339 or as a clock time like 15:04:05
+// that refers to the day of ref, ok is false if the parameter is not set.
+func getQueryTime(r *http.Request, name string, ref time.Time) (t time.Time, ok bool, err error) {
+	q := r.URL.Query().Get(name)
+	if q == "" {
+		return
+	}
+
+	t, err = time.Parse(time.RFC3339Nano, q)
+	if err == nil {
+		return t, true, nil
+	}
+
+	c, err := time.Parse("15:04:05", q)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %v: must be RFC 3339 or 15:04:05", name)
+	}
+
+	y, m, d := ref.Date()
+
+	return time.Date(y, m, d, c.Hour(), c.Minute(), c.Second(), 0, ref.Location()), true, nil
+}
+
+// nearestRecord returns the record closest to t, rs must not be empty.
+func nearestRecord(rs []Record, t time.Time) Record {
+	nearest := rs[0]
+	for _, r := range rs[1:] {
+		if absDuration(r.Time.Sub(t)) < absDuration(nearest.Time.Sub(t)) {
+			nearest = r
+		}
+	}
+
+	return nearest
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
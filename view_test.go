@@ -0,0 +1,264 @@
+package pprofrec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func records(ts time.Time, offsets ...time.Duration) (rs []Record) {
+	for _, o := range offsets {
+		rs = append(rs, Record{Time: ts.Add(o)})
+	}
+
+	return
+}
+
+func TestLimitWindow(t *testing.T) {
+	ts := time.Now()
+	rs := records(ts, 0, time.Second, 2*time.Second, 3*time.Second)
+
+	assert.Equal(t, rs, limitWindow(rs, 0))
+	assert.Equal(t, rs[2:], limitWindow(rs, time.Second))
+	assert.Equal(t, rs, limitWindow(rs, time.Hour))
+	assert.Empty(t, limitWindow(nil, time.Second))
+}
+
+func TestLimitFrequency(t *testing.T) {
+	ts := time.Now()
+	rs := records(ts, 0, 1001*time.Millisecond, 1999*time.Millisecond, 3*time.Second, 4002*time.Millisecond)
+
+	assert.Equal(t, rs, limitFrequency(rs, 0, 0))
+	assert.Equal(t, []Record{rs[0], rs[2], rs[4]}, limitFrequency(rs, 2*time.Second, 500*time.Millisecond))
+}
+
+func TestLimitLast(t *testing.T) {
+	rs := records(time.Now(), 0, time.Second, 2*time.Second)
+
+	assert.Equal(t, rs, limitLast(rs, 0))
+	assert.Equal(t, rs[1:], limitLast(rs, 2))
+	assert.Equal(t, rs, limitLast(rs, 5))
+}
+
+func TestGetQueryCount(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?last=50&zero=0&bad=x", nil)
+
+	n, err := getQueryCount(r, "last")
+	require.NoError(t, err)
+	assert.Equal(t, 50, n)
+
+	n, err = getQueryCount(r, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = getQueryCount(r, "zero")
+	assert.Error(t, err)
+
+	_, err = getQueryCount(r, "bad")
+	assert.Error(t, err)
+}
+
+func TestWindowLast(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: time.Second, Columns: Columns{Include: []string{"pprof"}}})
+	for _, r := range goroutineRecords(time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC), 10, 20, 30, 40, 50) {
+		rec.rs.push(r)
+	}
+
+	h := Window(ctx, WindowOpts{Recorder: rec})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?format=json&last=2", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var rs []Record
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rs))
+	require.Len(t, rs, 2)
+	assert.Equal(t, 50, rs[1].Pprof.Goroutine)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?last=2", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, strings.Count(w.Body.String(), `<tr><td class="tbl__col1">`))
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?last=-1", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetQueryDuration(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080?freq=250ms&window=-1s&bad=x", nil)
+
+	d, err := getQueryDuration(r, "freq", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, d)
+
+	d, err = getQueryDuration(r, "missing", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, d)
+
+	_, err = getQueryDuration(r, "window", 0)
+	assert.Error(t, err)
+
+	_, err = getQueryDuration(r, "bad", 0)
+	assert.Error(t, err)
+}
+
+func TestStreamWindow(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: time.Second})
+
+	r, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost:8080?format=ndjson&freq=50ms&window=300ms", http.NoBody)
+	require.NoError(t, err)
+
+	w := &responseWriter{}
+	done := make(chan struct{})
+	go func() {
+		f(w, r)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not end after window")
+	}
+
+	assert.True(t, strings.Count(w.Buffer.String(), "\n") > 2)
+}
+
+func TestStreamMaxDuration(t *testing.T) {
+	f := Stream(StreamOpts{Frequency: 50 * time.Millisecond, MaxDuration: 200 * time.Millisecond})
+
+	for _, query := range []string{"format=ndjson", "format=ndjson&window=1h"} {
+		r, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost:8080?"+query, http.NoBody)
+		require.NoError(t, err)
+
+		w := &responseWriter{}
+		done := make(chan struct{})
+		go func() {
+			f(w, r)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("stream did not end after max duration: %v", query)
+		}
+	}
+}
+
+func TestWindowResolution(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := Window(ctx, WindowOpts{
+		Window:      time.Second,
+		Frequency:   10 * time.Millisecond,
+		Resolutions: []Resolution{{Window: time.Minute, Frequency: time.Minute}},
+	})
+
+	time.Sleep(50 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?res=1m&format=json", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"time"`))
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?res=5s", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetQueryTime(t *testing.T) {
+	ref := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	_, ok, err := getQueryTime(httptest.NewRequest(http.MethodGet, "/", http.NoBody), "from", ref)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ts, ok, err := getQueryTime(httptest.NewRequest(http.MethodGet, "/?from=09:30:15", http.NoBody), "from", ref)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 10, 1, 9, 30, 15, 0, time.UTC), ts)
+
+	ts, ok, err = getQueryTime(httptest.NewRequest(http.MethodGet, "/?from=2021-09-30T08:00:00Z", http.NoBody), "from", ref)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, time.Date(2021, 9, 30, 8, 0, 0, 0, time.UTC).Equal(ts))
+
+	_, _, err = getQueryTime(httptest.NewRequest(http.MethodGet, "/?from=yesterday", http.NoBody), "from", ref)
+	assert.Error(t, err)
+}
+
+func TestNearestRecord(t *testing.T) {
+	ts := time.Now()
+	rs := records(ts, 0, time.Second, 2*time.Second)
+
+	assert.Equal(t, rs[0], nearestRecord(rs, ts.Add(-time.Hour)))
+	assert.Equal(t, rs[1], nearestRecord(rs, ts.Add(1400*time.Millisecond)))
+	assert.Equal(t, rs[2], nearestRecord(rs, ts.Add(time.Hour)))
+}
+
+func TestWindowDiff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: time.Second, Columns: Columns{Include: []string{"pprof"}}})
+	ts := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, r := range goroutineRecords(ts, 10, 20, 30, 40) {
+		rec.rs.push(r)
+	}
+
+	h := Window(ctx, WindowOpts{Recorder: rec})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?from=12:00:01&to=2021-10-01T12:00:03Z&diff=true", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), "<tr><td"))
+	assert.Contains(t, w.Body.String(), ">40</td>")
+	assert.Contains(t, w.Body.String(), ">20</td>")
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?from=12:00:01&diff=true", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWindowTimeRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := newTestRecorder(t, RecorderOpts{Window: time.Minute, Frequency: time.Second, Columns: Columns{Include: []string{"pprof"}}})
+	ts := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, r := range goroutineRecords(ts, 10, 20, 30, 40, 50) {
+		rec.rs.push(r)
+	}
+
+	h := Window(ctx, WindowOpts{Recorder: rec})
+
+	for _, tc := range []struct {
+		query string
+		count int
+	}{
+		{"from=12:00:01&to=2021-10-01T12:00:03Z", 3},
+		{"from=12:00:03", 2},
+		{"to=12:00:01", 2},
+	} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/window?format=json&"+tc.query, http.NoBody))
+		require.Equal(t, http.StatusOK, w.Code, tc.query)
+
+		var rs []Record
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&rs), tc.query)
+		assert.Len(t, rs, tc.count, tc.query)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build windows
+// +build windows
+
+package pprofrec
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+var (
+	procGetProcessHandleCount     = syscall.NewLazyDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+	procNtQueryInformationProcess = syscall.NewLazyDLL("ntdll.dll").NewProc("NtQueryInformationProcess")
+)
+
+// processIoPriority is the PROCESSINFOCLASS that NtQueryInformationProcess reads the io priority by.
+const processIoPriority = 33
+
+// win32Supported reports whether the handle count can be read.
+func win32Supported() bool {
+	return procGetProcessHandleCount.Find() == nil
+}
+
+// readWin32Stat reads the handle count and the cpu and io priorities of the current process.
+func readWin32Stat(ctx context.Context, p *process.Process) (s Win32Stat, err error) {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return
+	}
+
+	var count uint32
+	ret, _, err := procGetProcessHandleCount.Call(uintptr(h), uintptr(unsafe.Pointer(&count)))
+	if ret == 0 {
+		return s, fmt.Errorf("failed to get handle count: %w", err)
+	}
+	s.HandleCount = count
+
+	if p != nil {
+		s.PriorityClass, err = p.NiceWithContext(ctx)
+		if err != nil {
+			return s, fmt.Errorf("failed to get priority class: %w", err)
+		}
+	}
+
+	var ioPriority uint32
+	status, _, _ := procNtQueryInformationProcess.Call(uintptr(h), processIoPriority, uintptr(unsafe.Pointer(&ioPriority)), unsafe.Sizeof(ioPriority), 0)
+	if status != 0 {
+		return s, fmt.Errorf("failed to get io priority: status %#x", status)
+	}
+	s.IOPriority = ioPriority
+
+	return s, nil
+}
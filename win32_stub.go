@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package pprofrec
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// win32Supported returns false as handles and priority classes are specific to windows.
+func win32Supported() bool {
+	return false
+}
+
+// readWin32Stat is a no-op as handles and priority classes are specific to windows.
+func readWin32Stat(ctx context.Context, p *process.Process) (Win32Stat, error) {
+	return Win32Stat{}, nil
+}
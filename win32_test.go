@@ -0,0 +1,37 @@
+package pprofrec
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWin32Columns(t *testing.T) {
+	cols := Columns{Include: []string{"win32"}}.filter(getColumns(capabilities{win32: true}))
+	require.Len(t, cols, len(win32Columns))
+
+	r := Record{Win32: &Win32Stat{HandleCount: 120, PriorityClass: 8, IOPriority: 2}}
+	assert.Equal(t, float64(120), cols[0].value(r))
+	assert.Equal(t, float64(8), cols[1].value(r))
+	assert.Equal(t, float64(2), cols[2].value(r))
+}
+
+func TestSamplerWin32(t *testing.T) {
+	s := newSampler(context.Background(), nil, nil, Columns{Include: []string{"win32"}})
+
+	if runtime.GOOS != "windows" {
+		assert.Empty(t, s.cols)
+
+		return
+	}
+
+	require.Len(t, s.cols, len(win32Columns))
+
+	r := s.getRecord(context.Background())
+	require.NotNil(t, r.Win32)
+	assert.NotZero(t, r.Win32.HandleCount)
+	assert.Equal(t, int32(8), r.Win32.PriorityClass)
+}